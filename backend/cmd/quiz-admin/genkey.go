@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/matheuspolitano/quiz-go/backend/internal/token"
+)
+
+// runGenKey writes a private key to -out and its public key to -out.pub, both PEM encoded.
+// A paseto-local key is symmetric, it is printed in hex for TOKEN_SYMMETRIC_KEYS instead.
+func runGenKey(args []string) int {
+	flags := flag.NewFlagSet("gen-key", flag.ExitOnError)
+	keyType := flags.String("type", "ed25519", "key type: ed25519, rsa or paseto-local")
+	bits := flags.Int("bits", 3072, "RSA key size")
+	out := flags.String("out", "", "path of the private key, the public key is written next to it with a .pub suffix")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: quiz-admin gen-key -out <path> [-type ed25519|rsa] [-bits 3072]")
+		fmt.Fprintln(os.Stderr, "       quiz-admin gen-key -type paseto-local")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if *keyType == "paseto-local" {
+		fmt.Println(token.NewPasetoLocalKey())
+		return 0
+	}
+	if *out == "" {
+		flags.Usage()
+		return 2
+	}
+
+	var private crypto.Signer
+	var err error
+	switch *keyType {
+	case "ed25519":
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	case "rsa":
+		private, err = rsa.GenerateKey(rand.Reader, *bits)
+	default:
+		log.Printf("unknown key type %q", *keyType)
+		return 2
+	}
+	if err != nil {
+		log.Printf("gen-key failed: %v", err)
+		return 1
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		log.Printf("gen-key failed: %v", err)
+		return 1
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		log.Printf("gen-key failed: %v", err)
+		return 1
+	}
+	if err := writePEM(*out, "PRIVATE KEY", privateDER, 0600); err != nil {
+		log.Printf("gen-key failed: %v", err)
+		return 1
+	}
+	if err := writePEM(*out+".pub", "PUBLIC KEY", publicDER, 0644); err != nil {
+		log.Printf("gen-key failed: %v", err)
+		return 1
+	}
+	fmt.Printf("Wrote %s and %s.pub\n", *out, *out)
+	return 0
+}
+
+func writePEM(path, blockType string, der []byte, perm os.FileMode) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if err := pem.Encode(file, &pem.Block{Type: blockType, Bytes: der}); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
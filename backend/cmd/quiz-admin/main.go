@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/matheuspolitano/quiz-go/backend/internal/config"
+	"github.com/matheuspolitano/quiz-go/backend/internal/memdb"
+)
+
+const usage = `Usage: quiz-admin <command> [flags]
+
+Commands:
+  fsck      check the references between the stored collections
+  set-role  change the role of a user: player, author, instructor or admin
+  gen-key   generate a key to sign tokens with TOKEN_MAKER=asymmetric, paseto-public or paseto-local
+
+Run 'quiz-admin <command> -h' for the command flags.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "fsck":
+		os.Exit(runFsck(os.Args[2:]))
+	case "set-role":
+		os.Exit(runSetRole(os.Args[2:]))
+	case "gen-key":
+		os.Exit(runGenKey(os.Args[2:]))
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// runFsck loads every collection through memdb and reports the broken references.
+// It returns 0 when the data is clean or was repaired, 1 when issues are left.
+func runFsck(args []string) int {
+	flags := flag.NewFlagSet("fsck", flag.ExitOnError)
+	repair := flags.Bool("repair", false, "fix dangling references and quarantine the records that cannot be fixed")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: quiz-admin fsck [-repair] [-json]")
+		fmt.Fprintln(os.Stderr, "Stop the quiz server before running a repair, it caches every collection in memory.")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	store := openStore()
+	defer store.Close()
+
+	report, err := store.Fsck(*repair)
+	if err != nil {
+		log.Printf("fsck failed: %v", err)
+		return 1
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(report)
+	} else {
+		printReport(report)
+	}
+
+	if len(report.Issues) > 0 && !report.Repaired {
+		return 1
+	}
+	return 0
+}
+
+// runSetRole changes the role of a user, e.g. to recover when no admin can log in.
+func runSetRole(args []string) int {
+	flags := flag.NewFlagSet("set-role", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: quiz-admin set-role <username> <role>")
+		fmt.Fprintln(os.Stderr, "Stop the quiz server first, it caches every collection in memory.")
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	store := openStore()
+	defer store.Close()
+
+	user, err := store.SetUserRole(flags.Arg(0), flags.Arg(1))
+	if err != nil {
+		log.Printf("set-role failed: %v", err)
+		return 1
+	}
+	fmt.Printf("%s is now %s\n", user.Username, user.GetRole())
+	return 0
+}
+
+func openStore() *memdb.DBManager {
+	cfg, err := config.LoadConfig(".")
+	if err != nil {
+		log.Fatal(err)
+	}
+	driver, err := memdb.OpenDriver(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	store, err := memdb.NewDBManager(driver)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return store
+}
+
+func printReport(report *memdb.FsckReport) {
+	if len(report.Issues) == 0 {
+		fmt.Println("No issues found.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COLLECTION\tID\tPROBLEM\tREPAIR")
+	for _, issue := range report.Issues {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", issue.Collection, issue.ID, issue.Problem, issue.Repair)
+	}
+	w.Flush()
+
+	if report.Repaired {
+		fmt.Printf("\n%d issue(s) repaired.\n", len(report.Issues))
+	} else {
+		fmt.Printf("\n%d issue(s) found, run with -repair to fix them.\n", len(report.Issues))
+	}
+}
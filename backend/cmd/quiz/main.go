@@ -9,6 +9,7 @@ import (
 	"github.com/matheuspolitano/quiz-go/backend/internal/api"
 	"github.com/matheuspolitano/quiz-go/backend/internal/config"
 	"github.com/matheuspolitano/quiz-go/backend/internal/memdb"
+	"github.com/matheuspolitano/quiz-go/backend/internal/utils"
 )
 
 var interruptSignals = []os.Signal{
@@ -23,10 +24,48 @@ func main() {
 		log.Fatal(err)
 	}
 
-	store, err := memdb.NewDBManager()
+	driver, err := memdb.OpenDriver(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
+	store, err := memdb.NewDBManager(driver)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+	store.SetQuestionBankDir(cfg.DataDir)
+
+	if cfg.BootstrapAdmin != "" {
+		passwordHash, err := utils.HashPassword(cfg.BootstrapAdminPassword)
+		if err != nil {
+			log.Fatalf("BOOTSTRAP_ADMIN_PASSWORD: %v", err)
+		}
+		promoted, err := store.BootstrapAdmin(cfg.BootstrapAdmin, passwordHash)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if promoted {
+			log.Printf("No admin found, %s is now admin", cfg.BootstrapAdmin)
+		}
+	}
+
+	if cfg.QuestionBankWatch {
+		watcher, err := store.WatchQuestionBank(cfg.DataDir)
+		if err != nil {
+			log.Printf("Question bank hot reload disabled: %v", err)
+		} else {
+			defer watcher.Close()
+		}
+	}
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			log.Printf("Received SIGHUP, reloading the question bank")
+			store.ReloadAndLog(cfg.DataDir)
+		}
+	}()
+
 	svc, err := api.New(cfg, store)
 	if err != nil {
 		log.Fatal(err)
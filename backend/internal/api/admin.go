@@ -0,0 +1,168 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/matheuspolitano/quiz-go/backend/internal/memdb"
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+	"github.com/matheuspolitano/quiz-go/backend/internal/utils"
+)
+
+type setRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type deleteRequest struct {
+	Soft    bool `form:"soft"`
+	Cascade bool `form:"cascade"`
+}
+
+// flowRequest selects an attempt of a question flow, 0 selects every attempt
+type flowRequest struct {
+	Soft    bool `form:"soft"`
+	Attempt int  `form:"attempt" binding:"min=0"`
+}
+
+// flowTarget names the attempts selected by a flowRequest in the audit log
+func flowTarget(username, typeQuiz string, attempt int) string {
+	if attempt == 0 {
+		return utils.CombineIDs(username, typeQuiz)
+	}
+	return models.FlowID(username, typeQuiz, attempt)
+}
+
+// adminStatus maps the errors of the admin services to a http status
+func adminStatus(err error) int {
+	switch {
+	case errors.Is(err, memdb.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, memdb.ErrQuestionInUse), errors.Is(err, memdb.ErrQuestionAnswered), errors.Is(err, memdb.ErrNotDeleted),
+		errors.Is(err, memdb.ErrQuestionExists), errors.Is(err, memdb.ErrTypeQuizExists), errors.Is(err, memdb.ErrTypeQuizInUse):
+		return http.StatusConflict
+	case errors.Is(err, memdb.ErrAttemptInProgress), errors.Is(err, memdb.ErrMaxAttemptsReached):
+		return http.StatusConflict
+	case errors.Is(err, memdb.ErrLastAdmin):
+		return http.StatusConflict
+	case errors.Is(err, memdb.ErrInvalidEntry), errors.Is(err, memdb.ErrInvalidRole):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, memdb.ErrInvalidCursor):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (svc *Server) setUserRole(ctx *gin.Context) {
+	var req setRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		SendError(ctx, "error in bind body", err.Error(), http.StatusBadRequest)
+		return
+	}
+	user, err := svc.store.SetUserRole(ctx.Param("username"), req.Role)
+	if err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	recordAudit(ctx, svc.store, &models.AuditEvent{Action: models.AuditRoleChanged, Target: user.Username, Detail: "role=" + req.Role})
+	SendSuccess(ctx, "role updated, it applies from the next login", newUserResponse(user), http.StatusOK)
+}
+
+func (svc *Server) deleteUser(ctx *gin.Context) {
+	var req deleteRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		SendError(ctx, "error in bind query", err.Error(), http.StatusBadRequest)
+		return
+	}
+	username := ctx.Param("username")
+	if err := svc.store.DeleteUser(username, memdb.DeleteOptions{Soft: req.Soft}); err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	recordAudit(ctx, svc.store, &models.AuditEvent{Action: models.AuditUserDeleted, Target: username, Detail: fmt.Sprintf("soft=%t", req.Soft)})
+	SendSuccess(ctx, "user deleted", gin.H{"username": username, "soft": req.Soft}, http.StatusOK)
+}
+
+func (svc *Server) restoreUser(ctx *gin.Context) {
+	username := ctx.Param("username")
+	if err := svc.store.RestoreUser(username); err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	recordAudit(ctx, svc.store, &models.AuditEvent{Action: models.AuditUserRestored, Target: username})
+	SendSuccess(ctx, "user restored", gin.H{"username": username}, http.StatusOK)
+}
+
+func (svc *Server) deleteQuestionFlow(ctx *gin.Context) {
+	var req flowRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		SendError(ctx, "error in bind query", err.Error(), http.StatusBadRequest)
+		return
+	}
+	username, typeQuiz := ctx.Param("username"), ctx.Param("typeQuiz")
+	if err := svc.store.DeleteQuestionFlow(username, typeQuiz, req.Attempt, memdb.DeleteOptions{Soft: req.Soft}); err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	recordAudit(ctx, svc.store, &models.AuditEvent{Action: models.AuditFlowReset, Target: flowTarget(username, typeQuiz, req.Attempt), Detail: fmt.Sprintf("soft=%t", req.Soft)})
+	SendSuccess(ctx, "question flow deleted", gin.H{"username": username, "type_quiz": typeQuiz, "attempt": req.Attempt, "soft": req.Soft}, http.StatusOK)
+}
+
+func (svc *Server) restoreQuestionFlow(ctx *gin.Context) {
+	var req flowRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		SendError(ctx, "error in bind query", err.Error(), http.StatusBadRequest)
+		return
+	}
+	username, typeQuiz := ctx.Param("username"), ctx.Param("typeQuiz")
+	if err := svc.store.RestoreQuestionFlow(username, typeQuiz, req.Attempt); err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	recordAudit(ctx, svc.store, &models.AuditEvent{Action: models.AuditFlowRestored, Target: flowTarget(username, typeQuiz, req.Attempt)})
+	SendSuccess(ctx, "question flow restored", gin.H{"username": username, "type_quiz": typeQuiz, "attempt": req.Attempt}, http.StatusOK)
+}
+
+func (svc *Server) deleteQuestion(ctx *gin.Context) {
+	var req deleteRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		SendError(ctx, "error in bind query", err.Error(), http.StatusBadRequest)
+		return
+	}
+	id := ctx.Param("questionID")
+	if err := svc.store.DeleteQuestion(id, memdb.DeleteOptions{Soft: req.Soft, Cascade: req.Cascade}); err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	recordAudit(ctx, svc.store, &models.AuditEvent{Action: models.AuditQuestionDeleted, Target: id, Detail: fmt.Sprintf("soft=%t cascade=%t", req.Soft, req.Cascade)})
+	SendSuccess(ctx, "question deleted", gin.H{"id": id, "soft": req.Soft, "cascade": req.Cascade}, http.StatusOK)
+}
+
+func (svc *Server) restoreQuestion(ctx *gin.Context) {
+	id := ctx.Param("questionID")
+	if err := svc.store.RestoreQuestion(id); err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	recordAudit(ctx, svc.store, &models.AuditEvent{Action: models.AuditQuestionRestored, Target: id})
+	SendSuccess(ctx, "question restored", gin.H{"id": id}, http.StatusOK)
+}
+
+func (svc *Server) reloadQuestionBank(ctx *gin.Context) {
+	report, err := svc.store.ReloadQuestionBank(svc.config.DataDir)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, memdb.ErrInvalidQuestionBank):
+			status = http.StatusUnprocessableEntity
+		case errors.Is(err, memdb.ErrQuestionBankStale):
+			status = http.StatusConflict
+		}
+		SendError(ctx, "question bank rejected", err.Error(), status)
+		return
+	}
+	recordAudit(ctx, svc.store, &models.AuditEvent{Action: models.AuditBankReloaded, Target: svc.config.DataDir})
+	SendSuccess(ctx, "question bank reloaded", report, http.StatusOK)
+}
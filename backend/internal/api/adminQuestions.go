@@ -0,0 +1,208 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/matheuspolitano/quiz-go/backend/internal/memdb"
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+type listRequest struct {
+	Deleted bool   `form:"deleted"`
+	Limit   int    `form:"limit" binding:"min=0,max=500"`
+	Cursor  string `form:"cursor"`
+}
+
+// questionRequest leaves the options and the answer to the grader of the question kind
+type questionRequest struct {
+	ID            string   `json:"id"`
+	Kind          string   `json:"kind"`
+	Prompt        string   `json:"prompt" binding:"required"`
+	Options       []string `json:"options"`
+	Answer        string   `json:"answer" binding:"required"`
+	PartialCredit bool     `json:"partial_credit"`
+	Tolerance     float64  `json:"tolerance"`
+	Accepted      []string `json:"accepted"`
+	Tags          []string `json:"tags"`
+	Difficulty    string   `json:"difficulty"`
+}
+
+type typeQuizRequest struct {
+	Name        string               `json:"name"`
+	QuestionsID []string             `json:"questions_id" binding:"required_without=Pool"`
+	MaxAttempts int                  `json:"max_attempts" binding:"min=0"`
+	Shuffle     bool                 `json:"shuffle"`
+	Pool        *models.QuestionPool `json:"pool"`
+}
+
+func bindList(ctx *gin.Context) (memdb.ListOptions, bool) {
+	var req listRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		SendError(ctx, "error in bind query", err.Error(), http.StatusBadRequest)
+		return memdb.ListOptions{}, false
+	}
+	return memdb.ListOptions{IncludeDeleted: req.Deleted, Limit: req.Limit, Cursor: req.Cursor}, true
+}
+
+func (svc *Server) listQuestions(ctx *gin.Context) {
+	opts, ok := bindList(ctx)
+	if !ok {
+		return
+	}
+	page, err := svc.store.ListQuestions(opts)
+	if err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	SendSuccess(ctx, "", page, http.StatusOK)
+}
+
+func (svc *Server) getAdminQuestion(ctx *gin.Context) {
+	question, err := svc.store.GetQuestion(ctx.Param("questionID"))
+	if err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	SendSuccess(ctx, "", question, http.StatusOK)
+}
+
+func (svc *Server) createQuestion(ctx *gin.Context) {
+	var req questionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		SendError(ctx, "error in bind body", err.Error(), http.StatusBadRequest)
+		return
+	}
+	question, err := svc.store.CreateQuestion(&models.Question{
+		ID:            req.ID,
+		Kind:          req.Kind,
+		Prompt:        req.Prompt,
+		Options:       req.Options,
+		Answer:        req.Answer,
+		PartialCredit: req.PartialCredit,
+		Tolerance:     req.Tolerance,
+		Accepted:      req.Accepted,
+		Tags:          req.Tags,
+		Difficulty:    req.Difficulty,
+	})
+	if err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	recordAudit(ctx, svc.store, &models.AuditEvent{Action: models.AuditQuestionCreated, Target: question.ID})
+	SendSuccess(ctx, "question created", question, http.StatusCreated)
+}
+
+func (svc *Server) updateQuestion(ctx *gin.Context) {
+	var req questionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		SendError(ctx, "error in bind body", err.Error(), http.StatusBadRequest)
+		return
+	}
+	id := ctx.Param("questionID")
+	if req.ID != "" && req.ID != id {
+		SendError(ctx, "", "question id cannot be changed", http.StatusBadRequest)
+		return
+	}
+	question, err := svc.store.UpdateQuestion(&models.Question{
+		ID:            id,
+		Kind:          req.Kind,
+		Prompt:        req.Prompt,
+		Options:       req.Options,
+		Answer:        req.Answer,
+		PartialCredit: req.PartialCredit,
+		Tolerance:     req.Tolerance,
+		Accepted:      req.Accepted,
+		Tags:          req.Tags,
+		Difficulty:    req.Difficulty,
+	})
+	if err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	recordAudit(ctx, svc.store, &models.AuditEvent{Action: models.AuditQuestionUpdated, Target: question.ID})
+	SendSuccess(ctx, "question updated", question, http.StatusOK)
+}
+
+func (svc *Server) listTypeQuizzes(ctx *gin.Context) {
+	opts, ok := bindList(ctx)
+	if !ok {
+		return
+	}
+	page, err := svc.store.ListTypeQuizzes(opts)
+	if err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	SendSuccess(ctx, "", page, http.StatusOK)
+}
+
+func (svc *Server) getTypeQuiz(ctx *gin.Context) {
+	typeQuiz, err := svc.store.GetTypeQuiz(ctx.Param("typeQuiz"))
+	if err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	SendSuccess(ctx, "", typeQuiz, http.StatusOK)
+}
+
+func (svc *Server) createTypeQuiz(ctx *gin.Context) {
+	var req typeQuizRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		SendError(ctx, "error in bind body", err.Error(), http.StatusBadRequest)
+		return
+	}
+	typeQuiz, err := svc.store.CreateTypeQuiz(&models.TypeQuiz{Name: req.Name, QuestionsID: req.QuestionsID, MaxAttempts: req.MaxAttempts, Shuffle: req.Shuffle, Pool: req.Pool})
+	if err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	recordAudit(ctx, svc.store, &models.AuditEvent{Action: models.AuditTypeQuizCreated, Target: typeQuiz.Name})
+	SendSuccess(ctx, "quiz type created", typeQuiz, http.StatusCreated)
+}
+
+func (svc *Server) updateTypeQuiz(ctx *gin.Context) {
+	var req typeQuizRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		SendError(ctx, "error in bind body", err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := ctx.Param("typeQuiz")
+	if req.Name != "" && req.Name != name {
+		SendError(ctx, "", "quiz type name cannot be changed", http.StatusBadRequest)
+		return
+	}
+	typeQuiz, err := svc.store.UpdateTypeQuiz(&models.TypeQuiz{Name: name, QuestionsID: req.QuestionsID, MaxAttempts: req.MaxAttempts, Shuffle: req.Shuffle, Pool: req.Pool})
+	if err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	recordAudit(ctx, svc.store, &models.AuditEvent{Action: models.AuditTypeQuizUpdated, Target: typeQuiz.Name})
+	SendSuccess(ctx, "quiz type updated", typeQuiz, http.StatusOK)
+}
+
+func (svc *Server) deleteTypeQuiz(ctx *gin.Context) {
+	var req deleteRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		SendError(ctx, "error in bind query", err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := ctx.Param("typeQuiz")
+	if err := svc.store.DeleteTypeQuiz(name, memdb.DeleteOptions{Soft: req.Soft}); err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	recordAudit(ctx, svc.store, &models.AuditEvent{Action: models.AuditTypeQuizDeleted, Target: name, Detail: fmt.Sprintf("soft=%t", req.Soft)})
+	SendSuccess(ctx, "quiz type deleted", gin.H{"name": name, "soft": req.Soft}, http.StatusOK)
+}
+
+func (svc *Server) restoreTypeQuiz(ctx *gin.Context) {
+	name := ctx.Param("typeQuiz")
+	if err := svc.store.RestoreTypeQuiz(name); err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	recordAudit(ctx, svc.store, &models.AuditEvent{Action: models.AuditTypeQuizRestored, Target: name})
+	SendSuccess(ctx, "quiz type restored", gin.H{"name": name}, http.StatusOK)
+}
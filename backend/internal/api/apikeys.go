@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+	"github.com/matheuspolitano/quiz-go/backend/internal/token"
+	"github.com/matheuspolitano/quiz-go/backend/internal/utils"
+)
+
+type createAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// apiKeyResponse is the public view of an API key, without the hash of its secret
+type apiKeyResponse struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	CreatedBy string     `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	// Key is only set in the response of the creation, it cannot be read again
+	Key string `json:"key,omitempty"`
+}
+
+// apiKeyPage mirrors memdb.Page with the public view of the keys
+type apiKeyPage struct {
+	Items      []apiKeyResponse `json:"items"`
+	Total      int              `json:"total"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+func newAPIKeyResponse(key *models.APIKey) apiKeyResponse {
+	return apiKeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		Scopes:    key.Scopes,
+		CreatedBy: key.CreatedBy,
+		CreatedAt: key.CreatedAt,
+		ExpiresAt: key.ExpiresAt,
+		RevokedAt: key.DeletedAt,
+	}
+}
+
+func (svc *Server) createAPIKey(ctx *gin.Context) {
+	var req createAPIKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		SendError(ctx, "error in bind body", err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		SendError(ctx, "", "expires_at is in the past", http.StatusUnprocessableEntity)
+		return
+	}
+
+	// the ID goes in the key, hex keeps it free of the underscore separating the secret
+	id := uuid.New()
+	secretKey, secretHash, err := utils.NewAPIKey(hex.EncodeToString(id[:]))
+	if err != nil {
+		SendError(ctx, "", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	key, err := svc.store.CreateAPIKey(&models.APIKey{
+		ID:         hex.EncodeToString(id[:]),
+		Name:       req.Name,
+		Scopes:     req.Scopes,
+		SecretHash: secretHash,
+		CreatedBy:  authPayload.Username,
+		ExpiresAt:  req.ExpiresAt,
+	})
+	if err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+
+	recordAudit(ctx, svc.store, &models.AuditEvent{Action: models.AuditAPIKeyCreated, Target: key.ID, Detail: key.Name + " " + strings.Join(key.Scopes, ",")})
+	response := newAPIKeyResponse(key)
+	response.Key = secretKey
+	SendSuccess(ctx, "API key created, store it now, it cannot be shown again", response, http.StatusCreated)
+}
+
+func (svc *Server) listAPIKeys(ctx *gin.Context) {
+	opts, ok := bindList(ctx)
+	if !ok {
+		return
+	}
+	page, err := svc.store.ListAPIKeys(opts)
+	if err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	response := apiKeyPage{Items: make([]apiKeyResponse, 0, len(page.Items)), Total: page.Total, NextCursor: page.NextCursor}
+	for _, key := range page.Items {
+		response.Items = append(response.Items, newAPIKeyResponse(key))
+	}
+	SendSuccess(ctx, "", response, http.StatusOK)
+}
+
+func (svc *Server) revokeAPIKey(ctx *gin.Context) {
+	keyID := ctx.Param("keyID")
+	if err := svc.store.RevokeAPIKey(keyID); err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	recordAudit(ctx, svc.store, &models.AuditEvent{Action: models.AuditAPIKeyRevoked, Target: keyID})
+	SendSuccess(ctx, "API key revoked", gin.H{"id": keyID}, http.StatusOK)
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/matheuspolitano/quiz-go/backend/internal/memdb"
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+	"github.com/matheuspolitano/quiz-go/backend/internal/token"
+)
+
+type auditListRequest struct {
+	listRequest
+	Actor   string    `form:"actor"`
+	Action  string    `form:"action"`
+	Target  string    `form:"target"`
+	Outcome string    `form:"outcome" binding:"omitempty,oneof=success failure"`
+	Since   time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until   time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// recordAudit appends event to the audit log with the client IP and the request ID.
+// The actor defaults to the authenticated user of the request.
+// A failure to write the event does not fail the request, it is reported to the logger.
+func recordAudit(ctx *gin.Context, store memdb.Store, event *models.AuditEvent) {
+	if event.Actor == "" {
+		if payload, ok := ctx.Get(authorizationPayloadKey); ok {
+			event.Actor = payload.(*token.Payload).Username
+		}
+	}
+	event.ClientIP = ctx.ClientIP()
+	event.RequestID = ctx.GetString(requestIDKey)
+	if err := store.AppendAudit(event); err != nil {
+		_ = ctx.Error(fmt.Errorf("audit %s: %w", event.Action, err))
+	}
+}
+
+func (svc *Server) listAuditEvents(ctx *gin.Context) {
+	var req auditListRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		SendError(ctx, "error in bind query", err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter := memdb.AuditFilter{
+		Actor:   req.Actor,
+		Action:  req.Action,
+		Target:  req.Target,
+		Outcome: req.Outcome,
+		Since:   req.Since,
+		Until:   req.Until,
+	}
+	page, err := svc.store.ListAuditEvents(filter, memdb.ListOptions{Limit: req.Limit, Cursor: req.Cursor})
+	if err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	SendSuccess(ctx, "", page, http.StatusOK)
+}
@@ -3,48 +3,146 @@ package api
 import (
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/matheuspolitano/quiz-go/backend/internal/memdb"
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+	"github.com/matheuspolitano/quiz-go/backend/internal/ratelimit"
 	"github.com/matheuspolitano/quiz-go/backend/internal/token"
+	"github.com/matheuspolitano/quiz-go/backend/internal/utils"
 )
 
 const (
 	authorizationHeaderKey  = "authorization"
 	authorizationTypeBearer = "bearer"
+	authorizationTypeAPIKey = "apikey"
 	authorizationPayloadKey = "authorization_payload"
+	apiKeyHeaderKey         = "x-api-key"
+	requestIDHeaderKey      = "X-Request-ID"
+	requestIDKey            = "request_id"
+	// apiKeyUsernamePrefix names the requests of API keys in the payload, it cannot clash with a user
+	// since usernames cannot contain a colon, see models.ValidUsername
+	apiKeyUsernamePrefix = "apikey:"
 )
 
+var (
+	errRevokedToken  = errors.New("token has been revoked")
+	errInvalidAPIKey = errors.New("invalid API key")
+)
+
+// requestIDMiddleware gives every request an ID, sent back in X-Request-ID and stored in the audit log.
+// An ID sent by the client, e.g. by a proxy, is kept when it looks sane.
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requestID := ctx.GetHeader(requestIDHeaderKey)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		ctx.Set(requestIDKey, requestID)
+		ctx.Header(requestIDHeaderKey, requestID)
+		ctx.Next()
+	}
+}
+
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > 64 {
+		return false
+	}
+	for _, r := range requestID {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
 // AuthMiddleware creates a gin middleware for authorization
-func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
+// Tokens are verified by tokenMaker and then checked against the revocations of store.
+// API keys, sent in the X-API-Key header or with the ApiKey scheme, are only accepted
+// when they hold one of keyScopes, so routes without scopes are closed to them.
+// Refused requests are recorded in the audit log, sampled by failures: a client
+// repeating the same failure is only recorded once per window.
+func authMiddleware(tokenMaker token.Maker, store memdb.Store, failures *ratelimit.Limiter, keyScopes ...string) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		// deny records the refused request in the audit log, actor is the user of the token when it was readable
+		deny := func(status int, err error, actor string) {
+			if record, _ := failures.Allow(ctx.ClientIP() + "|" + actor + "|" + err.Error()); !record {
+				ctx.AbortWithStatusJSON(status, errorResponse(err))
+				return
+			}
+			recordAudit(ctx, store, &models.AuditEvent{
+				Actor:   actor,
+				Action:  models.AuditAuthFailed,
+				Target:  ctx.Request.Method + " " + ctx.FullPath(),
+				Outcome: models.AuditFailure,
+				Detail:  err.Error(),
+			})
+			ctx.AbortWithStatusJSON(status, errorResponse(err))
+		}
+
+		if apiKey, ok := apiKeyOf(ctx); ok {
+			payload, status, err := authenticateAPIKey(store, apiKey, keyScopes)
+			if status == http.StatusInternalServerError {
+				ctx.AbortWithStatusJSON(status, errorResponse(err))
+				return
+			}
+			if err != nil {
+				deny(status, err, "")
+				return
+			}
+			ctx.Set(authorizationPayloadKey, payload)
+			ctx.Next()
+			return
+		}
+
 		authorizationHeader := ctx.GetHeader(authorizationHeaderKey)
 
 		if len(authorizationHeader) == 0 {
 			err := errors.New("authorization header is not provided")
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			deny(http.StatusUnauthorized, err, "")
 			return
 		}
 
 		fields := strings.Fields(authorizationHeader)
 		if len(fields) < 2 {
 			err := errors.New("invalid authorization header format")
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			deny(http.StatusUnauthorized, err, "")
 			return
 		}
 
 		authorizationType := strings.ToLower(fields[0])
 		if authorizationType != authorizationTypeBearer {
-			err := fmt.Errorf("unsupported authorization type %s", authorizationType)
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			// the type is left out of the error, which keys the audit sampling on a bounded set of reasons
+			err := errors.New("unsupported authorization type, use Bearer or ApiKey")
+			deny(http.StatusUnauthorized, err, "")
 			return
 		}
 
 		accessToken := fields[1]
 		payload, err := tokenMaker.VerifyToken(accessToken)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			deny(http.StatusUnauthorized, err, "")
+			return
+		}
+		if payload.IsRefresh() {
+			err := errors.New("a refresh token cannot authorize requests, use it on /api/token/refresh")
+			deny(http.StatusUnauthorized, err, payload.Username)
+			return
+		}
+		revoked, err := store.IsTokenRevoked(payload.ID.String(), payload.Username, payload.IssuedAt)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		if revoked {
+			deny(http.StatusUnauthorized, errRevokedToken, payload.Username)
 			return
 		}
 
@@ -52,3 +150,94 @@ func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
 		ctx.Next()
 	}
 }
+
+// apiKeyOf returns the API key of the request, if it was sent as one
+func apiKeyOf(ctx *gin.Context) (string, bool) {
+	if key := ctx.GetHeader(apiKeyHeaderKey); key != "" {
+		return key, true
+	}
+	fields := strings.Fields(ctx.GetHeader(authorizationHeaderKey))
+	if len(fields) == 2 && strings.ToLower(fields[0]) == authorizationTypeAPIKey {
+		return fields[1], true
+	}
+	return "", false
+}
+
+// authenticateAPIKey checks an API key and builds the payload of its request,
+// it carries the service role and the status to answer with on failure
+func authenticateAPIKey(store memdb.Store, apiKey string, keyScopes []string) (*token.Payload, int, error) {
+	id, secret, err := utils.ParseAPIKey(apiKey)
+	if err != nil {
+		return nil, http.StatusUnauthorized, errInvalidAPIKey
+	}
+	key, err := store.GetAPIKey(id)
+	if errors.Is(err, memdb.ErrNotFound) {
+		return nil, http.StatusUnauthorized, errInvalidAPIKey
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if !utils.CheckAPIKeySecret(key.SecretHash, secret) {
+		return nil, http.StatusUnauthorized, errInvalidAPIKey
+	}
+	if key.Expired(time.Now()) {
+		return nil, http.StatusUnauthorized, errors.New("API key has expired")
+	}
+	if len(keyScopes) == 0 {
+		return nil, http.StatusForbidden, errors.New("API keys are not accepted on this route")
+	}
+	if !key.HasScope(keyScopes...) {
+		return nil, http.StatusForbidden, fmt.Errorf("API key requires one of the scopes: %s", strings.Join(keyScopes, ", "))
+	}
+
+	keyID, err := uuid.Parse(key.ID)
+	if err != nil {
+		return nil, http.StatusUnauthorized, errInvalidAPIKey
+	}
+	payload := &token.Payload{
+		ID:       keyID,
+		Type:     token.TokenTypeAPIKey,
+		Username: apiKeyUsernamePrefix + key.Name,
+		Role:     models.RoleService,
+		IssuedAt: key.CreatedAt,
+	}
+	if key.ExpiresAt != nil {
+		payload.ExpiredAt = *key.ExpiresAt
+	}
+	return payload, 0, nil
+}
+
+// rateLimit takes a token from the bucket of the user, or of the client IP when
+// the request is not authenticated, so it must run after authMiddleware to limit users.
+// Requests over the budget get a 429 with Retry-After.
+func rateLimit(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := "ip:" + ctx.ClientIP()
+		if payload, ok := ctx.Get(authorizationPayloadKey); ok {
+			key = "user:" + payload.(*token.Payload).Username
+		}
+		allowed, retryAfter := limiter.Allow(key)
+		if !allowed {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			ctx.Header("Retry-After", strconv.Itoa(seconds))
+			SendError(ctx, "rate limit exceeded", fmt.Sprintf("too many requests, %s allowed, retry in %d seconds", limiter.Rate(), seconds), http.StatusTooManyRequests)
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// requireRole aborts the requests whose token does not carry one of roles,
+// it must run after authMiddleware
+func requireRole(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		payload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+		if !slices.Contains(roles, payload.Role) {
+			err := fmt.Errorf("role %s is not allowed, requires one of: %s", payload.Role, strings.Join(roles, ", "))
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
+		ctx.Next()
+	}
+}
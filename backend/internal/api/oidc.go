@@ -0,0 +1,222 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/matheuspolitano/quiz-go/backend/internal/config"
+	"github.com/matheuspolitano/quiz-go/backend/internal/memdb"
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+	"github.com/matheuspolitano/quiz-go/backend/internal/oidc"
+)
+
+// pendingLoginLifetime is how long the user has to sign in at the provider
+const pendingLoginLifetime = 10 * time.Minute
+
+var (
+	errUnknownLoginState = errors.New("unknown or expired login state, start again from /api/oidc/login")
+	errUnverifiedEmail   = errors.New("the identity provider did not return a verified email")
+	errMissingClaim      = errors.New("the identity provider did not return the username claim")
+)
+
+// pendingLogin is kept between the redirect to the provider and its callback
+type pendingLogin struct {
+	nonce        string
+	codeVerifier string
+	expiresAt    time.Time
+}
+
+// oidcClient signs users in with an OpenID provider, it is nil when OIDC_ISSUER is not set
+type oidcClient struct {
+	provider      *oidc.Provider
+	usernameClaim string
+
+	mu      sync.Mutex
+	pending map[string]pendingLogin // by state
+}
+
+type deviceTokenRequest struct {
+	DeviceCode string `json:"device_code" binding:"required"`
+}
+
+func newOIDCClient(config config.Config) *oidcClient {
+	return &oidcClient{
+		provider: oidc.NewProvider(oidc.Config{
+			Issuer:       config.OIDCIssuer,
+			ClientID:     config.OIDCClientID,
+			ClientSecret: config.OIDCClientSecret,
+			RedirectURL:  config.OIDCRedirectURL,
+			Scopes:       []string{"profile", "email"},
+		}),
+		usernameClaim: config.OIDCUsernameClaim,
+		pending:       make(map[string]pendingLogin),
+	}
+}
+
+// start records a login and returns its state, expired logins are dropped on the way
+func (client *oidcClient) start(nonce, codeVerifier string) (string, error) {
+	state, err := oidc.RandomToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	for s, pending := range client.pending {
+		if now.After(pending.expiresAt) {
+			delete(client.pending, s)
+		}
+	}
+	client.pending[state] = pendingLogin{nonce: nonce, codeVerifier: codeVerifier, expiresAt: now.Add(pendingLoginLifetime)}
+	return state, nil
+}
+
+// finish consumes the login of state, a state is only accepted once
+func (client *oidcClient) finish(state string) (pendingLogin, bool) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	pending, ok := client.pending[state]
+	delete(client.pending, state)
+	if !ok || time.Now().After(pending.expiresAt) {
+		return pendingLogin{}, false
+	}
+	return pending, true
+}
+
+// username picks the username of a first login from OIDC_USERNAME_CLAIM.
+// It reports whether the claim was verified by the provider, only then may it take over an existing user.
+func (client *oidcClient) username(claims *oidc.Claims) (string, bool, error) {
+	switch client.usernameClaim {
+	case "", "email":
+		if claims.Email == "" || !claims.EmailVerified {
+			return "", false, errUnverifiedEmail
+		}
+		return claims.Email, true, nil
+	case "preferred_username":
+		if claims.PreferredUsername == "" {
+			return "", false, errMissingClaim
+		}
+		return claims.PreferredUsername, false, nil
+	case "sub":
+		return claims.Subject, false, nil
+	default:
+		return "", false, fmt.Errorf("unknown OIDC_USERNAME_CLAIM %q", client.usernameClaim)
+	}
+}
+
+// oidcLogin redirects to the provider with a fresh state, nonce and PKCE verifier
+func (server *Server) oidcLogin(ctx *gin.Context) {
+	nonce, err := oidc.RandomToken()
+	if err != nil {
+		SendError(ctx, "", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	codeVerifier, err := oidc.RandomToken()
+	if err != nil {
+		SendError(ctx, "", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	state, err := server.oidc.start(nonce, codeVerifier)
+	if err != nil {
+		SendError(ctx, "", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	authURL, err := server.oidc.provider.AuthCodeURL(ctx.Request.Context(), state, nonce, oidc.CodeChallenge(codeVerifier), ctx.Query("login_hint"))
+	if err != nil {
+		SendError(ctx, "", err.Error(), http.StatusBadGateway)
+		return
+	}
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// oidcCallback redeems the code sent back by the provider and opens a session like a password login
+func (server *Server) oidcCallback(ctx *gin.Context) {
+	if providerErr := ctx.Query("error"); providerErr != "" {
+		SendError(ctx, "", providerErr, http.StatusUnauthorized)
+		return
+	}
+	pending, ok := server.oidc.finish(ctx.Query("state"))
+	if !ok {
+		SendError(ctx, "", errUnknownLoginState.Error(), http.StatusBadRequest)
+		return
+	}
+	claims, err := server.oidc.provider.Exchange(ctx.Request.Context(), ctx.Query("code"), pending.codeVerifier, pending.nonce)
+	if err != nil {
+		SendError(ctx, "", err.Error(), http.StatusUnauthorized)
+		return
+	}
+	server.signInOIDC(ctx, claims)
+}
+
+// oidcDeviceAuthorization starts a device flow, the CLI shows the user code and polls oidcDeviceToken
+func (server *Server) oidcDeviceAuthorization(ctx *gin.Context) {
+	auth, err := server.oidc.provider.DeviceAuthorization(ctx.Request.Context())
+	if err != nil {
+		SendError(ctx, "", err.Error(), http.StatusBadGateway)
+		return
+	}
+	ctx.JSON(http.StatusOK, auth)
+}
+
+// oidcDeviceToken answers authorization_pending or slow_down until the user approves the device
+func (server *Server) oidcDeviceToken(ctx *gin.Context) {
+	var req deviceTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		SendError(ctx, "error in bind body", err.Error(), http.StatusBadRequest)
+		return
+	}
+	claims, err := server.oidc.provider.PollDeviceToken(ctx.Request.Context(), req.DeviceCode)
+	switch {
+	case errors.Is(err, oidc.ErrAuthorizationPending), errors.Is(err, oidc.ErrSlowDown):
+		SendError(ctx, "", err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		SendError(ctx, "", err.Error(), http.StatusUnauthorized)
+		return
+	}
+	server.signInOIDC(ctx, claims)
+}
+
+// signInOIDC finds or creates the user of the identity and sends our own tokens
+func (server *Server) signInOIDC(ctx *gin.Context, claims *oidc.Claims) {
+	subject := claims.Issuer + "|" + claims.Subject
+	username, verified, err := server.oidc.username(claims)
+	if err != nil {
+		server.oidcFailed(ctx, subject, err)
+		SendError(ctx, "", err.Error(), http.StatusUnauthorized)
+		return
+	}
+	user, err := server.store.FindOrCreateOIDCUser(subject, username, verified)
+	if err != nil {
+		server.oidcFailed(ctx, subject, err)
+	}
+	switch {
+	case errors.Is(err, memdb.ErrIdentityConflict):
+		SendError(ctx, "", err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, memdb.ErrUserDeleted):
+		SendError(ctx, "", err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, memdb.ErrInvalidUsername):
+		SendError(ctx, "", err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		SendError(ctx, "", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	server.sendToken(ctx, user, &models.AuditEvent{Action: models.AuditLogin, Detail: "oidc " + subject})
+}
+
+// oidcFailed records an identity the provider vouched for but that could not sign in
+func (server *Server) oidcFailed(ctx *gin.Context, subject string, err error) {
+	recordAudit(ctx, server.store, &models.AuditEvent{
+		Action:  models.AuditLoginFailed,
+		Target:  subject,
+		Outcome: models.AuditFailure,
+		Detail:  err.Error(),
+	})
+}
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/matheuspolitano/quiz-go/backend/internal/config"
+	"github.com/matheuspolitano/quiz-go/backend/internal/memdb"
+	"github.com/matheuspolitano/quiz-go/backend/internal/oidc"
+	"github.com/matheuspolitano/quiz-go/backend/internal/oidc/mockidp"
+)
+
+// newOIDCTestServer serves the API and its mock identity provider, the issuer is the URL of the test server
+func newOIDCTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	var handler http.Handler
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	store, err := memdb.NewDBManager(memdb.NewMemoryDriver())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	server, err := New(config.Config{
+		TokenSymmetricKeys:   "test=" + strings.Repeat("k", 32),
+		AccessTokenDuration:  15 * time.Minute,
+		RefreshTokenDuration: time.Hour,
+		OIDCIssuer:           ts.URL + "/mock-idp",
+		OIDCClientID:         "quiz",
+		OIDCRedirectURL:      ts.URL + "/api/oidc/callback",
+		OIDCMock:             true,
+	}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler = server.router
+	return ts
+}
+
+// noRedirectClient returns the redirects instead of following them, the test follows each hop
+var noRedirectClient = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// get sends a GET and returns the status, the Location header and the body
+func get(t *testing.T, rawURL string) (int, string, []byte) {
+	t.Helper()
+	resp, err := noRedirectClient.Get(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, resp.Header.Get("Location"), body
+}
+
+// post sends a POST of body, a url.Values is sent as a form and anything else as JSON
+func post(t *testing.T, rawURL string, body any) (int, []byte) {
+	t.Helper()
+	var resp *http.Response
+	var err error
+	if form, ok := body.(url.Values); ok {
+		resp, err = noRedirectClient.PostForm(rawURL, form)
+	} else {
+		data, _ := json.Marshal(body)
+		resp, err = noRedirectClient.Post(rawURL, "application/json", bytes.NewReader(data))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, data
+}
+
+// authorize starts a login and returns the authorization URL of the provider
+func authorize(t *testing.T, ts *httptest.Server, loginHint string) *url.URL {
+	t.Helper()
+	status, location, body := get(t, ts.URL+"/api/oidc/login?login_hint="+url.QueryEscape(loginHint))
+	if status != http.StatusFound {
+		t.Fatalf("login: status %d, %s", status, body)
+	}
+	authURL, err := url.Parse(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authURL
+}
+
+// callbackURL follows the authorization URL to the provider and returns where it sends the user back
+func callbackURL(t *testing.T, authURL *url.URL) string {
+	t.Helper()
+	status, location, body := get(t, authURL.String())
+	if status != http.StatusFound {
+		t.Fatalf("authorize: status %d, %s", status, body)
+	}
+	return location
+}
+
+func TestOIDCCodeFlow(t *testing.T) {
+	ts := newOIDCTestServer(t)
+
+	callback := callbackURL(t, authorize(t, ts, "alice"))
+	status, _, body := get(t, callback)
+	if status != http.StatusCreated {
+		t.Fatalf("callback: status %d, %s", status, body)
+	}
+	var tokens loginUserResponse
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		t.Fatal(err)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		t.Errorf("callback: no tokens in %s", body)
+	}
+
+	// the state is consumed by the first callback
+	status, _, body = get(t, callback)
+	if status != http.StatusBadRequest || !strings.Contains(string(body), errUnknownLoginState.Error()) {
+		t.Errorf("replayed callback: status %d, %s", status, body)
+	}
+}
+
+func TestOIDCCodeFlowNonceMismatch(t *testing.T) {
+	ts := newOIDCTestServer(t)
+
+	// the provider signs the nonce it was given, which is not the one of the login
+	authURL := authorize(t, ts, "alice")
+	query := authURL.Query()
+	query.Set("nonce", "another nonce")
+	authURL.RawQuery = query.Encode()
+
+	status, _, body := get(t, callbackURL(t, authURL))
+	if status != http.StatusUnauthorized || !strings.Contains(string(body), "nonce does not match") {
+		t.Errorf("callback: status %d, %s", status, body)
+	}
+}
+
+func TestOIDCDeviceFlow(t *testing.T) {
+	ts := newOIDCTestServer(t)
+
+	status, body := post(t, ts.URL+"/api/oidc/device", nil)
+	if status != http.StatusOK {
+		t.Fatalf("device authorization: status %d, %s", status, body)
+	}
+	var auth oidc.DeviceAuthorization
+	if err := json.Unmarshal(body, &auth); err != nil {
+		t.Fatal(err)
+	}
+	poll := map[string]string{"device_code": auth.DeviceCode}
+
+	status, body = post(t, ts.URL+"/api/oidc/device/token", poll)
+	if status != http.StatusBadRequest || !strings.Contains(string(body), oidc.ErrAuthorizationPending.Error()) {
+		t.Fatalf("poll before approval: status %d, %s", status, body)
+	}
+
+	status, body = post(t, auth.VerificationURI, url.Values{"user_code": {auth.UserCode}, "login_hint": {"bob"}})
+	if status != http.StatusOK {
+		t.Fatalf("approval: status %d, %s", status, body)
+	}
+	status, body = post(t, ts.URL+"/api/oidc/device/token", poll)
+	if status != http.StatusCreated {
+		t.Fatalf("poll after approval: status %d, %s", status, body)
+	}
+
+	// the device code is redeemed once
+	status, body = post(t, ts.URL+"/api/oidc/device/token", poll)
+	if status != http.StatusUnauthorized {
+		t.Errorf("poll after sign in: status %d, %s", status, body)
+	}
+}
+
+func TestOIDCWrongAudience(t *testing.T) {
+	ts := newOIDCTestServer(t)
+	issuer := ts.URL + "/mock-idp"
+
+	// an ID token issued to the quiz client, through the device flow of the provider
+	status, body := post(t, issuer+"/device_authorization", url.Values{"client_id": {"quiz"}})
+	if status != http.StatusOK {
+		t.Fatalf("device authorization: status %d, %s", status, body)
+	}
+	var auth oidc.DeviceAuthorization
+	if err := json.Unmarshal(body, &auth); err != nil {
+		t.Fatal(err)
+	}
+	post(t, auth.VerificationURI, url.Values{"user_code": {auth.UserCode}, "login_hint": {"carol"}})
+	status, body = post(t, issuer+"/token", url.Values{
+		"client_id":   {"quiz"},
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		"device_code": {auth.DeviceCode},
+	})
+	if status != http.StatusOK {
+		t.Fatalf("token: status %d, %s", status, body)
+	}
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	claims, err := oidc.NewProvider(oidc.Config{Issuer: issuer, ClientID: "quiz"}).VerifyIDToken(ctx, tokens.IDToken)
+	if err != nil {
+		t.Fatalf("ID token of the quiz client: %v", err)
+	}
+	if claims.Subject != mockidp.Subject("carol") {
+		t.Errorf("subject %q, want %q", claims.Subject, mockidp.Subject("carol"))
+	}
+	_, err = oidc.NewProvider(oidc.Config{Issuer: issuer, ClientID: "other"}).VerifyIDToken(ctx, tokens.IDToken)
+	if !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("ID token of another client: got %v, want %v", err, oidc.ErrInvalidIDToken)
+	}
+}
@@ -6,18 +6,41 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/matheuspolitano/quiz-go/backend/internal/models"
 	"github.com/matheuspolitano/quiz-go/backend/internal/token"
-	"github.com/matheuspolitano/quiz-go/backend/internal/utils"
 )
 
 type userAnswerRequest struct {
 	Answer string `json:"answer" binding:"required"`
 }
 
+// questionResponse is the player view of a question, the answer is left out until it is revealed
+type questionResponse struct {
+	ID      string   `json:"id"`
+	Kind    string   `json:"kind"`
+	Prompt  string   `json:"prompt"`
+	Options []string `json:"options,omitempty"`
+	Answer  string   `json:"answer,omitempty"`
+}
+
+func newQuestionResponse(question *models.Question, revealAnswer bool) questionResponse {
+	response := questionResponse{ID: question.ID, Kind: question.GetKind(), Prompt: question.Prompt, Options: question.Options}
+	if revealAnswer {
+		response.Answer = question.Answer
+	}
+	return response
+}
+
+// generalScore reports the latest attempt as user_quiz, best and average only count closed attempts
 type generalScore struct {
 	UserQuiz             *models.QuestionFlow `json:"user_quiz"`
+	BestAttempt          *models.QuestionFlow `json:"best_attempt,omitempty"`
+	Attempts             int                  `json:"attempts"`
+	ClosedAttempts       int                  `json:"closed_attempts"`
+	AverageAccuracy      float32              `json:"average_accuracy"`
 	GeneralAccuracyRates float32              `json:"general_accuracy_rates"`
 }
 
+// getQuestion returns the full record to authors and admins, players get the answer
+// only once they answered the question in a closed flow and no open attempt asks it again
 func (svc *Server) getQuestion(ctx *gin.Context) {
 	id := ctx.Param("questionID")
 	question, err := svc.store.GetQuestion(id)
@@ -25,9 +48,21 @@ func (svc *Server) getQuestion(ctx *gin.Context) {
 		SendError(ctx, "", err.Error(), http.StatusNotFound)
 		return
 	}
-	ctx.JSON(http.StatusAccepted, question)
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	switch authPayload.Role {
+	case models.RoleAuthor, models.RoleAdmin, models.RoleService:
+		ctx.JSON(http.StatusAccepted, question)
+		return
+	}
+	revealed, err := svc.store.AnswerRevealed(authPayload.Username, id)
+	if err != nil {
+		SendError(ctx, "", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ctx.JSON(http.StatusAccepted, newQuestionResponse(question, revealed))
 }
 
+// joinQuiz returns the latest attempt at the quiz type, starting the first one when there is none
 func (svc *Server) joinQuiz(ctx *gin.Context) {
 	typeQuiz := ctx.Param("typeQuiz")
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
@@ -38,28 +73,62 @@ func (svc *Server) joinQuiz(ctx *gin.Context) {
 	}
 	ctx.JSON(http.StatusAccepted, questionFlow)
 }
+
+// startAttempt opens a new attempt once the previous one is closed
+func (svc *Server) startAttempt(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	questionFlow, err := svc.store.StartAttempt(authPayload.Username, ctx.Param("typeQuiz"))
+	if err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	ctx.JSON(http.StatusCreated, questionFlow)
+}
+
+// listAttempts returns the attempts of the user, at one quiz type or at all of them
+func (svc *Server) listAttempts(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	attempts, err := svc.store.ListAttempts(authPayload.Username, ctx.Param("typeQuiz"))
+	if err != nil {
+		SendError(ctx, "", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ctx.JSON(http.StatusOK, attempts)
+}
+
+// nextQuestion serves the next question of the latest attempt
 func (svc *Server) nextQuestion(ctx *gin.Context) {
 	typeQuiz := ctx.Param("typeQuiz")
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
-	question, err := svc.store.NextQuestion(utils.CombineIDs(authPayload.Username, typeQuiz))
+	attempt, err := svc.store.CurrentAttempt(authPayload.Username, typeQuiz)
+	if err != nil {
+		SendError(ctx, "", err.Error(), http.StatusNotFound)
+		return
+	}
+	question, err := svc.store.NextQuestion(attempt.GetID())
 	if err != nil {
 		SendError(ctx, "", err.Error(), http.StatusNotFound)
 		return
 	}
-	ctx.JSON(http.StatusOK, question)
+	// the answer of the next question is only sent back by answerQuestion
+	ctx.JSON(http.StatusOK, newQuestionResponse(question, false))
 }
 
 func (svc *Server) generalScore(ctx *gin.Context) {
 	typeQuiz := ctx.Param("typeQuiz")
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
-	question, generalAccuracyRates, err := svc.store.GetScoreUser(authPayload.Username, typeQuiz)
+	score, err := svc.store.GetScoreUser(authPayload.Username, typeQuiz)
 	if err != nil {
 		SendError(ctx, "", err.Error(), http.StatusNotFound)
 		return
 	}
 	ctx.JSON(http.StatusAccepted, &generalScore{
-		UserQuiz:             question,
-		GeneralAccuracyRates: generalAccuracyRates,
+		UserQuiz:             score.Latest,
+		BestAttempt:          score.Best,
+		Attempts:             score.Attempts,
+		ClosedAttempts:       score.Closed,
+		AverageAccuracy:      score.AverageAccuracy,
+		GeneralAccuracyRates: score.GeneralAccuracy,
 	})
 }
 
@@ -74,11 +143,26 @@ func (svc *Server) answerQuestion(ctx *gin.Context) {
 	}
 
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	attempt, err := svc.store.CurrentAttempt(authPayload.Username, id)
+	if err != nil {
+		SendError(ctx, "", err.Error(), http.StatusNotFound)
+		return
+	}
 
-	history, err := svc.store.AddAnswer(utils.CombineIDs(authPayload.Username, id), questionID, req.Answer)
+	history, err := svc.store.AddAnswer(attempt.GetID(), questionID, req.Answer)
 	if err != nil {
 		SendError(ctx, "", err.Error(), http.StatusNotFound)
 		return
 	}
+	// the history is kept on the stored options, the player is answered on the options they were shown
+	if attempt.Seed != 0 {
+		question, err := svc.store.GetQuestion(questionID)
+		if err != nil {
+			SendError(ctx, "", err.Error(), http.StatusInternalServerError)
+			return
+		}
+		history.Answer = attempt.ShownAnswer(question, history.Answer)
+		history.ExpectedAnswer = attempt.ShownAnswer(question, history.ExpectedAnswer)
+	}
 	ctx.JSON(http.StatusAccepted, history)
 }
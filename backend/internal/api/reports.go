@@ -0,0 +1,16 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (svc *Server) typeQuizReport(ctx *gin.Context) {
+	report, err := svc.store.GetTypeQuizReport(ctx.Param("typeQuiz"))
+	if err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	SendSuccess(ctx, "", report, http.StatusOK)
+}
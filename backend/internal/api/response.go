@@ -22,6 +22,16 @@ func SendError(c *gin.Context, message string, errDetail string, statusCode int)
 	c.JSON(statusCode, response)
 }
 
+// SendSuccess sends a JSON success response
+func SendSuccess(c *gin.Context, message string, data interface{}, statusCode int) {
+	response := Response{
+		Status:  "success",
+		Message: message,
+		Data:    data,
+	}
+	c.JSON(statusCode, response)
+}
+
 func errorResponse(err error) gin.H {
 	return gin.H{"error": err.Error()}
 }
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+	"github.com/matheuspolitano/quiz-go/backend/internal/token"
+)
+
+// revokeTokenRequest names the token either by the token itself or by its ID,
+// the ID alone needs the username it belongs to
+type revokeTokenRequest struct {
+	Token    string `json:"token"`
+	TokenID  string `json:"token_id"`
+	Username string `json:"username"`
+	Reason   string `json:"reason"`
+}
+
+type revokeUserTokensRequest struct {
+	// IssuedBefore defaults to now, revoking every token issued so far
+	IssuedBefore *time.Time `json:"issued_before"`
+	Reason       string     `json:"reason"`
+}
+
+// maxTokenLifetime is the longest any token lives, a revocation is kept that long
+func (svc *Server) maxTokenLifetime() time.Duration {
+	return max(svc.config.AccessTokenDuration, svc.config.RefreshTokenDuration)
+}
+
+func (svc *Server) revokeToken(ctx *gin.Context) {
+	var req revokeTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		SendError(ctx, "error in bind body", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tokenID, username := req.TokenID, req.Username
+	expiresAt := time.Now().Add(svc.maxTokenLifetime())
+	switch {
+	case req.Token != "":
+		payload, err := svc.tokenMaker.VerifyToken(req.Token)
+		if errors.Is(err, token.ErrExpiredToken) {
+			SendSuccess(ctx, "token has already expired, nothing to revoke", nil, http.StatusOK)
+			return
+		}
+		if err != nil {
+			SendError(ctx, "", err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		tokenID, username, expiresAt = payload.ID.String(), payload.Username, payload.ExpiredAt
+	case req.TokenID == "" || req.Username == "":
+		SendError(ctx, "", "either token or token_id and username are required", http.StatusBadRequest)
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	revocation, err := svc.store.RevokeToken(tokenID, username, req.Reason, authPayload.Username, expiresAt)
+	if err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	recordAudit(ctx, svc.store, &models.AuditEvent{Action: models.AuditTokensRevoked, Target: username, Detail: "token " + tokenID})
+	SendSuccess(ctx, "token revoked", revocation, http.StatusOK)
+}
+
+func (svc *Server) revokeUserTokens(ctx *gin.Context) {
+	var req revokeUserTokensRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		SendError(ctx, "error in bind body", err.Error(), http.StatusBadRequest)
+		return
+	}
+	issuedBefore := time.Now()
+	if req.IssuedBefore != nil {
+		issuedBefore = *req.IssuedBefore
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	revocation, err := svc.store.RevokeUserTokens(ctx.Param("username"), issuedBefore, svc.maxTokenLifetime(), req.Reason, authPayload.Username)
+	if err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	recordAudit(ctx, svc.store, &models.AuditEvent{Action: models.AuditTokensRevoked, Target: revocation.Username, Detail: "issued before " + revocation.IssuedBefore.Format(time.RFC3339)})
+	SendSuccess(ctx, "tokens revoked", revocation, http.StatusOK)
+}
+
+func (svc *Server) listRevocations(ctx *gin.Context) {
+	opts, ok := bindList(ctx)
+	if !ok {
+		return
+	}
+	page, err := svc.store.ListRevocations(opts)
+	if err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	SendSuccess(ctx, "", page, http.StatusOK)
+}
@@ -4,12 +4,16 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/matheuspolitano/quiz-go/backend/internal/config"
 	"github.com/matheuspolitano/quiz-go/backend/internal/memdb"
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+	"github.com/matheuspolitano/quiz-go/backend/internal/oidc/mockidp"
+	"github.com/matheuspolitano/quiz-go/backend/internal/ratelimit"
 	"github.com/matheuspolitano/quiz-go/backend/internal/token"
 	"go.uber.org/zap"
 )
@@ -19,31 +23,228 @@ type Server struct {
 	router     *gin.Engine
 	config     config.Config
 	httpSvc    *http.Server
-	store      *memdb.DBManager
+	store      memdb.Store
 	tokenMaker token.Maker
+	oidc       *oidcClient
+	mockIdP    *mockidp.Server
+	limits     rateLimits
 }
 
+// rateLimits are the separate budgets of the route groups
+type rateLimits struct {
+	auth  *ratelimit.Limiter
+	quiz  *ratelimit.Limiter
+	admin *ratelimit.Limiter
+	ip    *ratelimit.Limiter
+	// authFailures samples the failed authentications recorded in the audit log
+	authFailures *ratelimit.Limiter
+}
+
+// authFailureAuditRate records one failed authentication per client IP, user and reason a minute,
+// the audit log would otherwise grow with every request sent without valid credentials
+var authFailureAuditRate = ratelimit.Rate{Requests: 1, Per: time.Minute}
+
 // New create new server
-func New(config config.Config, store *memdb.DBManager) (*Server, error) {
+func New(config config.Config, store memdb.Store) (*Server, error) {
 	logger, err := zap.NewProduction()
 	if err != nil {
 		return nil, err
 	}
-	router := initializeGinEngine(logger)
-	svc := &Server{router: router, config: config, tokenMaker: &token.JWTMaker{}, store: store}
+	tokenMaker, err := newTokenMaker(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := token.SelfCheck(tokenMaker); err != nil {
+		return nil, err
+	}
+	limits, err := newRateLimits(config)
+	if err != nil {
+		return nil, err
+	}
+	router, err := initializeGinEngine(logger, config)
+	if err != nil {
+		return nil, err
+	}
+	svc := &Server{router: router, config: config, tokenMaker: tokenMaker, store: store, limits: limits}
+	if err := svc.setupOIDC(); err != nil {
+		return nil, err
+	}
 	return svc.WithRoutes().WithServer(), nil
 }
 
+// newRateLimits builds the limiters of RATE_LIMIT_AUTH, RATE_LIMIT_QUIZ and RATE_LIMIT_ADMIN
+func newRateLimits(config config.Config) (rateLimits, error) {
+	var limits rateLimits
+	for _, limit := range []struct {
+		name    string
+		rate    string
+		limiter **ratelimit.Limiter
+	}{
+		{"RATE_LIMIT_AUTH", config.RateLimitAuth, &limits.auth},
+		{"RATE_LIMIT_QUIZ", config.RateLimitQuiz, &limits.quiz},
+		{"RATE_LIMIT_ADMIN", config.RateLimitAdmin, &limits.admin},
+		{"RATE_LIMIT_IP", config.RateLimitIP, &limits.ip},
+	} {
+		rate, err := ratelimit.ParseRate(limit.rate)
+		if err != nil {
+			return rateLimits{}, fmt.Errorf("%s: %w", limit.name, err)
+		}
+		*limit.limiter = ratelimit.NewLimiter(rate)
+	}
+	limits.authFailures = ratelimit.NewLimiter(authFailureAuditRate)
+	return limits, nil
+}
+
+// setupOIDC enables the OpenID Connect login when an issuer is configured.
+// With OIDC_MOCK the mock provider is served by this server and the
+// missing settings default to it, so the login works out of the box in development.
+func (svc *Server) setupOIDC() error {
+	if svc.config.OIDCMock {
+		baseURL := fmt.Sprintf("http://localhost:%s", svc.config.ApiPort)
+		if svc.config.OIDCIssuer == "" {
+			svc.config.OIDCIssuer = baseURL + "/mock-idp"
+		}
+		if svc.config.OIDCClientID == "" {
+			svc.config.OIDCClientID = "quiz"
+		}
+		if svc.config.OIDCRedirectURL == "" {
+			svc.config.OIDCRedirectURL = baseURL + "/api/oidc/callback"
+		}
+		if !strings.HasSuffix(strings.TrimSuffix(svc.config.OIDCIssuer, "/"), "/mock-idp") {
+			return fmt.Errorf("OIDC_MOCK: OIDC_ISSUER must be left empty or end with /mock-idp")
+		}
+		mock, err := mockidp.New(svc.config.OIDCIssuer, svc.config.OIDCClientID)
+		if err != nil {
+			return fmt.Errorf("OIDC_MOCK: %w", err)
+		}
+		svc.mockIdP = mock
+	}
+	if svc.config.OIDCIssuer == "" {
+		return nil
+	}
+	if svc.config.OIDCClientID == "" || svc.config.OIDCRedirectURL == "" {
+		return fmt.Errorf("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required with OIDC_ISSUER")
+	}
+	svc.oidc = newOIDCClient(svc.config)
+	return nil
+}
+
+// newTokenMaker builds the token maker selected by TOKEN_MAKER from the keys of the config
+func newTokenMaker(config config.Config) (token.Maker, error) {
+	switch config.TokenMaker {
+	case "", "hmac":
+		keys, err := token.ParseKeys(config.TokenSymmetricKeys)
+		if err != nil {
+			return nil, fmt.Errorf("TOKEN_SYMMETRIC_KEYS: %w", err)
+		}
+		activeKID, err := activeKeyID(config, keys)
+		if err != nil {
+			return nil, err
+		}
+		return token.NewJWTMakerWithKeys(keys, activeKID)
+	case "paseto-local":
+		keys, err := token.ParseKeys(config.TokenSymmetricKeys)
+		if err != nil {
+			return nil, fmt.Errorf("TOKEN_SYMMETRIC_KEYS: %w", err)
+		}
+		activeKID, err := activeKeyID(config, keys)
+		if err != nil {
+			return nil, err
+		}
+		return token.NewPasetoLocalMaker(keys, activeKID)
+	case "asymmetric", "paseto-public":
+		privateKeys, publicKeys, activeKID, err := readKeyFiles(config)
+		if err != nil {
+			return nil, err
+		}
+		if config.TokenMaker == "paseto-public" {
+			return token.NewPasetoPublicMaker(privateKeys, publicKeys, activeKID)
+		}
+		return token.NewAsymmetricMaker(privateKeys, publicKeys, activeKID)
+	default:
+		return nil, fmt.Errorf("unknown token maker %q", config.TokenMaker)
+	}
+}
+
+// readKeyFiles reads the PEM keys of TOKEN_PRIVATE_KEY_FILES and TOKEN_PUBLIC_KEY_FILES
+func readKeyFiles(config config.Config) (privateKeys, publicKeys map[string][]byte, activeKID string, err error) {
+	privatePaths, err := token.ParseKeys(config.TokenPrivateKeyFiles)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("TOKEN_PRIVATE_KEY_FILES: %w", err)
+	}
+	if privateKeys, err = token.ReadKeyFiles(privatePaths); err != nil {
+		return nil, nil, "", err
+	}
+	publicKeys = map[string][]byte{}
+	if config.TokenPublicKeyFiles != "" {
+		publicPaths, err := token.ParseKeys(config.TokenPublicKeyFiles)
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("TOKEN_PUBLIC_KEY_FILES: %w", err)
+		}
+		if publicKeys, err = token.ReadKeyFiles(publicPaths); err != nil {
+			return nil, nil, "", err
+		}
+	}
+	activeKID, err = activeKeyID(config, privatePaths)
+	return privateKeys, publicKeys, activeKID, err
+}
+
+// activeKeyID returns TOKEN_ACTIVE_KEY_ID, which may be left empty when there is a single key
+func activeKeyID[T any](config config.Config, keys map[string]T) (string, error) {
+	if config.TokenActiveKeyID != "" {
+		return config.TokenActiveKeyID, nil
+	}
+	if len(keys) > 1 {
+		return "", fmt.Errorf("TOKEN_ACTIVE_KEY_ID is required with several signing keys")
+	}
+	for kid := range keys {
+		return kid, nil
+	}
+	return "", nil
+}
+
 // WithRoutes implement the routes
 func (svc *Server) WithRoutes() *Server {
+	if provider, ok := svc.tokenMaker.(token.KeySetProvider); ok {
+		svc.router.GET("/.well-known/jwks.json", func(ctx *gin.Context) {
+			ctx.Header("Cache-Control", "public, max-age=300")
+			ctx.JSON(http.StatusOK, provider.JWKS())
+		})
+	}
+
 	apiGroup := svc.router.Group("/api")
 	apiGroup.GET("/ping", func(ctx *gin.Context) {
 		ctx.JSON(http.StatusAccepted, gin.H{
 			"message": "pong",
 		})
 	})
-	apiGroup.POST("/login", svc.startUser)
-	authRoutes := apiGroup.Group("/quiz").Use(authMiddleware(svc.tokenMaker))
+	// the login routes are limited per client IP, the other groups per client IP before
+	// authMiddleware, so failed authentications are limited too, and per user after it
+	ipLimit := rateLimit(svc.limits.ip)
+	publicRoutes := apiGroup.Group("", rateLimit(svc.limits.auth))
+	publicRoutes.POST("/register", svc.registerUser)
+	publicRoutes.POST("/login", svc.loginUser)
+	publicRoutes.POST("/token/refresh", svc.refreshToken)
+	publicRoutes.POST("/logout", svc.logout)
+	if svc.oidc != nil {
+		oidcRoutes := publicRoutes.Group("/oidc")
+		oidcRoutes.GET("/login", svc.oidcLogin)
+		oidcRoutes.GET("/callback", svc.oidcCallback)
+		oidcRoutes.POST("/device", svc.oidcDeviceAuthorization)
+		// clients poll the device token every few seconds until the user approves the login,
+		// the login budget would run out first, the polls get the budget of the authenticated routes
+		apiGroup.POST("/oidc/device/token", ipLimit, svc.oidcDeviceToken)
+	}
+	if svc.mockIdP != nil {
+		// the mock serves its endpoints below the path of its issuer
+		svc.router.Any("/mock-idp/*path", gin.WrapH(svc.mockIdP))
+	}
+	accountRoutes := apiGroup.Group("/account", ipLimit, authMiddleware(svc.tokenMaker, svc.store, svc.limits.authFailures), rateLimit(svc.limits.quiz))
+	accountRoutes.PUT("/password", svc.changePassword)
+	accountRoutes.GET("/sessions", svc.listSessions)
+	accountRoutes.DELETE("/sessions", svc.revokeAllSessions)
+	accountRoutes.DELETE("/sessions/:sessionID", svc.revokeSession)
+	authRoutes := apiGroup.Group("/quiz").Use(ipLimit, authMiddleware(svc.tokenMaker, svc.store, svc.limits.authFailures), rateLimit(svc.limits.quiz))
 	authRoutes.GET("/ping", func(ctx *gin.Context) {
 		authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
 		ctx.JSON(http.StatusAccepted, gin.H{
@@ -53,9 +254,55 @@ func (svc *Server) WithRoutes() *Server {
 	authRoutes.GET("/types", svc.listAllTypeQuiz)
 	authRoutes.GET("/question/:questionID", svc.getQuestion)
 	authRoutes.POST("/joinQuiz/:typeQuiz", svc.joinQuiz)
+	authRoutes.GET("/attempts", svc.listAttempts)
+	authRoutes.GET("/attempts/:typeQuiz", svc.listAttempts)
+	authRoutes.POST("/attempts/:typeQuiz", svc.startAttempt)
 	authRoutes.GET("/answer/:typeQuiz/next", svc.nextQuestion)
 	authRoutes.POST("/answer/:typeQuiz/:questionID", svc.answerQuestion)
 	authRoutes.GET("/answer/:typeQuiz/score", svc.generalScore)
+
+	adminGroup := apiGroup.Group("/admin")
+
+	// authoring routes also accept API keys with a questions scope
+	authoringRoles := requireRole(models.RoleAuthor, models.RoleAdmin, models.RoleService)
+	adminLimit := rateLimit(svc.limits.admin)
+	authoringReadRoutes := adminGroup.Group("", ipLimit,
+		authMiddleware(svc.tokenMaker, svc.store, svc.limits.authFailures, models.ScopeQuestionsRead, models.ScopeQuestionsWrite), adminLimit, authoringRoles)
+	authoringReadRoutes.GET("/questions", svc.listQuestions)
+	authoringReadRoutes.GET("/questions/:questionID", svc.getAdminQuestion)
+	authoringReadRoutes.GET("/types", svc.listTypeQuizzes)
+	authoringReadRoutes.GET("/types/:typeQuiz", svc.getTypeQuiz)
+
+	authoringRoutes := adminGroup.Group("", ipLimit, authMiddleware(svc.tokenMaker, svc.store, svc.limits.authFailures, models.ScopeQuestionsWrite), adminLimit, authoringRoles)
+	authoringRoutes.POST("/questions", svc.createQuestion)
+	authoringRoutes.PUT("/questions/:questionID", svc.updateQuestion)
+	authoringRoutes.DELETE("/questions/:questionID", svc.deleteQuestion)
+	authoringRoutes.POST("/questions/:questionID/restore", svc.restoreQuestion)
+	authoringRoutes.POST("/types", svc.createTypeQuiz)
+	authoringRoutes.PUT("/types/:typeQuiz", svc.updateTypeQuiz)
+	authoringRoutes.DELETE("/types/:typeQuiz", svc.deleteTypeQuiz)
+	authoringRoutes.POST("/types/:typeQuiz/restore", svc.restoreTypeQuiz)
+	authoringRoutes.POST("/reload", svc.reloadQuestionBank)
+
+	adminRoutes := adminGroup.Group("", ipLimit, authMiddleware(svc.tokenMaker, svc.store, svc.limits.authFailures), adminLimit, requireRole(models.RoleAdmin))
+	adminRoutes.PUT("/users/:username/role", svc.setUserRole)
+	adminRoutes.PUT("/users/:username/password", svc.resetPassword)
+	adminRoutes.DELETE("/users/:username", svc.deleteUser)
+	adminRoutes.POST("/users/:username/restore", svc.restoreUser)
+	adminRoutes.DELETE("/users/:username/flows/:typeQuiz", svc.deleteQuestionFlow)
+	adminRoutes.POST("/users/:username/flows/:typeQuiz/restore", svc.restoreQuestionFlow)
+	adminRoutes.POST("/users/:username/tokens/revoke", svc.revokeUserTokens)
+	adminRoutes.POST("/tokens/revoke", svc.revokeToken)
+	adminRoutes.GET("/revocations", svc.listRevocations)
+	adminRoutes.POST("/api-keys", svc.createAPIKey)
+	adminRoutes.GET("/api-keys", svc.listAPIKeys)
+	adminRoutes.DELETE("/api-keys/:keyID", svc.revokeAPIKey)
+	adminRoutes.GET("/audit", svc.listAuditEvents)
+
+	reportRoutes := apiGroup.Group("/reports", ipLimit,
+		authMiddleware(svc.tokenMaker, svc.store, svc.limits.authFailures, models.ScopeReportsRead), adminLimit,
+		requireRole(models.RoleInstructor, models.RoleAdmin, models.RoleService))
+	reportRoutes.GET("/types/:typeQuiz", svc.typeQuizReport)
 	return svc
 }
 
@@ -88,20 +335,31 @@ func (svc *Server) Shutdown() error {
 	return svc.httpSvc.Shutdown(ctx)
 }
 
-func initializeGinEngine(logger *zap.Logger) *gin.Engine {
+func initializeGinEngine(logger *zap.Logger, config config.Config) (*gin.Engine, error) {
 	var router *gin.Engine
 
 	router = gin.New()
-	router.Use(ginLogger(logger), gin.Recovery())
+	// ClientIP is the key of the rate limits, X-Forwarded-For is only read from the configured proxies
+	var trustedProxies []string
+	for _, proxy := range strings.Split(config.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		return nil, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+	}
+	router.Use(requestIDMiddleware(), ginLogger(logger), gin.Recovery())
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-API-Key", "X-Request-ID"},
+		ExposeHeaders:    []string{"X-Request-ID"},
 		AllowCredentials: true,
 		MaxAge:           time.Duration(300) * time.Second,
 	}))
 
-	return router
+	return router, nil
 }
 
 func ginLogger(logger *zap.Logger) gin.HandlerFunc {
@@ -127,6 +385,7 @@ func ginLogger(logger *zap.Logger) gin.HandlerFunc {
 				zap.Int("status", c.Writer.Status()),
 				zap.Duration("latency", latency),
 				zap.String("client_ip", c.ClientIP()),
+				zap.String("request_id", c.GetString(requestIDKey)),
 			)
 		}
 	}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/matheuspolitano/quiz-go/backend/internal/memdb"
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+	"github.com/matheuspolitano/quiz-go/backend/internal/token"
+)
+
+var errInvalidRefreshToken = errors.New("refresh token is invalid or its session was revoked")
+
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type refreshTokenResponse struct {
+	AccessToken          string    `json:"access_token"`
+	AccessTokenExpiresAt time.Time `json:"access_token_expires_at"`
+}
+
+// verifyRefreshToken returns the payload of a refresh token, access tokens are refused
+func (server *Server) verifyRefreshToken(ctx *gin.Context) (*token.Payload, bool) {
+	var req refreshTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		SendError(ctx, "error in bind body", err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	payload, err := server.tokenMaker.VerifyToken(req.RefreshToken)
+	if err != nil {
+		SendError(ctx, "", err.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+	if !payload.IsRefresh() {
+		SendError(ctx, "", errInvalidRefreshToken.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+	revoked, err := server.store.IsTokenRevoked(payload.ID.String(), payload.Username, payload.IssuedAt)
+	if err != nil {
+		SendError(ctx, "", err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if revoked {
+		SendError(ctx, "", errRevokedToken.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+	return payload, true
+}
+
+// refreshToken issues a new access token for the session of a refresh token,
+// the role is read again so a role change applies from the next refresh
+func (server *Server) refreshToken(ctx *gin.Context) {
+	refreshPayload, ok := server.verifyRefreshToken(ctx)
+	if !ok {
+		return
+	}
+	if _, err := server.store.RefreshSession(refreshPayload.ID.String(), refreshPayload.Username); err != nil {
+		if errors.Is(err, memdb.ErrNotFound) || errors.Is(err, memdb.ErrSessionExpired) {
+			SendError(ctx, "", errInvalidRefreshToken.Error(), http.StatusUnauthorized)
+			return
+		}
+		SendError(ctx, "", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	user, err := server.store.GetUser(refreshPayload.Username)
+	if err != nil {
+		SendError(ctx, "", errInvalidRefreshToken.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	accessToken, accessPayload, err := server.tokenMaker.CreateToken(user.Username, user.GetRole(), server.config.AccessTokenDuration)
+	if err != nil {
+		SendError(ctx, "", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ctx.JSON(http.StatusOK, &refreshTokenResponse{
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: accessPayload.ExpiredAt,
+	})
+}
+
+// logout revokes the session of a refresh token, its access tokens stay valid until they expire
+func (server *Server) logout(ctx *gin.Context) {
+	refreshPayload, ok := server.verifyRefreshToken(ctx)
+	if !ok {
+		return
+	}
+	err := server.store.DeleteSession(refreshPayload.Username, refreshPayload.ID.String())
+	if err != nil && !errors.Is(err, memdb.ErrNotFound) {
+		SendError(ctx, "", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	recordAudit(ctx, server.store, &models.AuditEvent{Actor: refreshPayload.Username, Action: models.AuditLogout, Target: refreshPayload.ID.String()})
+	SendSuccess(ctx, "logged out", nil, http.StatusOK)
+}
+
+func (server *Server) listSessions(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	sessions, err := server.store.ListSessions(authPayload.Username)
+	if err != nil {
+		SendError(ctx, "", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if sessions == nil {
+		sessions = []*models.Session{}
+	}
+	SendSuccess(ctx, "", sessions, http.StatusOK)
+}
+
+func (server *Server) revokeSession(ctx *gin.Context) {
+	sessionID := ctx.Param("sessionID")
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if err := server.store.DeleteSession(authPayload.Username, sessionID); err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	recordAudit(ctx, server.store, &models.AuditEvent{Action: models.AuditSessionRevoked, Target: sessionID})
+	SendSuccess(ctx, "session revoked", gin.H{"session_id": sessionID}, http.StatusOK)
+}
+
+func (server *Server) revokeAllSessions(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if err := server.store.DeleteUserSessions(authPayload.Username); err != nil {
+		SendError(ctx, "", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	recordAudit(ctx, server.store, &models.AuditEvent{Action: models.AuditSessionRevoked, Target: authPayload.Username, Detail: "every session"})
+	SendSuccess(ctx, "every session revoked", nil, http.StatusOK)
+}
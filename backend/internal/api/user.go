@@ -1,42 +1,210 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/matheuspolitano/quiz-go/backend/internal/memdb"
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+	"github.com/matheuspolitano/quiz-go/backend/internal/token"
+	"github.com/matheuspolitano/quiz-go/backend/internal/utils"
 )
 
-type createUserRequest struct {
+var errInvalidCredentials = errors.New("invalid username or password")
+
+// dummyPasswordHash is compared when the user does not exist,
+// so a login takes the same time whether the username is known or not
+var dummyPasswordHash, _ = utils.HashPassword("not-a-real-password")
+
+type credentialsRequest struct {
 	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+type resetPasswordRequest struct {
+	Password string `json:"password" binding:"required"`
 }
 
 type loginUserResponse struct {
-	AccessToken string `json:"access_token"`
+	SessionID             string    `json:"session_id"`
+	AccessToken           string    `json:"access_token"`
+	AccessTokenExpiresAt  time.Time `json:"access_token_expires_at"`
+	RefreshToken          string    `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
+}
+
+// userResponse is the public view of a user, without the password hash
+type userResponse struct {
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
-func (server *Server) startUser(ctx *gin.Context) {
-	var req createUserRequest
+func newUserResponse(user *models.User) userResponse {
+	return userResponse{Username: user.Username, Role: user.GetRole(), CreatedAt: user.CreatedAt}
+}
+
+func (server *Server) registerUser(ctx *gin.Context) {
+	var req credentialsRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		SendError(ctx, "error in bind body", err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	_, err := server.store.CreateUser(req.Username)
-	if err != nil && err != memdb.ErrUsernameAlreadyExist {
+	passwordHash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		SendError(ctx, "", err.Error(), http.StatusBadRequest)
+		return
+	}
+	user, err := server.store.CreateUser(req.Username, passwordHash)
+	switch {
+	case err == memdb.ErrUsernameAlreadyExist:
+		SendError(ctx, "", err.Error(), http.StatusConflict)
+		return
+	case err == memdb.ErrUserDeleted:
+		SendError(ctx, "", err.Error(), http.StatusForbidden)
+		return
+	case err != nil:
 		SendError(ctx, "", err.Error(), http.StatusBadRequest)
 		return
 	}
+	server.sendToken(ctx, user, &models.AuditEvent{Action: models.AuditRegister})
+}
+
+func (server *Server) loginUser(ctx *gin.Context) {
+	var req credentialsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		SendError(ctx, "error in bind body", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := server.store.GetUser(req.Username)
+	if err != nil {
+		utils.CheckPassword(dummyPasswordHash, req.Password)
+		server.loginFailed(ctx, req.Username, "unknown user")
+		return
+	}
+	// users created before passwords existed cannot log in until an admin resets their password
+	if user.PasswordHash == "" || !utils.CheckPassword(user.PasswordHash, req.Password) {
+		server.loginFailed(ctx, req.Username, "wrong password")
+		return
+	}
+	server.sendToken(ctx, user, &models.AuditEvent{Action: models.AuditLogin, Detail: "password"})
+}
+
+// loginFailed records the failed login, the reason is kept out of the answer
+func (server *Server) loginFailed(ctx *gin.Context, username, reason string) {
+	recordAudit(ctx, server.store, &models.AuditEvent{
+		Actor:   username,
+		Action:  models.AuditLoginFailed,
+		Target:  username,
+		Outcome: models.AuditFailure,
+		Detail:  reason,
+	})
+	SendError(ctx, "", errInvalidCredentials.Error(), http.StatusUnauthorized)
+}
+
+// sendToken opens a session for the user and returns its refresh token with a first access token.
+// audit names the action that signed the user in, it is recorded with the new session as target.
+func (server *Server) sendToken(ctx *gin.Context, user *models.User, audit *models.AuditEvent) {
+	refreshToken, refreshPayload, err := server.tokenMaker.CreateRefreshToken(user.Username, server.config.RefreshTokenDuration)
+	if err != nil {
+		SendError(ctx, "", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	accessToken, accessPayload, err := server.tokenMaker.CreateToken(user.Username, user.GetRole(), server.config.AccessTokenDuration)
+	if err != nil {
+		SendError(ctx, "", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	session := &models.Session{
+		ID:         refreshPayload.ID.String(),
+		Username:   user.Username,
+		UserAgent:  ctx.Request.UserAgent(),
+		ClientIP:   ctx.ClientIP(),
+		CreatedAt:  refreshPayload.IssuedAt,
+		LastUsedAt: refreshPayload.IssuedAt,
+		ExpiresAt:  refreshPayload.ExpiredAt,
+	}
+	if err := server.store.CreateSession(session); err != nil {
+		SendError(ctx, "", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	audit.Actor, audit.Target = user.Username, session.ID
+	recordAudit(ctx, server.store, audit)
+	ctx.JSON(http.StatusCreated, &loginUserResponse{
+		SessionID:             session.ID,
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessPayload.ExpiredAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshPayload.ExpiredAt,
+	})
+}
 
-	token, _, err := server.tokenMaker.CreateToken(req.Username, "regular", time.Minute*60*24*365)
+func (server *Server) changePassword(ctx *gin.Context) {
+	var req changePasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		SendError(ctx, "error in bind body", err.Error(), http.StatusBadRequest)
+		return
+	}
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	user, err := server.store.GetUser(authPayload.Username)
+	if err != nil {
+		SendError(ctx, "", err.Error(), http.StatusNotFound)
+		return
+	}
+	if user.PasswordHash == "" || !utils.CheckPassword(user.PasswordHash, req.CurrentPassword) {
+		recordAudit(ctx, server.store, &models.AuditEvent{
+			Action:  models.AuditPasswordChanged,
+			Target:  user.Username,
+			Outcome: models.AuditFailure,
+			Detail:  "wrong current password",
+		})
+		SendError(ctx, "", "current password is wrong", http.StatusUnauthorized)
+		return
+	}
+	passwordHash, err := utils.HashPassword(req.NewPassword)
 	if err != nil {
 		SendError(ctx, "", err.Error(), http.StatusBadRequest)
 		return
 	}
-	userResponse := &loginUserResponse{
-		AccessToken: token,
+	if err := server.store.SetPasswordHash(user.Username, passwordHash); err != nil {
+		SendError(ctx, "", err.Error(), http.StatusInternalServerError)
+		return
 	}
-	ctx.JSON(http.StatusCreated, userResponse)
+	recordAudit(ctx, server.store, &models.AuditEvent{Action: models.AuditPasswordChanged, Target: user.Username})
+	SendSuccess(ctx, "password changed", nil, http.StatusOK)
+}
 
+func (server *Server) resetPassword(ctx *gin.Context) {
+	var req resetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		SendError(ctx, "error in bind body", err.Error(), http.StatusBadRequest)
+		return
+	}
+	passwordHash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		SendError(ctx, "", err.Error(), http.StatusBadRequest)
+		return
+	}
+	username := ctx.Param("username")
+	if err := server.store.SetPasswordHash(username, passwordHash); err != nil {
+		SendError(ctx, "", err.Error(), adminStatus(err))
+		return
+	}
+	// the old password may be known to someone else, log the user out everywhere
+	if err := server.store.DeleteUserSessions(username); err != nil {
+		SendError(ctx, "", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	recordAudit(ctx, server.store, &models.AuditEvent{Action: models.AuditPasswordReset, Target: username})
+	SendSuccess(ctx, "password reset, every session was revoked", gin.H{"username": username}, http.StatusOK)
 }
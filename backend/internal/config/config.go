@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
@@ -8,6 +10,60 @@ import (
 type Config struct {
 	ApiPort         string `mapstructure:"API_PORT"`
 	ApiTimeShutdown int    `mapstructure:"API_TIME_SHUTDOWN"`
+
+	// TokenMaker selects how tokens are signed: hmac (HS256), asymmetric (EdDSA or RS256),
+	// paseto-local (v4.local, hex keys in TokenSymmetricKeys) or paseto-public (v4.public, Ed25519 key files)
+	TokenMaker string `mapstructure:"TOKEN_MAKER"`
+	// TokenSymmetricKeys are the HMAC or paseto-local keys accepted for tokens, "kid1=secret1,kid2=secret2"
+	TokenSymmetricKeys string `mapstructure:"TOKEN_SYMMETRIC_KEYS"`
+	// TokenPrivateKeyFiles are the PEM private keys of the asymmetric and paseto-public makers, "kid1=path1,kid2=path2"
+	TokenPrivateKeyFiles string `mapstructure:"TOKEN_PRIVATE_KEY_FILES"`
+	// TokenPublicKeyFiles are verify-only PEM public keys of the asymmetric and paseto-public makers, same format
+	TokenPublicKeyFiles string `mapstructure:"TOKEN_PUBLIC_KEY_FILES"`
+	// TokenActiveKeyID is the kid signing new tokens, optional with a single key
+	TokenActiveKeyID string `mapstructure:"TOKEN_ACTIVE_KEY_ID"`
+	// AccessTokenDuration is how long an access token authorizes requests, e.g. 15m
+	AccessTokenDuration time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	// RefreshTokenDuration is how long a session can renew its access token without a new login, e.g. 720h
+	RefreshTokenDuration time.Duration `mapstructure:"REFRESH_TOKEN_DURATION"`
+
+	// StorageDriver selects the memdb driver: json, sqlite or memory
+	StorageDriver    string `mapstructure:"STORAGE_DRIVER"`
+	DataDir          string `mapstructure:"DATA_DIR"`
+	SQLitePath       string `mapstructure:"SQLITE_PATH"`
+	SQLiteImportJSON bool   `mapstructure:"SQLITE_IMPORT_JSON"`
+	// JournalCompactInterval is how often, in seconds, the json driver folds its journals into the snapshots
+	JournalCompactInterval int `mapstructure:"JOURNAL_COMPACT_INTERVAL"`
+	// QuestionBankWatch reloads the questions and quiz types when their files in DataDir change
+	QuestionBankWatch bool `mapstructure:"QUESTION_BANK_WATCH"`
+
+	// RateLimitAuth, RateLimitQuiz and RateLimitAdmin are the token bucket budgets of the route groups,
+	// "<requests>/<period>" such as 10/1m, empty or 0 for no limit. The login routes are limited
+	// per client IP, the others per user or API key. RateLimitIP limits every client IP on the
+	// authenticated routes before its credentials are checked, so guessing them is bounded too.
+	RateLimitAuth  string `mapstructure:"RATE_LIMIT_AUTH"`
+	RateLimitQuiz  string `mapstructure:"RATE_LIMIT_QUIZ"`
+	RateLimitAdmin string `mapstructure:"RATE_LIMIT_ADMIN"`
+	RateLimitIP    string `mapstructure:"RATE_LIMIT_IP"`
+	// TrustedProxies are the proxies whose X-Forwarded-For gives the client IP, "10.0.0.1,10.1.0.0/16".
+	// When empty the client IP is the address of the connection, so it cannot be spoofed.
+	TrustedProxies string `mapstructure:"TRUSTED_PROXIES"`
+
+	// OIDCIssuer enables the OpenID Connect login, players then sign in with the identity provider at this URL
+	OIDCIssuer       string `mapstructure:"OIDC_ISSUER"`
+	OIDCClientID     string `mapstructure:"OIDC_CLIENT_ID"`
+	OIDCClientSecret string `mapstructure:"OIDC_CLIENT_SECRET"`
+	// OIDCRedirectURL is the public URL of /api/oidc/callback, registered at the identity provider
+	OIDCRedirectURL string `mapstructure:"OIDC_REDIRECT_URL"`
+	// OIDCUsernameClaim names the users created on their first login: email, preferred_username or sub
+	OIDCUsernameClaim string `mapstructure:"OIDC_USERNAME_CLAIM"`
+	// OIDCMock serves a mock identity provider on /mock-idp accepting any username, for development only
+	OIDCMock bool `mapstructure:"OIDC_MOCK"`
+
+	// BootstrapAdmin is made admin at startup while no admin exists
+	BootstrapAdmin string `mapstructure:"BOOTSTRAP_ADMIN"`
+	// BootstrapAdminPassword is set on the bootstrap admin when it has no password yet
+	BootstrapAdminPassword string `mapstructure:"BOOTSTRAP_ADMIN_PASSWORD"`
 }
 
 // LoadConfig reads configuration from file or environment variables.
@@ -16,6 +72,33 @@ func LoadConfig(path string) (config Config, err error) {
 	viper.SetConfigName("app")
 	viper.SetConfigType("env")
 
+	viper.SetDefault("TOKEN_MAKER", "hmac")
+	viper.SetDefault("TOKEN_SYMMETRIC_KEYS", "")
+	viper.SetDefault("TOKEN_PRIVATE_KEY_FILES", "")
+	viper.SetDefault("TOKEN_PUBLIC_KEY_FILES", "")
+	viper.SetDefault("TOKEN_ACTIVE_KEY_ID", "")
+	viper.SetDefault("ACCESS_TOKEN_DURATION", "15m")
+	viper.SetDefault("REFRESH_TOKEN_DURATION", "720h")
+	viper.SetDefault("STORAGE_DRIVER", "json")
+	viper.SetDefault("DATA_DIR", "./data")
+	viper.SetDefault("SQLITE_PATH", "./data/quiz.db")
+	viper.SetDefault("SQLITE_IMPORT_JSON", true)
+	viper.SetDefault("JOURNAL_COMPACT_INTERVAL", 60)
+	viper.SetDefault("QUESTION_BANK_WATCH", true)
+	viper.SetDefault("RATE_LIMIT_AUTH", "10/1m")
+	viper.SetDefault("RATE_LIMIT_QUIZ", "120/1m")
+	viper.SetDefault("RATE_LIMIT_ADMIN", "300/1m")
+	viper.SetDefault("RATE_LIMIT_IP", "600/1m")
+	viper.SetDefault("TRUSTED_PROXIES", "")
+	viper.SetDefault("OIDC_ISSUER", "")
+	viper.SetDefault("OIDC_CLIENT_ID", "")
+	viper.SetDefault("OIDC_CLIENT_SECRET", "")
+	viper.SetDefault("OIDC_REDIRECT_URL", "")
+	viper.SetDefault("OIDC_USERNAME_CLAIM", "email")
+	viper.SetDefault("OIDC_MOCK", false)
+	viper.SetDefault("BOOTSTRAP_ADMIN", "")
+	viper.SetDefault("BOOTSTRAP_ADMIN_PASSWORD", "")
+
 	viper.AutomaticEnv()
 
 	if err = viper.ReadInConfig(); err != nil {
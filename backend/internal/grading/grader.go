@@ -0,0 +1,66 @@
+package grading
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+var ErrUnknownKind = errors.New("unknown question kind")
+
+// Grader checks the questions of a kind and grades the answers given to them.
+type Grader interface {
+	// Validate checks that the question can be answered and graded
+	Validate(question *models.Question) error
+	// Grade returns the credit of answer, from 0 for wrong to 1 for right
+	Grade(question *models.Question, answer string) float32
+}
+
+var (
+	mu      sync.RWMutex
+	graders = map[string]Grader{
+		models.KindChoice:      choiceGrader{},
+		models.KindTrueFalse:   trueFalseGrader{},
+		models.KindMultiSelect: multiSelectGrader{},
+		models.KindNumeric:     numericGrader{},
+		models.KindText:        textGrader{},
+	}
+)
+
+// Register makes a grader available for kind, replacing the grader of a known kind
+func Register(kind string, grader Grader) {
+	mu.Lock()
+	defer mu.Unlock()
+	graders[kind] = grader
+}
+
+// For returns the grader of kind
+func For(kind string) (Grader, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	grader, ok := graders[kind]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKind, kind)
+	}
+	return grader, nil
+}
+
+// Validate checks question with the grader of its kind
+func Validate(question *models.Question) error {
+	grader, err := For(question.GetKind())
+	if err != nil {
+		return fmt.Errorf("question %s: %w", question.ID, err)
+	}
+	return grader.Validate(question)
+}
+
+// Grade grades answer with the grader of the question kind, a question of an unknown kind gives no credit
+func Grade(question *models.Question, answer string) float32 {
+	grader, err := For(question.GetKind())
+	if err != nil {
+		return 0
+	}
+	return grader.Grade(question, answer)
+}
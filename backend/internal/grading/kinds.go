@@ -0,0 +1,189 @@
+package grading
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+// choiceGrader grades a single right option, Answer is its letter or the whole option
+type choiceGrader struct{}
+
+func (choiceGrader) Validate(q *models.Question) error {
+	if len(q.Options) < 2 {
+		return fmt.Errorf("question %s needs at least two options", q.ID)
+	}
+	if _, ok := optionIndex(q, q.Answer); !ok {
+		return fmt.Errorf("question %s answer %q does not match any option", q.ID, q.Answer)
+	}
+	return nil
+}
+
+func (choiceGrader) Grade(q *models.Question, answer string) float32 {
+	expected, ok := optionIndex(q, q.Answer)
+	given, found := optionIndex(q, answer)
+	if ok && found && expected == given {
+		return 1
+	}
+	return 0
+}
+
+// trueFalseGrader accepts true, false and their usual spellings such as yes, no, t or f
+type trueFalseGrader struct{}
+
+func (trueFalseGrader) Validate(q *models.Question) error {
+	if _, ok := parseBool(q.Answer); !ok {
+		return fmt.Errorf("question %s answer %q is neither true nor false", q.ID, q.Answer)
+	}
+	return nil
+}
+
+func (trueFalseGrader) Grade(q *models.Question, answer string) float32 {
+	expected, _ := parseBool(q.Answer)
+	given, ok := parseBool(answer)
+	if ok && given == expected {
+		return 1
+	}
+	return 0
+}
+
+// multiSelectGrader grades a set of options written "A,C". Without partial credit only the exact
+// set is right, with it each right option is worth a share and each wrong one takes a share back.
+type multiSelectGrader struct{}
+
+func (multiSelectGrader) Validate(q *models.Question) error {
+	if len(q.Options) < 2 {
+		return fmt.Errorf("question %s needs at least two options", q.ID)
+	}
+	expected, ok := optionSet(q, q.Answer)
+	if !ok || len(expected) == 0 {
+		return fmt.Errorf("question %s answer %q does not list options", q.ID, q.Answer)
+	}
+	return nil
+}
+
+func (multiSelectGrader) Grade(q *models.Question, answer string) float32 {
+	expected, _ := optionSet(q, q.Answer)
+	given, ok := optionSet(q, answer)
+	if !ok || len(expected) == 0 {
+		return 0
+	}
+	right, wrong := 0, 0
+	for index := range given {
+		if expected[index] {
+			right++
+		} else {
+			wrong++
+		}
+	}
+	if !q.PartialCredit {
+		if right == len(expected) && wrong == 0 {
+			return 1
+		}
+		return 0
+	}
+	return float32(math.Max(0, float64(right-wrong)/float64(len(expected))))
+}
+
+// numericGrader accepts a number within Tolerance of Answer
+type numericGrader struct{}
+
+func (numericGrader) Validate(q *models.Question) error {
+	if _, err := parseNumber(q.Answer); err != nil {
+		return fmt.Errorf("question %s answer %q is not a number", q.ID, q.Answer)
+	}
+	if q.Tolerance < 0 {
+		return fmt.Errorf("question %s has a negative tolerance", q.ID)
+	}
+	return nil
+}
+
+func (numericGrader) Grade(q *models.Question, answer string) float32 {
+	expected, _ := parseNumber(q.Answer)
+	given, err := parseNumber(answer)
+	if err == nil && math.Abs(given-expected) <= q.Tolerance {
+		return 1
+	}
+	return 0
+}
+
+// textGrader matches Answer and its Accepted synonyms, ignoring case, punctuation and spacing
+type textGrader struct{}
+
+func (textGrader) Validate(q *models.Question) error {
+	if normalizeText(q.Answer) == "" {
+		return fmt.Errorf("question %s has no answer", q.ID)
+	}
+	return nil
+}
+
+func (textGrader) Grade(q *models.Question, answer string) float32 {
+	given := normalizeText(answer)
+	if given == "" {
+		return 0
+	}
+	for _, accepted := range append([]string{q.Answer}, q.Accepted...) {
+		if normalizeText(accepted) == given {
+			return 1
+		}
+	}
+	return 0
+}
+
+// optionIndex finds the option answered by its letter, "A" for "A: Rome", or by the whole option
+func optionIndex(q *models.Question, answer string) (int, bool) {
+	answer = strings.TrimSpace(answer)
+	for i, option := range q.Options {
+		letter, _, _ := strings.Cut(option, ":")
+		if strings.EqualFold(strings.TrimSpace(letter), answer) || option == answer {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// optionSet reads options separated by commas, it fails on any unknown option
+func optionSet(q *models.Question, answer string) (map[int]bool, bool) {
+	set := make(map[int]bool)
+	for _, part := range strings.Split(answer, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		index, ok := optionIndex(q, part)
+		if !ok {
+			return nil, false
+		}
+		set[index] = true
+	}
+	return set, true
+}
+
+func parseBool(s string) (bool, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "true", "t", "yes", "y":
+		return true, true
+	case "false", "f", "no", "n":
+		return false, true
+	}
+	return false, false
+}
+
+// parseNumber reads a number, a decimal comma is accepted as well
+func parseNumber(s string) (float64, error) {
+	return strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(s), ",", "."), 64)
+}
+
+// normalizeText lowercases s, drops its punctuation and collapses its spaces
+func normalizeText(s string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			return ' '
+		}
+		return unicode.ToLower(r)
+	}, s)
+	return strings.Join(strings.Fields(cleaned), " ")
+}
@@ -0,0 +1,112 @@
+package grading
+
+import (
+	"testing"
+
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+func TestGrade(t *testing.T) {
+	choice := &models.Question{ID: "c", Options: []string{"A: Rome", "B: Milan", "C: Venice"}, Answer: "A"}
+	trueFalse := &models.Question{ID: "tf", Kind: models.KindTrueFalse, Answer: "true"}
+	multi := &models.Question{ID: "m", Kind: models.KindMultiSelect, Options: []string{"A: 2", "B: 3", "C: 4", "D: 5"}, Answer: "A,B,D"}
+	partial := *multi
+	partial.PartialCredit = true
+	numeric := &models.Question{ID: "n", Kind: models.KindNumeric, Answer: "3.14", Tolerance: 0.01}
+	exact := &models.Question{ID: "e", Kind: models.KindNumeric, Answer: "2,5"}
+	text := &models.Question{ID: "t", Kind: models.KindText, Answer: "New York", Accepted: []string{"NYC"}}
+
+	tests := []struct {
+		name     string
+		question *models.Question
+		answer   string
+		want     float32
+	}{
+		{"choice by letter", choice, "A", 1},
+		{"choice by lowercase letter", choice, " a ", 1},
+		{"choice by whole option", choice, "A: Rome", 1},
+		{"choice wrong", choice, "B", 0},
+		{"choice unknown option", choice, "Z", 0},
+		{"choice empty", choice, "", 0},
+
+		{"true false", trueFalse, "true", 1},
+		{"true false spelling", trueFalse, "Yes", 1},
+		{"true false short", trueFalse, "t", 1},
+		{"true false wrong", trueFalse, "no", 0},
+		{"true false unreadable", trueFalse, "maybe", 0},
+
+		{"multi exact set", multi, "A,B,D", 1},
+		{"multi any order and spacing", multi, "d, a ,B", 1},
+		{"multi missing option", multi, "A,B", 0},
+		{"multi extra option", multi, "A,B,C,D", 0},
+		{"multi unknown option", multi, "A,B,Z", 0},
+		{"partial exact set", &partial, "A,B,D", 1},
+		{"partial two of three", &partial, "A,B", 2.0 / 3},
+		{"partial right and wrong cancel", &partial, "A,C", 0},
+		{"partial two right one wrong", &partial, "A,B,C", 1.0 / 3},
+		{"partial never negative", &partial, "C", 0},
+		{"partial unknown option", &partial, "A,Z", 0},
+
+		{"numeric exact", numeric, "3.14", 1},
+		{"numeric within tolerance", numeric, "3.149", 1},
+		{"numeric outside tolerance", numeric, "3.2", 0},
+		{"numeric comma decimal", numeric, "3,14", 1},
+		{"numeric comma decimal answer", exact, "2.5", 1},
+		{"numeric comma decimal both", exact, " 2,5 ", 1},
+		{"numeric without tolerance", exact, "2.51", 0},
+		{"numeric not a number", numeric, "pi", 0},
+
+		{"text exact", text, "New York", 1},
+		{"text case and spaces", text, "  new   york ", 1},
+		{"text punctuation", text, "New-York!", 1},
+		{"text accepted synonym", text, "nyc", 1},
+		{"text wrong", text, "Boston", 0},
+		{"text empty", text, "  ", 0},
+		{"text only punctuation", text, "?!", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Grade(tt.question, tt.answer)
+			if diff := got - tt.want; diff > 1e-6 || diff < -1e-6 {
+				t.Errorf("Grade(%q) = %v, want %v", tt.answer, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		question *models.Question
+		valid    bool
+	}{
+		{"choice", &models.Question{Options: []string{"A: 1", "B: 2"}, Answer: "B"}, true},
+		{"choice one option", &models.Question{Options: []string{"A: 1"}, Answer: "A"}, false},
+		{"choice unknown answer", &models.Question{Options: []string{"A: 1", "B: 2"}, Answer: "C"}, false},
+		{"true false", &models.Question{Kind: models.KindTrueFalse, Answer: "false"}, true},
+		{"true false other answer", &models.Question{Kind: models.KindTrueFalse, Answer: "perhaps"}, false},
+		{"multi select", &models.Question{Kind: models.KindMultiSelect, Options: []string{"A: 1", "B: 2"}, Answer: "A,B"}, true},
+		{"multi select no answer", &models.Question{Kind: models.KindMultiSelect, Options: []string{"A: 1", "B: 2"}, Answer: " , "}, false},
+		{"multi select unknown option", &models.Question{Kind: models.KindMultiSelect, Options: []string{"A: 1", "B: 2"}, Answer: "A,C"}, false},
+		{"numeric comma decimal", &models.Question{Kind: models.KindNumeric, Answer: "1,5"}, true},
+		{"numeric not a number", &models.Question{Kind: models.KindNumeric, Answer: "one"}, false},
+		{"numeric negative tolerance", &models.Question{Kind: models.KindNumeric, Answer: "1", Tolerance: -1}, false},
+		{"text", &models.Question{Kind: models.KindText, Answer: "Paris"}, true},
+		{"text only punctuation", &models.Question{Kind: models.KindText, Answer: "..."}, false},
+		{"unknown kind", &models.Question{Kind: "essay", Answer: "x"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.question)
+			if (err == nil) != tt.valid {
+				t.Errorf("Validate(%+v) = %v, want valid %v", tt.question, err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestGradeUnknownKind(t *testing.T) {
+	if got := Grade(&models.Question{Kind: "essay", Answer: "x"}, "x"); got != 0 {
+		t.Errorf("Grade of an unknown kind = %v, want 0", got)
+	}
+}
@@ -0,0 +1,52 @@
+package memdb
+
+import (
+	"fmt"
+
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+// CreateAPIKey stores a new key, its secret must already be hashed.
+func (db *DBManager) CreateAPIKey(key *models.APIKey) (*models.APIKey, error) {
+	if key.Name == "" || len(key.Scopes) == 0 {
+		return nil, fmt.Errorf("CreateAPIKey: %w: a name and at least one scope are required", ErrInvalidEntry)
+	}
+	for _, scope := range key.Scopes {
+		if !models.ValidScope(scope) {
+			return nil, fmt.Errorf("CreateAPIKey: %w: unknown scope %q", ErrInvalidEntry, scope)
+		}
+	}
+	key.DeletedAt = nil
+
+	err := db.Update(func(tx *Tx) error {
+		key.CreatedAt = tx.now
+		if err := db.apiKeyRepo.SaveTx(tx, key); err != nil {
+			return fmt.Errorf("CreateAPIKey: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// GetAPIKey returns a key that has not been revoked, expired keys are returned too.
+func (db *DBManager) GetAPIKey(id string) (*models.APIKey, error) {
+	return db.apiKeyRepo.FindByID(id)
+}
+
+// ListAPIKeys returns a page of keys ordered by ID, revoked keys only with opts.IncludeDeleted.
+func (db *DBManager) ListAPIKeys(opts ListOptions) (Page[*models.APIKey], error) {
+	return applyListOptions(db.apiKeyRepo.Query(), opts).Page()
+}
+
+// RevokeAPIKey tombstones a key, it is kept so the listing shows who created and revoked it.
+func (db *DBManager) RevokeAPIKey(id string) error {
+	return db.Update(func(tx *Tx) error {
+		if _, err := db.apiKeyRepo.FindByIDTx(tx, id); err != nil {
+			return fmt.Errorf("RevokeAPIKey: %w", err)
+		}
+		return db.apiKeyRepo.SoftDeleteTx(tx, id)
+	})
+}
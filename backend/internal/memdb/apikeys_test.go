@@ -0,0 +1,126 @@
+package memdb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+	"github.com/matheuspolitano/quiz-go/backend/internal/utils"
+)
+
+// TestAPIKeyRoundTrip creates a key like the admin route does and finds it again from the key alone.
+func TestAPIKeyRoundTrip(t *testing.T) {
+	db, err := NewDBManager(NewMemoryDriver())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	id := uuid.NewString()
+	key, secretHash, err := utils.NewAPIKey(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateAPIKey(&models.APIKey{ID: id, Name: "ci", Scopes: []string{models.ScopeReportsRead}, SecretHash: secretHash}); err != nil {
+		t.Fatal(err)
+	}
+
+	parsedID, secret, err := utils.ParseAPIKey(key)
+	if err != nil || parsedID != id {
+		t.Fatalf("ParseAPIKey(%q) = %q, %v, want id %q", key, parsedID, err, id)
+	}
+	stored, err := db.GetAPIKey(parsedID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !utils.CheckAPIKeySecret(stored.SecretHash, secret) {
+		t.Error("the secret of the key does not match its stored hash")
+	}
+	if utils.CheckAPIKeySecret(stored.SecretHash, secret+"x") {
+		t.Error("another secret matches the stored hash")
+	}
+
+	if err := db.RevokeAPIKey(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetAPIKey(id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("revoked key: got %v, want %v", err, ErrNotFound)
+	}
+	if err := db.RevokeAPIKey(id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("revoke twice: got %v, want %v", err, ErrNotFound)
+	}
+	// the revoked key stays listed for the record
+	page, err := db.ListAPIKeys(ListOptions{IncludeDeleted: true})
+	if err != nil || len(page.Items) != 1 || page.Items[0].DeletedAt == nil {
+		t.Errorf("listing with revoked keys: %v, %v", page.Items, err)
+	}
+}
+
+func TestParseAPIKey(t *testing.T) {
+	tests := []struct {
+		key    string
+		id     string
+		secret string
+		valid  bool
+	}{
+		{"qk_k1_secret", "k1", "secret", true},
+		{"qk_k1_sec_ret", "k1", "sec_ret", true},
+		{"k1_secret", "", "", false},
+		{"qk_k1", "", "", false},
+		{"qk__secret", "", "", false},
+		{"qk_k1_", "", "", false},
+		{"", "", "", false},
+	}
+	for _, tt := range tests {
+		id, secret, err := utils.ParseAPIKey(tt.key)
+		if !tt.valid {
+			if !errors.Is(err, utils.ErrInvalidAPIKey) {
+				t.Errorf("ParseAPIKey(%q): got %v, want %v", tt.key, err, utils.ErrInvalidAPIKey)
+			}
+			continue
+		}
+		if err != nil || id != tt.id || secret != tt.secret {
+			t.Errorf("ParseAPIKey(%q) = %q, %q, %v, want %q, %q", tt.key, id, secret, err, tt.id, tt.secret)
+		}
+	}
+}
+
+func TestCreateAPIKeyScopes(t *testing.T) {
+	db, err := NewDBManager(NewMemoryDriver())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for name, scopes := range map[string][]string{
+		"no scope":      nil,
+		"unknown scope": {models.ScopeReportsRead, "users:write"},
+	} {
+		if _, err := db.CreateAPIKey(&models.APIKey{ID: uuid.NewString(), Name: "ci", Scopes: scopes}); !errors.Is(err, ErrInvalidEntry) {
+			t.Errorf("%s: got %v, want %v", name, err, ErrInvalidEntry)
+		}
+	}
+	if _, err := db.CreateAPIKey(&models.APIKey{ID: uuid.NewString(), Scopes: []string{models.ScopeReportsRead}}); !errors.Is(err, ErrInvalidEntry) {
+		t.Errorf("no name: got %v, want %v", err, ErrInvalidEntry)
+	}
+
+	key, err := db.CreateAPIKey(&models.APIKey{ID: uuid.NewString(), Name: "authoring", Scopes: []string{models.ScopeQuestionsRead}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		scopes []string
+		want   bool
+	}{
+		{[]string{models.ScopeQuestionsRead}, true},
+		{[]string{models.ScopeQuestionsRead, models.ScopeQuestionsWrite}, true},
+		{[]string{models.ScopeQuestionsWrite}, false},
+		{[]string{models.ScopeReportsRead}, false},
+		{nil, false},
+	} {
+		if got := key.HasScope(tt.scopes...); got != tt.want {
+			t.Errorf("HasScope(%v) = %v, want %v", tt.scopes, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package memdb
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+var (
+	ErrAttemptInProgress  = errors.New("finish the attempt in progress before starting a new one")
+	ErrMaxAttemptsReached = errors.New("no attempts left for this quiz type")
+)
+
+// UserScore is the standing of a user in a quiz type across their attempts.
+// Best and Average only count the closed attempts.
+type UserScore struct {
+	Latest          *models.QuestionFlow
+	Best            *models.QuestionFlow
+	Attempts        int
+	Closed          int
+	AverageAccuracy float32
+	GeneralAccuracy float32
+}
+
+// attempts returns the attempts of a user at a quiz type ordered by number, tombstones included.
+func (db *DBManager) attempts(userID, typeQuizName string) ([]*models.QuestionFlow, error) {
+	return db.questionsFlowRepo.Query().
+		ByIndex(indexByUser, userID).
+		Where(func(f *models.QuestionFlow) bool { return f.TypeQuizName == typeQuizName }).
+		WithDeleted().
+		OrderBy(func(a, b *models.QuestionFlow) bool { return a.Attempt < b.Attempt }).
+		All()
+}
+
+// latestAttempt picks the live attempt with the highest number, nil when there is none.
+func latestAttempt(attempts []*models.QuestionFlow) *models.QuestionFlow {
+	for i := len(attempts) - 1; i >= 0; i-- {
+		if attempts[i].DeletedAt == nil {
+			return attempts[i]
+		}
+	}
+	return nil
+}
+
+// CurrentAttempt returns the latest live attempt of a user at a quiz type.
+func (db *DBManager) CurrentAttempt(userID, typeQuizName string) (*models.QuestionFlow, error) {
+	attempts, err := db.attempts(userID, typeQuizName)
+	if err != nil {
+		return nil, fmt.Errorf("CurrentAttempt: %w", err)
+	}
+	latest := latestAttempt(attempts)
+	if latest == nil {
+		return nil, fmt.Errorf("CurrentAttempt: no attempt at %s: %w", typeQuizName, ErrNotFound)
+	}
+	return latest, nil
+}
+
+// ListAttempts returns the live attempts of a user, of every quiz type when typeQuizName is empty,
+// ordered by quiz type and attempt number.
+func (db *DBManager) ListAttempts(userID, typeQuizName string) ([]*models.QuestionFlow, error) {
+	attempts, err := db.questionsFlowRepo.Query().
+		ByIndex(indexByUser, userID).
+		Where(func(f *models.QuestionFlow) bool { return typeQuizName == "" || f.TypeQuizName == typeQuizName }).
+		OrderBy(func(a, b *models.QuestionFlow) bool {
+			if a.TypeQuizName != b.TypeQuizName {
+				return a.TypeQuizName < b.TypeQuizName
+			}
+			return a.Attempt < b.Attempt
+		}).
+		All()
+	if err != nil {
+		return nil, fmt.Errorf("ListAttempts: %w", err)
+	}
+	return attempts, nil
+}
+
+// StartAttempt opens a new attempt of a user at a quiz type. It is refused while the
+// latest attempt is still open or once the user used the MaxAttempts of the quiz type.
+// Soft deleted attempts keep their number but are not counted against MaxAttempts.
+func (db *DBManager) StartAttempt(userID, typeQuizName string) (*models.QuestionFlow, error) {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	attempts, err := db.attempts(userID, typeQuizName)
+	if err != nil {
+		return nil, fmt.Errorf("StartAttempt: %w", err)
+	}
+	if latest := latestAttempt(attempts); latest != nil && !latest.IsClosed() {
+		return nil, fmt.Errorf("StartAttempt: %w: attempt %d", ErrAttemptInProgress, latest.Attempt)
+	}
+	flow, err := db.startAttemptTx(tx, userID, typeQuizName, attempts)
+	if err != nil {
+		return nil, fmt.Errorf("StartAttempt: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("StartAttempt: %w", err)
+	}
+	return flow, nil
+}
+
+// startAttemptTx stores the attempt following attempts and lists it on the user.
+func (db *DBManager) startAttemptTx(tx *Tx, userID, typeQuizName string, attempts []*models.QuestionFlow) (*models.QuestionFlow, error) {
+	typeQuiz, err := db.TypeQuizRepo.FindByIDTx(tx, typeQuizName)
+	if err != nil {
+		return nil, fmt.Errorf("TypeQuiz does not exist: %w", err)
+	}
+	number, live := 1, 0
+	for _, attempt := range attempts {
+		if attempt.Attempt >= number {
+			number = attempt.Attempt + 1
+		}
+		if attempt.DeletedAt == nil {
+			live++
+		}
+	}
+	if typeQuiz.MaxAttempts > 0 && live >= typeQuiz.MaxAttempts {
+		return nil, fmt.Errorf("%w: %d of %d used", ErrMaxAttemptsReached, live, typeQuiz.MaxAttempts)
+	}
+
+	user, err := db.userProgressRepo.FindByIDTx(tx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	flow := &models.QuestionFlow{
+		UserID:       userID,
+		TypeQuizName: typeQuizName,
+		Attempt:      number,
+		CreatedAt:    tx.now,
+		AccuracyRate: 1.0,
+		History:      make([]string, 0),
+	}
+	for typeQuiz.Shuffle && flow.Seed == 0 {
+		flow.Seed = rand.Uint64()
+	}
+	if typeQuiz.Pool != nil {
+		if flow.QuestionsID, err = db.drawQuestionsTx(tx, typeQuiz); err != nil {
+			return nil, fmt.Errorf("failed to draw the questions: %w", err)
+		}
+	}
+	if err := db.questionsFlowRepo.SaveTx(tx, flow); err != nil {
+		return nil, fmt.Errorf("failed to save new flow: %w", err)
+	}
+	user.QuestionsFlowsID = append(user.QuestionsFlowsID, flow.GetID())
+	if err := db.userProgressRepo.SaveTx(tx, user); err != nil {
+		return nil, fmt.Errorf("failed to update user flows: %w", err)
+	}
+	return flow, nil
+}
+
+// GetScoreUser scores the attempts of a user at a quiz type. The general accuracy
+// averages the closed attempts of every user.
+func (db *DBManager) GetScoreUser(userID, quizType string) (*UserScore, error) {
+	attempts, err := db.ListAttempts(userID, quizType)
+	if err != nil {
+		return nil, fmt.Errorf("GetScoreUser: %w", err)
+	}
+	if len(attempts) == 0 {
+		return nil, fmt.Errorf("GetScoreUser: no attempt at %s: %w", quizType, ErrNotFound)
+	}
+
+	score := &UserScore{Latest: attempts[len(attempts)-1], Attempts: len(attempts)}
+	var total float32
+	for _, attempt := range attempts {
+		if !attempt.IsClosed() {
+			continue
+		}
+		score.Closed++
+		total += attempt.AccuracyRate
+		if score.Best == nil || attempt.AccuracyRate > score.Best.AccuracyRate {
+			score.Best = attempt
+		}
+	}
+	if score.Closed > 0 {
+		score.AverageAccuracy = total / float32(score.Closed)
+	}
+
+	closedFlows, err := db.questionsFlowRepo.Query().
+		ByIndex(indexByTypeQuiz, quizType).
+		Where(func(f *models.QuestionFlow) bool { return f.IsClosed() }).
+		All()
+	if err != nil {
+		return nil, fmt.Errorf("GetScoreUser: %w", err)
+	}
+	if len(closedFlows) > 0 {
+		var general float32
+		for _, item := range closedFlows {
+			general += item.AccuracyRate
+		}
+		score.GeneralAccuracy = general / float32(len(closedFlows))
+	}
+	return score, nil
+}
+
+// migrateFlowAttempts renames the flows stored before attempts were numbered,
+// "user:type" becomes attempt 1 "user:type:1" and the users list the new ID.
+// It only finds flows to rename on the first start after the upgrade.
+func (db *DBManager) migrateFlowAttempts() (int, error) {
+	legacy, err := db.questionsFlowRepo.Query().
+		WithDeleted().
+		Where(func(f *models.QuestionFlow) bool { return f.Attempt == 0 }).
+		All()
+	if err != nil || len(legacy) == 0 {
+		return 0, err
+	}
+	err = db.Update(func(tx *Tx) error {
+		for _, flow := range legacy {
+			oldID := flow.GetID()
+			renamed := *flow
+			renamed.Attempt = 1
+			if err := db.questionsFlowRepo.DeleteTx(tx, oldID); err != nil {
+				return err
+			}
+			if err := db.questionsFlowRepo.SaveTx(tx, &renamed); err != nil {
+				return err
+			}
+			user, err := db.userProgressRepo.findTx(tx, flow.UserID)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			user.QuestionsFlowsID = removeString(user.QuestionsFlowsID, oldID)
+			user.QuestionsFlowsID = append(user.QuestionsFlowsID, renamed.GetID())
+			if err := db.userProgressRepo.SaveTx(tx, user); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("migrateFlowAttempts: %w", err)
+	}
+	return len(legacy), nil
+}
@@ -0,0 +1,174 @@
+package memdb
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+// newAttemptTestDB returns a database where alice may take two attempts at Maths
+func newAttemptTestDB(t *testing.T) *DBManager {
+	t.Helper()
+	db, err := NewDBManager(NewMemoryDriver())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.CreateQuestion(&models.Question{ID: "q1", Prompt: "2 + 2?", Options: []string{"A: 3", "B: 4"}, Answer: "B"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateTypeQuiz(&models.TypeQuiz{Name: "Maths", QuestionsID: []string{"q1"}, MaxAttempts: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateUser("alice", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// finishAttempt answers every question of flow, which closes it
+func finishAttempt(t *testing.T, db *DBManager, flow *models.QuestionFlow) {
+	t.Helper()
+	for {
+		question, err := db.NextQuestion(flow.GetID())
+		if errors.Is(err, ErrAllQuestionsAnswered) {
+			return
+		}
+		if err != nil {
+			t.Fatalf("NextQuestion: %v", err)
+		}
+		if _, err := db.AddAnswer(flow.GetID(), question.ID, "B"); err != nil {
+			t.Fatalf("AddAnswer: %v", err)
+		}
+	}
+}
+
+func TestAttemptNumbering(t *testing.T) {
+	db := newAttemptTestDB(t)
+
+	first, err := db.AddQuestionFlow("alice", "Maths")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Attempt != 1 || first.GetID() != models.FlowID("alice", "Maths", 1) {
+		t.Fatalf("first attempt %d with id %s", first.Attempt, first.GetID())
+	}
+	// joining again returns the attempt in progress
+	if again, err := db.AddQuestionFlow("alice", "Maths"); err != nil || again.GetID() != first.GetID() {
+		t.Errorf("join again: %v, %v, want attempt 1", again, err)
+	}
+	if _, err := db.StartAttempt("alice", "Maths"); !errors.Is(err, ErrAttemptInProgress) {
+		t.Errorf("start while attempt 1 is open: got %v, want %v", err, ErrAttemptInProgress)
+	}
+
+	finishAttempt(t, db, first)
+	second, err := db.StartAttempt("alice", "Maths")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Attempt != 2 {
+		t.Errorf("second attempt numbered %d", second.Attempt)
+	}
+	if current, err := db.CurrentAttempt("alice", "Maths"); err != nil || current.Attempt != 2 {
+		t.Errorf("current attempt: %v, %v, want 2", current, err)
+	}
+	user, err := db.GetUser("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(user.QuestionsFlowsID, []string{first.GetID(), second.GetID()}) {
+		t.Errorf("user lists %v", user.QuestionsFlowsID)
+	}
+}
+
+func TestAttemptLimit(t *testing.T) {
+	db := newAttemptTestDB(t)
+	for i := 0; i < 2; i++ {
+		flow, err := db.StartAttempt("alice", "Maths")
+		if err != nil {
+			t.Fatal(err)
+		}
+		finishAttempt(t, db, flow)
+	}
+	if _, err := db.StartAttempt("alice", "Maths"); !errors.Is(err, ErrMaxAttemptsReached) {
+		t.Fatalf("third attempt: got %v, want %v", err, ErrMaxAttemptsReached)
+	}
+
+	// a soft deleted attempt gives the attempt back but keeps its number
+	if err := db.DeleteQuestionFlow("alice", "Maths", 1, DeleteOptions{Soft: true}); err != nil {
+		t.Fatal(err)
+	}
+	third, err := db.StartAttempt("alice", "Maths")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.Attempt != 3 {
+		t.Errorf("attempt after a soft delete numbered %d, want 3", third.Attempt)
+	}
+	attempts, err := db.ListAttempts("alice", "Maths")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var numbers []int
+	for _, attempt := range attempts {
+		numbers = append(numbers, attempt.Attempt)
+	}
+	if !slices.Equal(numbers, []int{2, 3}) {
+		t.Errorf("live attempts %v, want [2 3]", numbers)
+	}
+
+	if _, err := db.StartAttempt("alice", "History"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("attempt at an unknown quiz type: got %v, want %v", err, ErrNotFound)
+	}
+}
+
+// TestMigrateFlowAttempts opens a database stored before attempts were numbered,
+// its flows become attempt 1 and the users list their new IDs.
+func TestMigrateFlowAttempts(t *testing.T) {
+	driver := NewMemoryDriver()
+	seed := []struct {
+		collection, id, doc string
+	}{
+		{usersCollection, "alice", `{"username": "alice", "questions_flows_id": ["alice:Maths"]}`},
+		{questionsFlowCollection, "alice:Maths", `{"user_id": "alice", "type_quiz": "Maths", "history": ["h1"], "accuracy_rate": 1}`},
+		// the flow of a user that no longer exists is renamed too
+		{questionsFlowCollection, "bob:Maths", `{"user_id": "bob", "type_quiz": "Maths", "history": []}`},
+	}
+	var ops []Op
+	for _, entry := range seed {
+		ops = append(ops, Op{Collection: entry.collection, Kind: OpPut, ID: entry.id, Data: json.RawMessage(entry.doc)})
+	}
+	if err := driver.Apply(ops); err != nil {
+		t.Fatal(err)
+	}
+
+	for run := 1; run <= 2; run++ {
+		db, err := NewDBManager(driver)
+		if err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+		for _, user := range []string{"alice", "bob"} {
+			if _, err := db.questionsFlowRepo.FindByID(user + ":Maths"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("run %d: legacy flow of %s: got %v, want %v", run, user, err, ErrNotFound)
+			}
+			if _, err := db.questionsFlowRepo.FindByID(models.FlowID(user, "Maths", 1)); err != nil {
+				t.Errorf("run %d: attempt 1 of %s: %v", run, user, err)
+			}
+		}
+		flow, err := db.CurrentAttempt("alice", "Maths")
+		if err != nil || flow.Attempt != 1 || !slices.Equal(flow.History, []string{"h1"}) {
+			t.Errorf("run %d: current attempt %+v, %v", run, flow, err)
+		}
+		user, err := db.GetUser("alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(user.QuestionsFlowsID, []string{models.FlowID("alice", "Maths", 1)}) {
+			t.Errorf("run %d: user lists %v", run, user.QuestionsFlowsID)
+		}
+		db.Close()
+	}
+}
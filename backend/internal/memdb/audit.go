@@ -0,0 +1,68 @@
+package memdb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+// AuditFilter selects audit events, empty fields match everything.
+// Action matches the action itself or, like "question", every action of that subject.
+type AuditFilter struct {
+	Actor   string
+	Action  string
+	Target  string
+	Outcome string
+	Since   time.Time
+	Until   time.Time
+}
+
+func (f AuditFilter) match(event *models.AuditEvent) bool {
+	switch {
+	case f.Action != "" && event.Action != f.Action && !strings.HasPrefix(event.Action, f.Action+"."):
+		return false
+	case f.Target != "" && event.Target != f.Target:
+		return false
+	case f.Outcome != "" && event.Outcome != f.Outcome:
+		return false
+	case !f.Since.IsZero() && event.CreatedAt.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !event.CreatedAt.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// AppendAudit stores a new audit event with a fresh ID and the current time.
+// The audit log is append-only, there is no way to change or delete an event.
+func (db *DBManager) AppendAudit(event *models.AuditEvent) error {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return fmt.Errorf("AppendAudit: %w", err)
+	}
+	event.ID = id.String()
+	if event.Outcome == "" {
+		event.Outcome = models.AuditSuccess
+	}
+	return db.Update(func(tx *Tx) error {
+		event.CreatedAt = tx.now
+		if err := db.auditRepo.SaveTx(tx, event); err != nil {
+			return fmt.Errorf("AppendAudit: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListAuditEvents returns a page of the events matching filter, the most recent first.
+func (db *DBManager) ListAuditEvents(filter AuditFilter, opts ListOptions) (Page[*models.AuditEvent], error) {
+	query := db.auditRepo.Query().
+		Where(filter.match).
+		OrderByKey(func(e *models.AuditEvent) string { return timeKey(e.CreatedAt) }, true)
+	if filter.Actor != "" {
+		query = query.ByIndex(indexByUser, filter.Actor)
+	}
+	return applyListOptions(query, opts).Page()
+}
@@ -2,52 +2,155 @@ package memdb
 
 import (
 	"fmt"
+	"log"
 	"sync"
 
 	"github.com/matheuspolitano/quiz-go/backend/internal/models"
 )
 
+// Collection names used to store the repositories.
+const (
+	usersCollection         = "users"
+	historyCollection       = "history"
+	questionsCollection     = "questions"
+	typesQuizCollection     = "typesQuiz"
+	questionsFlowCollection = "questionsFlows"
+	quarantineCollection    = "quarantine"
+	sessionsCollection      = "sessions"
+	revocationsCollection   = "revocations"
+	apiKeysCollection       = "apiKeys"
+	auditCollection         = "auditLog"
+)
+
+// Secondary indexes declared on the repositories.
+const (
+	indexByTypeQuiz = "type_quiz"
+	indexByUser     = "user"
+	indexByQuestion = "question"
+	indexBySubject  = "oidc_subject"
+)
+
 type DBManager struct {
 	userProgressRepo  *Repository[*models.User]
 	historyRepo       *Repository[*models.History]
 	questionRepo      *Repository[*models.Question]
 	TypeQuizRepo      *Repository[*models.TypeQuiz]
 	questionsFlowRepo *Repository[*models.QuestionFlow]
+	quarantineRepo    *Repository[*QuarantineEntry]
+	sessionRepo       *Repository[*models.Session]
+	revocationRepo    *Repository[*models.Revocation]
+	apiKeyRepo        *Repository[*models.APIKey]
+	auditRepo         *Repository[*models.AuditEvent]
 
-	globalMu sync.Mutex
+	driver Driver
+	txMu   sync.Mutex
+	// bankDir holds the question bank files kept in step with the store, bankStale
+	// the error of the last write back while the files miss a change. Both are guarded by txMu.
+	bankDir   string
+	bankStale error
 }
 
-func NewDBManager() (*DBManager, error) {
-	userRepo, err := NewRepositoryDefault[*models.User]("users")
+// NewDBManager loads every collection through the given driver
+func NewDBManager(driver Driver) (*DBManager, error) {
+	userRepo, err := NewRepository[*models.User](driver, usersCollection)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user progress repo: %v", err)
 	}
 
-	historyRepo, err := NewRepositoryDefault[*models.History]("history")
+	historyRepo, err := NewRepository[*models.History](driver, historyCollection)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create history repo: %v", err)
 	}
 
-	questionRepo, err := NewRepositoryDefault[*models.Question]("questions")
+	questionRepo, err := NewRepository[*models.Question](driver, questionsCollection)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create question repo: %v", err)
 	}
 
-	TypeQuizRepo, err := NewRepositoryDefault[*models.TypeQuiz]("typesQuiz")
+	TypeQuizRepo, err := NewRepository[*models.TypeQuiz](driver, typesQuizCollection)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create type question repo: %v", err)
 	}
 
-	questionsFlowRepo, err := NewRepositoryDefault[*models.QuestionFlow]("questionsFlows")
+	questionsFlowRepo, err := NewRepository[*models.QuestionFlow](driver, questionsFlowCollection)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create question repo: %v", err)
 	}
 
-	return &DBManager{
+	quarantineRepo, err := NewRepository[*QuarantineEntry](driver, quarantineCollection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create quarantine repo: %v", err)
+	}
+
+	sessionRepo, err := NewRepository[*models.Session](driver, sessionsCollection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session repo: %v", err)
+	}
+
+	revocationRepo, err := NewRepository[*models.Revocation](driver, revocationsCollection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create revocation repo: %v", err)
+	}
+
+	apiKeyRepo, err := NewRepository[*models.APIKey](driver, apiKeysCollection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create api key repo: %v", err)
+	}
+
+	auditRepo, err := NewRepository[*models.AuditEvent](driver, auditCollection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audit repo: %v", err)
+	}
+
+	questionsFlowRepo.AddIndex(indexByTypeQuiz, func(f *models.QuestionFlow) []string {
+		return []string{f.TypeQuizName}
+	})
+	questionsFlowRepo.AddIndex(indexByUser, func(f *models.QuestionFlow) []string {
+		return []string{f.UserID}
+	})
+	historyRepo.AddIndex(indexByUser, func(h *models.History) []string {
+		return []string{h.UserID}
+	})
+	historyRepo.AddIndex(indexByQuestion, func(h *models.History) []string {
+		return []string{h.QuestionID}
+	})
+	userRepo.AddIndex(indexBySubject, func(u *models.User) []string {
+		if u.OIDCSubject == "" {
+			return nil
+		}
+		return []string{u.OIDCSubject}
+	})
+	auditRepo.AddIndex(indexByUser, func(e *models.AuditEvent) []string {
+		return []string{e.Actor}
+	})
+	sessionRepo.AddIndex(indexByUser, func(s *models.Session) []string {
+		return []string{s.Username}
+	})
+
+	db := &DBManager{
 		userProgressRepo:  userRepo,
 		historyRepo:       historyRepo,
 		questionRepo:      questionRepo,
 		TypeQuizRepo:      TypeQuizRepo,
 		questionsFlowRepo: questionsFlowRepo,
-	}, nil
+		quarantineRepo:    quarantineRepo,
+		sessionRepo:       sessionRepo,
+		revocationRepo:    revocationRepo,
+		apiKeyRepo:        apiKeyRepo,
+		auditRepo:         auditRepo,
+		driver:            driver,
+	}
+	migrated, err := db.migrateFlowAttempts()
+	if err != nil {
+		return nil, err
+	}
+	if migrated > 0 {
+		log.Printf("Numbered %d question flows stored before attempts as attempt 1", migrated)
+	}
+	return db, nil
+}
+
+// Close releases the underlying driver
+func (db *DBManager) Close() error {
+	return db.driver.Close()
 }
@@ -0,0 +1,281 @@
+package memdb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+var (
+	ErrQuestionInUse    = errors.New("question is used by a quiz type or an attempt")
+	ErrQuestionAnswered = errors.New("question has answers, soft delete it instead")
+)
+
+// DeleteOptions controls how the Delete methods of DBManager remove an entry.
+type DeleteOptions struct {
+	// Soft tombstones the entry instead of removing it, it can be restored later
+	Soft bool
+	// Cascade removes the references to the entry instead of refusing the delete
+	Cascade bool
+}
+
+// DeleteUser removes a user with their question flows and history.
+// A soft delete tombstones the user and their flows and keeps the history.
+func (db *DBManager) DeleteUser(username string, opts DeleteOptions) error {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	user, err := db.userProgressRepo.findTx(tx, username)
+	if err != nil {
+		return fmt.Errorf("DeleteUser: %w", err)
+	}
+	if user.DeletedAt == nil && user.GetRole() == models.RoleAdmin {
+		admins, err := db.countAdmins()
+		if err != nil {
+			return fmt.Errorf("DeleteUser: %w", err)
+		}
+		if admins <= 1 {
+			return fmt.Errorf("DeleteUser: %w", ErrLastAdmin)
+		}
+	}
+	flows, err := db.questionsFlowRepo.Query().ByIndex(indexByUser, username).WithDeleted().All()
+	if err != nil {
+		return fmt.Errorf("DeleteUser: %w", err)
+	}
+	// sessions are never restored, a restored user logs in again
+	if err := db.deleteSessionsTx(tx, username, func(*models.Session) bool { return true }); err != nil {
+		return fmt.Errorf("DeleteUser: %w", err)
+	}
+
+	if opts.Soft {
+		if user.DeletedAt != nil {
+			return fmt.Errorf("DeleteUser: %w", ErrNotFound)
+		}
+		for _, flow := range flows {
+			if flow.DeletedAt == nil {
+				if err := db.questionsFlowRepo.SoftDeleteTx(tx, flow.GetID()); err != nil {
+					return fmt.Errorf("DeleteUser: %w", err)
+				}
+			}
+		}
+		if err := db.userProgressRepo.SoftDeleteTx(tx, username); err != nil {
+			return fmt.Errorf("DeleteUser: %w", err)
+		}
+		return tx.Commit()
+	}
+
+	history, err := db.historyRepo.FindByIndex(indexByUser, username)
+	if err != nil {
+		return fmt.Errorf("DeleteUser: %w", err)
+	}
+	for _, hist := range history {
+		if err := db.historyRepo.DeleteTx(tx, hist.ID); err != nil {
+			return fmt.Errorf("DeleteUser: %w", err)
+		}
+	}
+	for _, flow := range flows {
+		if err := db.questionsFlowRepo.DeleteTx(tx, flow.GetID()); err != nil {
+			return fmt.Errorf("DeleteUser: %w", err)
+		}
+	}
+	if err := db.userProgressRepo.DeleteTx(tx, username); err != nil {
+		return fmt.Errorf("DeleteUser: %w", err)
+	}
+	return tx.Commit()
+}
+
+// RestoreUser removes the tombstone of a user and of the flows deleted along with them.
+func (db *DBManager) RestoreUser(username string) error {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	user, err := db.userProgressRepo.findTx(tx, username)
+	if err != nil {
+		return fmt.Errorf("RestoreUser: %w", err)
+	}
+	if user.DeletedAt == nil {
+		return fmt.Errorf("RestoreUser: %w", ErrNotDeleted)
+	}
+	flows, err := db.questionsFlowRepo.Query().ByIndex(indexByUser, username).WithDeleted().All()
+	if err != nil {
+		return fmt.Errorf("RestoreUser: %w", err)
+	}
+	for _, flow := range flows {
+		if flow.DeletedAt != nil && flow.DeletedAt.Equal(*user.DeletedAt) {
+			if err := db.questionsFlowRepo.RestoreTx(tx, flow.GetID()); err != nil {
+				return fmt.Errorf("RestoreUser: %w", err)
+			}
+		}
+	}
+	if err := db.userProgressRepo.RestoreTx(tx, username); err != nil {
+		return fmt.Errorf("RestoreUser: %w", err)
+	}
+	return tx.Commit()
+}
+
+// DeleteQuestion removes a question. It is refused while a quiz type lists it,
+// unless opts.Cascade removes it from those quiz types too, and while an open
+// attempt drew it, the attempt still has to ask it. Answered questions can only
+// be soft deleted, their history keeps pointing to them.
+func (db *DBManager) DeleteQuestion(id string, opts DeleteOptions) error {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	question, err := db.questionRepo.findTx(tx, id)
+	if err != nil {
+		return fmt.Errorf("DeleteQuestion: %w", err)
+	}
+	if opts.Soft && question.DeletedAt != nil {
+		return fmt.Errorf("DeleteQuestion: %w", ErrNotFound)
+	}
+
+	types, err := db.TypeQuizRepo.Query().WithDeleted().
+		Where(func(t *models.TypeQuiz) bool { return containsString(t.QuestionsID, id) }).
+		All()
+	if err != nil {
+		return fmt.Errorf("DeleteQuestion: %w", err)
+	}
+	if len(types) > 0 && !opts.Cascade {
+		names := make([]string, 0, len(types))
+		for _, t := range types {
+			names = append(names, t.Name)
+		}
+		return fmt.Errorf("DeleteQuestion: %w: %s", ErrQuestionInUse, strings.Join(names, ", "))
+	}
+	// the questions drawn for an attempt are frozen, a hard delete would leave closed ones pointing nowhere
+	flows, err := db.questionsFlowRepo.Query().WithDeleted().
+		Where(func(f *models.QuestionFlow) bool {
+			return containsString(f.QuestionsID, id) && (!opts.Soft || f.ClosedAt.IsZero())
+		}).
+		All()
+	if err != nil {
+		return fmt.Errorf("DeleteQuestion: %w", err)
+	}
+	if len(flows) > 0 {
+		ids := make([]string, 0, len(flows))
+		for _, f := range flows {
+			ids = append(ids, f.GetID())
+		}
+		return fmt.Errorf("DeleteQuestion: %w: drawn by %s", ErrQuestionInUse, strings.Join(ids, ", "))
+	}
+	for _, t := range types {
+		t.QuestionsID = removeString(t.QuestionsID, id)
+		if err := db.TypeQuizRepo.SaveTx(tx, t); err != nil {
+			return fmt.Errorf("DeleteQuestion: %w", err)
+		}
+	}
+
+	if opts.Soft {
+		if err := db.questionRepo.SoftDeleteTx(tx, id); err != nil {
+			return fmt.Errorf("DeleteQuestion: %w", err)
+		}
+		return tx.Commit()
+	}
+
+	answers, err := db.historyRepo.Query().ByIndex(indexByQuestion, id).Count()
+	if err != nil {
+		return fmt.Errorf("DeleteQuestion: %w", err)
+	}
+	if answers > 0 {
+		return fmt.Errorf("DeleteQuestion: %w", ErrQuestionAnswered)
+	}
+	if err := db.questionRepo.DeleteTx(tx, id); err != nil {
+		return fmt.Errorf("DeleteQuestion: %w", err)
+	}
+	return tx.Commit()
+}
+
+// RestoreQuestion removes the tombstone of a question. Quiz types it was
+// cascaded out of are left untouched.
+func (db *DBManager) RestoreQuestion(id string) error {
+	return db.Update(func(tx *Tx) error {
+		if err := db.questionRepo.RestoreTx(tx, id); err != nil {
+			return fmt.Errorf("RestoreQuestion: %w", err)
+		}
+		return nil
+	})
+}
+
+// DeleteQuestionFlow removes an attempt of a user at a quiz type with its history,
+// or every attempt when attempt is 0, which gives the user their attempts back.
+// A soft delete keeps the attempts as tombstones.
+func (db *DBManager) DeleteQuestionFlow(userID, typeQuizName string, attempt int, opts DeleteOptions) error {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	flows, err := db.selectAttempts(userID, typeQuizName, attempt, func(f *models.QuestionFlow) bool {
+		return !opts.Soft || f.DeletedAt == nil
+	})
+	if err != nil {
+		return fmt.Errorf("DeleteQuestionFlow: %w", err)
+	}
+	if opts.Soft {
+		for _, flow := range flows {
+			if err := db.questionsFlowRepo.SoftDeleteTx(tx, flow.GetID()); err != nil {
+				return fmt.Errorf("DeleteQuestionFlow: %w", err)
+			}
+		}
+		return tx.Commit()
+	}
+
+	user, userErr := db.userProgressRepo.findTx(tx, userID)
+	for _, flow := range flows {
+		for _, histID := range flow.History {
+			if err := db.historyRepo.DeleteTx(tx, histID); err != nil && !errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("DeleteQuestionFlow: %w", err)
+			}
+		}
+		if err := db.questionsFlowRepo.DeleteTx(tx, flow.GetID()); err != nil {
+			return fmt.Errorf("DeleteQuestionFlow: %w", err)
+		}
+		if userErr == nil {
+			user.QuestionsFlowsID = removeString(user.QuestionsFlowsID, flow.GetID())
+		}
+	}
+	if userErr == nil {
+		if err := db.userProgressRepo.SaveTx(tx, user); err != nil {
+			return fmt.Errorf("DeleteQuestionFlow: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// RestoreQuestionFlow removes the tombstone of a soft deleted attempt, or of every
+// soft deleted attempt of the quiz type when attempt is 0.
+func (db *DBManager) RestoreQuestionFlow(userID, typeQuizName string, attempt int) error {
+	flows, err := db.selectAttempts(userID, typeQuizName, attempt, func(f *models.QuestionFlow) bool {
+		return attempt != 0 || f.DeletedAt != nil
+	})
+	if err != nil {
+		return fmt.Errorf("RestoreQuestionFlow: %w", err)
+	}
+	return db.Update(func(tx *Tx) error {
+		for _, flow := range flows {
+			if err := db.questionsFlowRepo.RestoreTx(tx, flow.GetID()); err != nil {
+				return fmt.Errorf("RestoreQuestionFlow: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// selectAttempts returns the attempt of a user at a quiz type, or every attempt when attempt is 0,
+// keeping those matching keep. Nothing left to act on is ErrNotFound.
+func (db *DBManager) selectAttempts(userID, typeQuizName string, attempt int, keep func(*models.QuestionFlow) bool) ([]*models.QuestionFlow, error) {
+	attempts, err := db.attempts(userID, typeQuizName)
+	if err != nil {
+		return nil, err
+	}
+	selected := make([]*models.QuestionFlow, 0, len(attempts))
+	for _, flow := range attempts {
+		if (attempt == 0 || flow.Attempt == attempt) && keep(flow) {
+			selected = append(selected, flow)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, ErrNotFound
+	}
+	return selected, nil
+}
@@ -0,0 +1,62 @@
+package memdb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+// TestDeleteQuestionDrawnByOpenAttempt checks that a question drawn into an open attempt
+// cannot be deleted, the attempt still asks it once no quiz type lists it.
+func TestDeleteQuestionDrawnByOpenAttempt(t *testing.T) {
+	db, err := NewDBManager(NewMemoryDriver())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, q := range []*models.Question{
+		{ID: "q1", Prompt: "2 + 2?", Options: []string{"A: 3", "B: 4"}, Answer: "B"},
+		{ID: "q2", Prompt: "3 + 3?", Options: []string{"A: 6", "B: 7"}, Answer: "A"},
+	} {
+		if _, err := db.CreateQuestion(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// without listed questions the pool draws from the whole bank
+	if _, err := db.CreateTypeQuiz(&models.TypeQuiz{Name: "Maths", Pool: &models.QuestionPool{Draw: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateUser("alice", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	flow, err := db.AddQuestionFlow("alice", "Maths")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, soft := range []bool{true, false} {
+		err := db.DeleteQuestion("q2", DeleteOptions{Soft: soft, Cascade: true})
+		if !errors.Is(err, ErrQuestionInUse) {
+			t.Errorf("DeleteQuestion(soft=%v): got %v, want %v", soft, err, ErrQuestionInUse)
+		}
+	}
+
+	for range flow.QuestionsID {
+		question, err := db.NextQuestion(flow.GetID())
+		if err != nil {
+			t.Fatalf("NextQuestion: %v", err)
+		}
+		if _, err := db.AddAnswer(flow.GetID(), question.ID, "A"); err != nil {
+			t.Fatalf("AddAnswer: %v", err)
+		}
+	}
+	if _, err := db.NextQuestion(flow.GetID()); !errors.Is(err, ErrAllQuestionsAnswered) {
+		t.Fatalf("NextQuestion: got %v, want %v", err, ErrAllQuestionsAnswered)
+	}
+	// once the attempt is closed the question may be retired
+	if err := db.DeleteQuestion("q2", DeleteOptions{Soft: true}); err != nil {
+		t.Errorf("soft delete after the attempt closed: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package memdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/matheuspolitano/quiz-go/backend/internal/config"
+)
+
+// OpKind identifies the kind of change carried by an Op.
+type OpKind string
+
+const (
+	// OpPut inserts or replaces a document.
+	OpPut OpKind = "put"
+	// OpDelete removes a document, deleting a missing document is not an error.
+	OpDelete OpKind = "delete"
+)
+
+// Op is a single change to be persisted by a Driver.
+type Op struct {
+	Collection string          `json:"collection"`
+	Kind       OpKind          `json:"kind"`
+	ID         string          `json:"id"`
+	Data       json.RawMessage `json:"data,omitempty"`
+}
+
+// KeyFunc decodes a stored document and returns its ID.
+type KeyFunc func(data json.RawMessage) (string, error)
+
+// Driver persists the collections cached by the repositories.
+// The repositories keep every entry in memory, the driver only has to
+// load them at startup and store the changes.
+type Driver interface {
+	// Load returns every document of a collection.
+	Load(collection string, key KeyFunc) ([]json.RawMessage, error)
+
+	// Apply persists a batch of operations.
+	Apply(ops []Op) error
+
+	// Close releases the resources held by the driver.
+	Close() error
+}
+
+// memoryDriver keeps the documents in a map, data lives only as long as the process.
+type memoryDriver struct {
+	collections map[string]map[string]json.RawMessage
+	mu          sync.Mutex
+}
+
+// NewMemoryDriver creates a Driver without persistence, useful for tests and demos.
+func NewMemoryDriver() Driver {
+	return &memoryDriver{collections: make(map[string]map[string]json.RawMessage)}
+}
+
+func (d *memoryDriver) Load(collection string, _ KeyFunc) ([]json.RawMessage, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	docs := make([]json.RawMessage, 0, len(d.collections[collection]))
+	for _, doc := range d.collections[collection] {
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+func (d *memoryDriver) Apply(ops []Op) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, op := range ops {
+		if op.Kind != OpPut && op.Kind != OpDelete {
+			return fmt.Errorf("%w: unknown operation %q", ErrInvalidEntry, op.Kind)
+		}
+	}
+	for _, op := range ops {
+		docs, ok := d.collections[op.Collection]
+		if !ok {
+			docs = make(map[string]json.RawMessage)
+			d.collections[op.Collection] = docs
+		}
+		_ = applyOp(docs, op)
+	}
+	return nil
+}
+
+func (d *memoryDriver) Close() error { return nil }
+
+// OpenDriver creates the Driver selected by STORAGE_DRIVER.
+func OpenDriver(cfg config.Config) (Driver, error) {
+	switch cfg.StorageDriver {
+	case "", "json":
+		return NewJSONDriver(cfg.DataDir, time.Second*time.Duration(cfg.JournalCompactInterval)), nil
+	case "memory":
+		// seed the memory driver with the question banks so the server is usable
+		driver := NewMemoryDriver()
+		if _, err := ImportJSON(driver, cfg.DataDir); err != nil {
+			return nil, err
+		}
+		return driver, nil
+	case "sqlite":
+		driver, err := newSQLiteDriver(cfg.SQLitePath)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.SQLiteImportJSON {
+			if err := importJSONOnce(driver, cfg.DataDir); err != nil {
+				driver.Close()
+				return nil, err
+			}
+		}
+		return driver, nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+	}
+}
@@ -0,0 +1,368 @@
+package memdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matheuspolitano/quiz-go/backend/internal/grading"
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+// QuarantineEntry keeps a record removed by Fsck, so it can be inspected
+// or restored by hand instead of being lost.
+type QuarantineEntry struct {
+	ID            string          `json:"id"`
+	Collection    string          `json:"collection"`
+	EntryID       string          `json:"entry_id"`
+	Reason        string          `json:"reason"`
+	Data          json.RawMessage `json:"data"`
+	QuarantinedAt time.Time       `json:"quarantined_at"`
+}
+
+// Implement the Identifiable interface
+func (q *QuarantineEntry) GetID() string {
+	return q.ID
+}
+
+// Issue is a broken reference found by Fsck.
+type Issue struct {
+	Collection string `json:"collection"`
+	ID         string `json:"id"`
+	Problem    string `json:"problem"`
+	Repair     string `json:"repair"`
+}
+
+// FsckReport lists the issues found by Fsck and whether they were repaired.
+type FsckReport struct {
+	Issues   []Issue `json:"issues"`
+	Repaired bool    `json:"repaired"`
+}
+
+func (r *FsckReport) add(collection, id, repair, problem string, args ...any) {
+	r.Issues = append(r.Issues, Issue{
+		Collection: collection,
+		ID:         id,
+		Problem:    fmt.Sprintf(problem, args...),
+		Repair:     repair,
+	})
+}
+
+const (
+	repairDropReference = "drop reference"
+	repairQuarantine    = "quarantine"
+	repairFixAnswer     = "copy answer from question"
+	repairLinkFlow      = "link flow to user"
+)
+
+// fsckState holds a copy of every collection while it is being checked.
+type fsckState struct {
+	users     map[string]*models.User
+	questions map[string]*models.Question
+	types     map[string]*models.TypeQuiz
+	flows     map[string]*models.QuestionFlow
+	history   map[string]*models.History
+
+	dirty       map[string]map[string]bool
+	quarantined map[string]map[string]string
+}
+
+func (s *fsckState) markDirty(collection, id string) {
+	if s.dirty[collection] == nil {
+		s.dirty[collection] = make(map[string]bool)
+	}
+	s.dirty[collection][id] = true
+}
+
+func (s *fsckState) quarantine(collection, id, reason string) {
+	if s.quarantined[collection] == nil {
+		s.quarantined[collection] = make(map[string]string)
+	}
+	s.quarantined[collection][id] = reason
+}
+
+func (s *fsckState) isQuarantined(collection, id string) bool {
+	_, ok := s.quarantined[collection][id]
+	return ok
+}
+
+// Fsck checks the references between every collection and reports the broken ones.
+// With repair set, dangling references are dropped, answers are re-synced with
+// their question and records that cannot be fixed are moved to the quarantine
+// collection, all in a single transaction.
+func (db *DBManager) Fsck(repair bool) (*FsckReport, error) {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	state := &fsckState{
+		users:       indexByID(db.userProgressRepo),
+		questions:   indexByID(db.questionRepo),
+		types:       indexByID(db.TypeQuizRepo),
+		flows:       indexByID(db.questionsFlowRepo),
+		history:     indexByID(db.historyRepo),
+		dirty:       make(map[string]map[string]bool),
+		quarantined: make(map[string]map[string]string),
+	}
+	report := &FsckReport{}
+
+	db.checkTypeQuizzes(state, report)
+	db.checkFlows(state, report)
+	db.checkHistory(state, report)
+	db.checkUsers(state, report)
+	recomputeAccuracy(state)
+
+	if !repair || len(report.Issues) == 0 {
+		return report, nil
+	}
+	if err := db.applyRepairs(tx, state); err != nil {
+		return nil, fmt.Errorf("Fsck: failed to stage repairs: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("Fsck: %w", err)
+	}
+	report.Repaired = true
+	return report, nil
+}
+
+func (db *DBManager) checkTypeQuizzes(state *fsckState, report *FsckReport) {
+	for _, name := range sortedKeys(state.types) {
+		typeQuiz := state.types[name]
+		kept := make([]string, 0, len(typeQuiz.QuestionsID))
+		for _, qID := range typeQuiz.QuestionsID {
+			if _, ok := state.questions[qID]; !ok {
+				report.add(typesQuizCollection, name, repairDropReference, "references unknown question %s", qID)
+				continue
+			}
+			kept = append(kept, qID)
+		}
+		if len(kept) != len(typeQuiz.QuestionsID) {
+			typeQuiz.QuestionsID = kept
+			state.markDirty(typesQuizCollection, name)
+		}
+	}
+}
+
+func (db *DBManager) checkFlows(state *fsckState, report *FsckReport) {
+	for _, id := range sortedKeys(state.flows) {
+		flow := state.flows[id]
+		if _, ok := state.users[flow.UserID]; !ok {
+			report.add(questionsFlowCollection, id, repairQuarantine, "belongs to unknown user %s", flow.UserID)
+			state.quarantine(questionsFlowCollection, id, "unknown user")
+			continue
+		}
+		if _, ok := state.types[flow.TypeQuizName]; !ok {
+			report.add(questionsFlowCollection, id, repairQuarantine, "uses unknown quiz type %s", flow.TypeQuizName)
+			state.quarantine(questionsFlowCollection, id, "unknown quiz type")
+			continue
+		}
+
+		drawn := make([]string, 0, len(flow.QuestionsID))
+		for _, qID := range flow.QuestionsID {
+			if _, ok := state.questions[qID]; !ok {
+				report.add(questionsFlowCollection, id, repairDropReference, "draws unknown question %s", qID)
+				continue
+			}
+			drawn = append(drawn, qID)
+		}
+		if len(drawn) != len(flow.QuestionsID) {
+			flow.QuestionsID = drawn
+			state.markDirty(questionsFlowCollection, id)
+		}
+
+		kept := make([]string, 0, len(flow.History))
+		for _, histID := range flow.History {
+			hist, ok := state.history[histID]
+			if !ok {
+				report.add(questionsFlowCollection, id, repairDropReference, "references missing history %s", histID)
+				continue
+			}
+			if hist.UserID != flow.UserID {
+				report.add(questionsFlowCollection, id, repairDropReference,
+					"references history %s of another user %s", histID, hist.UserID)
+				continue
+			}
+			kept = append(kept, histID)
+		}
+		if len(kept) != len(flow.History) {
+			flow.History = kept
+			state.markDirty(questionsFlowCollection, id)
+		}
+	}
+}
+
+func (db *DBManager) checkHistory(state *fsckState, report *FsckReport) {
+	referenced := make(map[string]string)
+	for _, id := range sortedKeys(state.flows) {
+		if state.isQuarantined(questionsFlowCollection, id) {
+			continue
+		}
+		for _, histID := range state.flows[id].History {
+			referenced[histID] = id
+		}
+	}
+
+	for _, id := range sortedKeys(state.history) {
+		hist := state.history[id]
+		flowID, ok := referenced[id]
+		if !ok {
+			report.add(historyCollection, id, repairQuarantine, "is not referenced by any question flow")
+			state.quarantine(historyCollection, id, "orphan history")
+			continue
+		}
+		question, ok := state.questions[hist.QuestionID]
+		if !ok {
+			report.add(historyCollection, id, repairQuarantine, "answers unknown question %s", hist.QuestionID)
+			state.quarantine(historyCollection, id, "unknown question")
+			flow := state.flows[flowID]
+			flow.History = removeString(flow.History, id)
+			state.markDirty(questionsFlowCollection, flowID)
+			continue
+		}
+		if hist.ExpectedAnswer != question.Answer {
+			report.add(historyCollection, id, repairFixAnswer,
+				"expects answer %q but question %s now expects %q", hist.ExpectedAnswer, question.ID, question.Answer)
+			hist.ExpectedAnswer = question.Answer
+			if hist.Score != nil {
+				score := grading.Grade(question, hist.Answer)
+				hist.Score = &score
+			}
+			state.markDirty(historyCollection, id)
+			state.markDirty(questionsFlowCollection, flowID)
+		}
+	}
+}
+
+func (db *DBManager) checkUsers(state *fsckState, report *FsckReport) {
+	for _, username := range sortedKeys(state.users) {
+		user := state.users[username]
+		listed := make(map[string]bool, len(user.QuestionsFlowsID))
+		kept := make([]string, 0, len(user.QuestionsFlowsID))
+		for _, flowID := range user.QuestionsFlowsID {
+			if _, ok := state.flows[flowID]; !ok || state.isQuarantined(questionsFlowCollection, flowID) {
+				report.add(usersCollection, username, repairDropReference, "references missing question flow %s", flowID)
+				continue
+			}
+			listed[flowID] = true
+			kept = append(kept, flowID)
+		}
+		for _, flowID := range sortedKeys(state.flows) {
+			flow := state.flows[flowID]
+			if flow.UserID != username || listed[flowID] || state.isQuarantined(questionsFlowCollection, flowID) {
+				continue
+			}
+			report.add(usersCollection, username, repairLinkFlow, "does not list its question flow %s", flowID)
+			kept = append(kept, flowID)
+		}
+		if len(kept) != len(user.QuestionsFlowsID) || len(listed) != len(user.QuestionsFlowsID) {
+			user.QuestionsFlowsID = kept
+			state.markDirty(usersCollection, username)
+		}
+	}
+}
+
+// recomputeAccuracy refreshes the accuracy of every flow touched by a repair.
+func recomputeAccuracy(state *fsckState) {
+	for flowID := range state.dirty[questionsFlowCollection] {
+		flow := state.flows[flowID]
+		var credit float32
+		for _, histID := range flow.History {
+			credit += state.history[histID].Credit()
+		}
+		if len(flow.History) > 0 {
+			flow.AccuracyRate = credit / float32(len(flow.History))
+		} else {
+			flow.AccuracyRate = 1.0
+		}
+	}
+}
+
+func (db *DBManager) applyRepairs(tx *Tx, state *fsckState) error {
+	for id := range state.dirty[usersCollection] {
+		if err := db.userProgressRepo.SaveTx(tx, state.users[id]); err != nil {
+			return err
+		}
+	}
+	for id := range state.dirty[typesQuizCollection] {
+		if err := db.TypeQuizRepo.SaveTx(tx, state.types[id]); err != nil {
+			return err
+		}
+	}
+	for id := range state.dirty[questionsFlowCollection] {
+		if state.isQuarantined(questionsFlowCollection, id) {
+			continue
+		}
+		if err := db.questionsFlowRepo.SaveTx(tx, state.flows[id]); err != nil {
+			return err
+		}
+	}
+	for id := range state.dirty[historyCollection] {
+		if state.isQuarantined(historyCollection, id) {
+			continue
+		}
+		if err := db.historyRepo.SaveTx(tx, state.history[id]); err != nil {
+			return err
+		}
+	}
+
+	for id, reason := range state.quarantined[questionsFlowCollection] {
+		if err := quarantineTx(tx, db, db.questionsFlowRepo, state.flows[id], reason); err != nil {
+			return err
+		}
+	}
+	for id, reason := range state.quarantined[historyCollection] {
+		if err := quarantineTx(tx, db, db.historyRepo, state.history[id], reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// quarantineTx moves entity out of repo and into the quarantine collection.
+func quarantineTx[T Identifiable](tx *Tx, db *DBManager, repo *Repository[T], entity T, reason string) error {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return err
+	}
+	entry := &QuarantineEntry{
+		ID:            uuid.NewString(),
+		Collection:    repo.collection,
+		EntryID:       entity.GetID(),
+		Reason:        reason,
+		Data:          data,
+		QuarantinedAt: time.Now(),
+	}
+	if err := db.quarantineRepo.SaveTx(tx, entry); err != nil {
+		return err
+	}
+	return repo.DeleteTx(tx, entity.GetID())
+}
+
+func indexByID[T Identifiable](repo *Repository[T]) map[string]T {
+	items, _ := repo.Query().WithDeleted().All()
+	index := make(map[string]T, len(items))
+	for _, item := range items {
+		index[item.GetID()] = item
+	}
+	return index
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func removeString(list []string, value string) []string {
+	kept := make([]string, 0, len(list))
+	for _, item := range list {
+		if item != value {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
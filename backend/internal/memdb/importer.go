@@ -0,0 +1,164 @@
+package memdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+const jsonImportKey = "json_import"
+
+// importKeys lists the collections copied by ImportJSON with the key of their entries
+var importKeys = map[string]KeyFunc{
+	usersCollection:         documentKey[*models.User],
+	historyCollection:       documentKey[*models.History],
+	questionsCollection:     documentKey[*models.Question],
+	typesQuizCollection:     documentKey[*models.TypeQuiz],
+	questionsFlowCollection: documentKey[*models.QuestionFlow],
+	quarantineCollection:    documentKey[*QuarantineEntry],
+	sessionsCollection:      documentKey[*models.Session],
+	revocationsCollection:   documentKey[*models.Revocation],
+	apiKeysCollection:       documentKey[*models.APIKey],
+	auditCollection:         documentKey[*models.AuditEvent],
+}
+
+// ImportJSON copies every collection stored by the JSON driver in dir into dst.
+// The snapshots are read with the journals and the committed batches of the
+// transaction log replayed over them, as the driver would load them, but nothing
+// in dir is written. Entries are written with put operations, so running it
+// twice is harmless.
+func ImportJSON(dst Driver, dir string) (int, error) {
+	collections := make(map[string]map[string]json.RawMessage, len(importKeys))
+	for collection, key := range importKeys {
+		docs, err := readJSONDocs(dir, collection, key)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read JSON collection %s: %w", collection, err)
+		}
+		collections[collection] = docs
+	}
+	if err := replayTxLog(dir, collections); err != nil {
+		return 0, fmt.Errorf("failed to read JSON data: %w", err)
+	}
+
+	var ops []Op
+	for collection, docs := range collections {
+		for id, doc := range docs {
+			ops = append(ops, Op{Collection: collection, Kind: OpPut, ID: id, Data: doc})
+		}
+	}
+	if len(ops) == 0 {
+		return 0, nil
+	}
+	if err := dst.Apply(ops); err != nil {
+		return 0, fmt.Errorf("failed to import JSON data: %w", err)
+	}
+	return len(ops), nil
+}
+
+// readJSONDocs reads the snapshot of collection in dir and replays its journal over it, read only.
+func readJSONDocs(dir, collection string, key KeyFunc) (map[string]json.RawMessage, error) {
+	docs, err := decodeSnapshot(filepath.Join(dir, fmt.Sprintf(defaultDBFilename, collection)), key)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, fmt.Sprintf(defaultJournalFilename, collection))
+	journal, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return docs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to open journal '%s': %v", path, err)
+	}
+	defer journal.Close()
+	if _, _, err := scanJournal(journal, path, docs); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// replayTxLog applies the batches committed to the transaction log of dir before a crash,
+// which the driver would recover on its next start, over the collections read from dir.
+func replayTxLog(dir string, collections map[string]map[string]json.RawMessage) error {
+	path := filepath.Join(dir, txLogFilename)
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to open transaction log '%s': %v", path, err)
+	}
+	defer file.Close()
+	batches, err := scanTxLog(file, path)
+	if err != nil {
+		return err
+	}
+	for _, ops := range batches {
+		for _, op := range ops {
+			if docs, ok := collections[op.Collection]; ok {
+				if err := applyOp(docs, op); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// documentKey decodes a stored T and returns its ID, rejecting entries without ID.
+func documentKey[T Identifiable](data json.RawMessage) (string, error) {
+	var item T
+	if err := json.Unmarshal(data, &item); err != nil {
+		return "", fmt.Errorf("error decoding entry: %v", err)
+	}
+	if item.GetID() == "" {
+		return "", fmt.Errorf("%w: empty ID", ErrInvalidEntry)
+	}
+	return item.GetID(), nil
+}
+
+// readJSONFile decodes dir/<collection>.data.json, a missing file is an empty collection.
+func readJSONFile[T Identifiable](dir, collection string) ([]T, error) {
+	path := filepath.Join(dir, fmt.Sprintf(defaultDBFilename, collection))
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to read '%s': %v", path, err)
+	}
+
+	var items []T
+	if err := json.Unmarshal(content, &items); err != nil {
+		return nil, fmt.Errorf("error decoding JSON from '%s': %v", path, err)
+	}
+	for _, item := range items {
+		if item.GetID() == "" {
+			return nil, fmt.Errorf("%w: empty ID in '%s'", ErrInvalidEntry, path)
+		}
+	}
+	return items, nil
+}
+
+// importJSONOnce imports the JSON data directory into a fresh SQLite database.
+// The import is recorded in the metadata table so it only ever runs once.
+func importJSONOnce(driver *sqliteDriver, dir string) error {
+	done, err := driver.metadata(jsonImportKey)
+	if err != nil {
+		return fmt.Errorf("unable to read import status: %v", err)
+	}
+	if done != "" {
+		return nil
+	}
+
+	count, err := ImportJSON(driver, dir)
+	if err != nil {
+		return err
+	}
+	log.Printf("Imported %d entries from '%s' into sqlite", count, dir)
+	return driver.setMetadata(jsonImportKey, time.Now().UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,85 @@
+package memdb
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestImportJSONReadsJournals checks that the entries written since the last snapshot,
+// which only live in the journals, are imported with every collection, and that the
+// import leaves the source directory as it was.
+func TestImportJSONReadsJournals(t *testing.T) {
+	dir := t.TempDir()
+	writeBank(t, dir)
+
+	src := NewJSONDriver(dir, 0)
+	defer src.Close()
+	want := map[string]string{
+		usersCollection:         `{"username": "alice"}`,
+		sessionsCollection:      `{"id": "s1", "username": "alice"}`,
+		revocationsCollection:   `{"id": "user:alice"}`,
+		apiKeysCollection:       `{"id": "k1", "name": "ci"}`,
+		auditCollection:         `{"id": "a1", "action": "login"}`,
+		quarantineCollection:    `{"id": "questions/q9"}`,
+		historyCollection:       `{"id": "h1", "user_id": "alice"}`,
+		questionsFlowCollection: `{"user_id": "alice", "type_quiz": "Maths", "attempt": 1}`,
+	}
+	var ops []Op
+	for collection, doc := range want {
+		if _, err := src.Load(collection, importKeys[collection]); err != nil {
+			t.Fatal(err)
+		}
+		id, err := importKeys[collection](json.RawMessage(doc))
+		if err != nil {
+			t.Fatalf("%s: %v", collection, err)
+		}
+		ops = append(ops, Op{Collection: collection, Kind: OpPut, ID: id, Data: json.RawMessage(doc)})
+	}
+	if err := src.Apply(ops); err != nil {
+		t.Fatal(err)
+	}
+
+	before := readDir(t, dir)
+	dst := NewMemoryDriver()
+	count, err := ImportJSON(dst, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after := readDir(t, dir); !reflect.DeepEqual(before, after) {
+		t.Errorf("the import changed the source directory:\nbefore %v\nafter  %v", before, after)
+	}
+	// the bank files hold one question and one quiz type
+	if count != len(want)+2 {
+		t.Errorf("imported %d entries, want %d", count, len(want)+2)
+	}
+	for collection := range want {
+		docs, err := dst.Load(collection, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(docs) != 1 {
+			t.Errorf("%s: imported %d entries, want 1", collection, len(docs))
+		}
+	}
+}
+
+// readDir returns the content of every file in dir by name
+func readDir(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[entry.Name()] = string(content)
+	}
+	return files
+}
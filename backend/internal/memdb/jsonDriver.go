@@ -0,0 +1,535 @@
+package memdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDBFilename      = "%s.data.json"
+	defaultJournalFilename = "%s.journal"
+	txLogFilename          = "transactions.journal"
+	baseDir                = "./data"
+)
+
+// jsonDriver stores every collection as a JSON array snapshot in its own file,
+// plus an append-only journal with the operations applied since the last snapshot.
+// The journal is replayed on Load and folded into the snapshot by Compact.
+// Batches touching more than one entry are first written to a transaction log,
+// so a crash halfway through the collection journals is completed on restart.
+type jsonDriver struct {
+	dir         string
+	collections map[string]map[string]json.RawMessage
+	journals    map[string]*os.File
+	pending     map[string]int
+	txLog       *os.File
+	// failed is set when a committed batch could not reach the journals,
+	// writes are refused until a restart recovers it from the transaction log
+	failed error
+	mu     sync.Mutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewJSONDriver creates a Driver that keeps each collection in dir/<collection>.data.json
+// compactEvery schedules the journal compaction, zero only compacts on Close.
+func NewJSONDriver(dir string, compactEvery time.Duration) Driver {
+	d := &jsonDriver{
+		dir:         dir,
+		collections: make(map[string]map[string]json.RawMessage),
+		journals:    make(map[string]*os.File),
+		pending:     make(map[string]int),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	if compactEvery > 0 {
+		go d.compactLoop(compactEvery)
+	} else {
+		close(d.done)
+	}
+	return d
+}
+
+func (d *jsonDriver) filePath(collection string) string {
+	return filepath.Join(d.dir, fmt.Sprintf(defaultDBFilename, collection))
+}
+
+func (d *jsonDriver) journalPath(collection string) string {
+	return filepath.Join(d.dir, fmt.Sprintf(defaultJournalFilename, collection))
+}
+
+// Load reads the collection snapshot and replays its journal over it.
+func (d *jsonDriver) Load(collection string, key KeyFunc) ([]json.RawMessage, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	path := d.filePath(collection)
+	log.Printf("Loading data from '%s'", path)
+
+	// Ensure the directory exists.
+	dir := filepath.Dir(path)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if mkErr := os.MkdirAll(dir, 0755); mkErr != nil {
+			return nil, fmt.Errorf("unable to create directory '%s': %v", dir, mkErr)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("error checking directory '%s': %v", dir, err)
+	}
+
+	if d.txLog == nil {
+		if err := d.recoverTxLog(); err != nil {
+			return nil, err
+		}
+	}
+
+	docs, err := d.readSnapshot(path, key)
+	if err != nil {
+		return nil, err
+	}
+	replayed, err := d.replayJournal(collection, docs)
+	if err != nil {
+		return nil, err
+	}
+	d.collections[collection] = docs
+	d.pending[collection] = replayed
+
+	items := make([]json.RawMessage, 0, len(docs))
+	for _, doc := range docs {
+		items = append(items, doc)
+	}
+	return items, nil
+}
+
+// readSnapshot reads the snapshot file keyed by ID, creating an empty one when missing.
+func (d *jsonDriver) readSnapshot(path string, key KeyFunc) (map[string]json.RawMessage, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if createErr := atomicWriteFile(path, []byte("[]")); createErr != nil {
+			return nil, fmt.Errorf("unable to create data file: %v", createErr)
+		}
+		return make(map[string]json.RawMessage), nil
+	}
+	return decodeSnapshot(path, key)
+}
+
+// decodeSnapshot reads the snapshot file keyed by ID without writing anything,
+// a missing file is an empty collection.
+func decodeSnapshot(path string, key KeyFunc) (map[string]json.RawMessage, error) {
+	docs := make(map[string]json.RawMessage)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return docs, nil
+		}
+		return nil, fmt.Errorf("unable to open data file: %v", err)
+	}
+	if len(bytes.TrimSpace(content)) == 0 {
+		return docs, nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(content, &items); err != nil {
+		return nil, fmt.Errorf("error decoding JSON from file: %v", err)
+	}
+	for _, item := range items {
+		id, err := key(item)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding entry from file: %v", err)
+		}
+		docs[id] = item
+	}
+	return docs, nil
+}
+
+// replayJournal applies every journaled operation over docs and returns how many were replayed.
+// A torn last line (the process died mid-append) is dropped, anything else is an error.
+func (d *jsonDriver) replayJournal(collection string, docs map[string]json.RawMessage) (int, error) {
+	path := d.journalPath(collection)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return 0, fmt.Errorf("unable to open journal '%s': %v", path, err)
+	}
+
+	count, validSize, err := scanJournal(file, path, docs)
+	if err != nil {
+		file.Close()
+		return 0, err
+	}
+	if err := file.Truncate(validSize); err != nil {
+		file.Close()
+		return 0, fmt.Errorf("unable to truncate journal '%s': %v", path, err)
+	}
+	d.journals[collection] = file
+	return count, nil
+}
+
+// scanJournal applies the operations read from the journal at path over docs. It returns how many
+// were applied and the size of the entries read, a torn last line is left out of both.
+func scanJournal(r io.Reader, path string, docs map[string]json.RawMessage) (count int, validSize int64, err error) {
+	reader := bufio.NewReader(r)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return 0, 0, fmt.Errorf("unable to read journal '%s': %v", path, readErr)
+		}
+		if len(line) == 0 {
+			break
+		}
+
+		var op Op
+		if readErr == io.EOF || json.Unmarshal(line, &op) != nil {
+			if readErr == io.EOF {
+				log.Printf("Dropping torn entry at the end of journal '%s'", path)
+				break
+			}
+			return 0, 0, fmt.Errorf("corrupted entry in journal '%s' at offset %d", path, validSize)
+		}
+		if err := applyOp(docs, op); err != nil {
+			return 0, 0, err
+		}
+		validSize += int64(len(line))
+		count++
+	}
+	return count, validSize, nil
+}
+
+// scanTxLog returns the batches fully written to the transaction log at path,
+// a batch cut short was never committed.
+func scanTxLog(r io.Reader, path string) ([][]Op, error) {
+	var batches [][]Op
+	reader := bufio.NewReader(r)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return nil, fmt.Errorf("unable to read transaction log '%s': %v", path, readErr)
+		}
+		if len(line) == 0 {
+			break
+		}
+
+		var ops []Op
+		if readErr == io.EOF || json.Unmarshal(line, &ops) != nil {
+			// never fully written, so never committed
+			log.Printf("Dropping uncommitted batch at the end of '%s'", path)
+			break
+		}
+		batches = append(batches, ops)
+	}
+	return batches, nil
+}
+
+// recoverTxLog completes the batches committed to the transaction log before a crash
+// by appending them again to the collection journals. Puts and deletes are idempotent,
+// so a batch that already reached some journals is safe to replay.
+func (d *jsonDriver) recoverTxLog() error {
+	path := filepath.Join(d.dir, txLogFilename)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to open transaction log '%s': %v", path, err)
+	}
+
+	batches, err := scanTxLog(file, path)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	lines := make(map[string]*bytes.Buffer)
+	for _, ops := range batches {
+		for _, op := range ops {
+			buf, ok := lines[op.Collection]
+			if !ok {
+				buf = &bytes.Buffer{}
+				lines[op.Collection] = buf
+			}
+			if err := json.NewEncoder(buf).Encode(op); err != nil {
+				file.Close()
+				return fmt.Errorf("error encoding journal entry: %s", err.Error())
+			}
+		}
+	}
+
+	for collection, buf := range lines {
+		log.Printf("Recovering committed batch entries for '%s'", collection)
+		if err := appendSync(d.journalPath(collection), buf.Bytes()); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := file.Truncate(0); err != nil {
+		file.Close()
+		return fmt.Errorf("unable to truncate transaction log '%s': %v", path, err)
+	}
+	d.txLog = file
+	return nil
+}
+
+func appendSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to open journal '%s': %v", path, err)
+	}
+	defer file.Close()
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("unable to append to journal '%s': %v", path, err)
+	}
+	return file.Sync()
+}
+
+func applyOp(docs map[string]json.RawMessage, op Op) error {
+	switch op.Kind {
+	case OpPut:
+		docs[op.ID] = op.Data
+	case OpDelete:
+		delete(docs, op.ID)
+	default:
+		return fmt.Errorf("%w: unknown operation %q", ErrInvalidEntry, op.Kind)
+	}
+	return nil
+}
+
+// Apply appends the operations to the collection journals and fsyncs them.
+// A batch with several operations is committed to the transaction log first.
+func (d *jsonDriver) Apply(ops []Op) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.failed != nil {
+		return d.failed
+	}
+
+	lines := make(map[string]*bytes.Buffer)
+	for _, op := range ops {
+		if _, ok := d.journals[op.Collection]; !ok {
+			return fmt.Errorf("%w: collection %q is not loaded", ErrInvalidEntry, op.Collection)
+		}
+		if op.Kind != OpPut && op.Kind != OpDelete {
+			return fmt.Errorf("%w: unknown operation %q", ErrInvalidEntry, op.Kind)
+		}
+		buf, ok := lines[op.Collection]
+		if !ok {
+			buf = &bytes.Buffer{}
+			lines[op.Collection] = buf
+		}
+		if err := json.NewEncoder(buf).Encode(op); err != nil {
+			return fmt.Errorf("error encoding journal entry: %s", err.Error())
+		}
+	}
+
+	if len(ops) > 1 {
+		batch, err := json.Marshal(ops)
+		if err != nil {
+			return fmt.Errorf("error encoding transaction: %s", err.Error())
+		}
+		if err := d.appendJournal(d.txLog, append(batch, '\n')); err != nil {
+			// the log is empty between batches, drop what reached it so a restart does not commit it
+			return d.rollbackJournal(d.txLog, 0, fmt.Errorf("transaction log: %w", err))
+		}
+	}
+
+	for collection, buf := range lines {
+		journal := d.journals[collection]
+		if len(ops) > 1 {
+			if err := d.appendJournal(journal, buf.Bytes()); err != nil {
+				d.failed = fmt.Errorf("storage needs a restart to recover a committed transaction: %w", err)
+				return d.failed
+			}
+			continue
+		}
+		info, err := journal.Stat()
+		if err != nil {
+			return fmt.Errorf("unable to stat journal: %s", err.Error())
+		}
+		if err := d.appendJournal(journal, buf.Bytes()); err != nil {
+			return d.rollbackJournal(journal, info.Size(), err)
+		}
+	}
+
+	if len(ops) > 1 {
+		// the batch is durable in the journals, recovery no longer needs it. A batch left
+		// in the log would be replayed over the writes that follow it on restart.
+		if err := d.txLog.Truncate(0); err != nil {
+			d.failed = fmt.Errorf("storage needs a restart to clear the transaction log: %s", err.Error())
+			return d.failed
+		}
+	}
+
+	for _, op := range ops {
+		_ = applyOp(d.collections[op.Collection], op)
+		d.pending[op.Collection]++
+	}
+	return nil
+}
+
+func (d *jsonDriver) appendJournal(journal *os.File, data []byte) error {
+	if _, err := journal.Write(data); err != nil {
+		return fmt.Errorf("unable to append to journal: %s", err.Error())
+	}
+	if err := journal.Sync(); err != nil {
+		return fmt.Errorf("unable to sync journal: %s", err.Error())
+	}
+	return nil
+}
+
+// rollbackJournal cuts file back to size after a failed append, so the entry is not
+// replayed on restart although the write was refused. When even that fails the
+// driver refuses writes until a restart.
+func (d *jsonDriver) rollbackJournal(file *os.File, size int64, cause error) error {
+	if err := file.Truncate(size); err != nil {
+		d.failed = fmt.Errorf("storage needs a restart to drop a refused write: %w (truncate: %s)", cause, err.Error())
+		return d.failed
+	}
+	return cause
+}
+
+// Compact writes a fresh snapshot of every collection with journaled
+// operations and truncates its journal.
+func (d *jsonDriver) Compact() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for collection, count := range d.pending {
+		if count == 0 {
+			continue
+		}
+		if err := writeSnapshot(d.filePath(collection), d.collections[collection]); err != nil {
+			return err
+		}
+		journal := d.journals[collection]
+		if err := journal.Truncate(0); err != nil {
+			return fmt.Errorf("unable to truncate journal: %s", err.Error())
+		}
+		if err := journal.Sync(); err != nil {
+			return fmt.Errorf("unable to sync journal: %s", err.Error())
+		}
+		d.pending[collection] = 0
+	}
+	return nil
+}
+
+func (d *jsonDriver) compactLoop(every time.Duration) {
+	defer close(d.done)
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := d.Compact(); err != nil {
+				log.Printf("Journal compaction failed: %v", err)
+			}
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// writeSnapshot writes the documents of a collection to path as a JSON array ordered by ID.
+func writeSnapshot(path string, docs map[string]json.RawMessage) error {
+	ids := make([]string, 0, len(docs))
+	for id := range docs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	items := make([]json.RawMessage, 0, len(ids))
+	for _, id := range ids {
+		items = append(items, docs[id])
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(items); err != nil {
+		return fmt.Errorf("error encoding JSON to file: %s", err.Error())
+	}
+
+	return atomicWriteFile(path, buf.Bytes())
+}
+
+// Close stops the compaction loop, compacts a last time and closes the journals.
+func (d *jsonDriver) Close() error {
+	select {
+	case <-d.stop:
+	default:
+		close(d.stop)
+	}
+	<-d.done
+
+	err := d.Compact()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for collection, journal := range d.journals {
+		if closeErr := journal.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		delete(d.journals, collection)
+	}
+	if d.txLog != nil {
+		if closeErr := d.txLog.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		d.txLog = nil
+	}
+	return err
+}
+
+// atomicWriteFile writes data to a temporary file in the same directory,
+// fsyncs it and renames it over path, so readers never see a partial file.
+func atomicWriteFile(path string, data []byte) (err error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("error creating directories: %s", err.Error())
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("unable to create temp file: %s", err.Error())
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return fmt.Errorf("unable to write temp file: %s", err.Error())
+	}
+	if err = tmp.Sync(); err != nil {
+		return fmt.Errorf("unable to sync temp file: %s", err.Error())
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("unable to close temp file: %s", err.Error())
+	}
+	if err = os.Chmod(tmp.Name(), 0600); err != nil {
+		return fmt.Errorf("unable to set file mode: %s", err.Error())
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("unable to replace data file: %s", err.Error())
+	}
+	return syncDir(dir)
+}
+
+// syncDir makes a rename durable by fsyncing its directory.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("unable to sync directory '%s': %s", dir, err.Error())
+	}
+	return nil
+}
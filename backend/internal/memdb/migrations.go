@@ -0,0 +1,117 @@
+package memdb
+
+// migration is a versioned change to the SQLite schema.
+// Migrations are applied in order and never edited once released,
+// a schema change always goes into a new entry at the end of the list.
+type migration struct {
+	version int
+	name    string
+	stmts   []string
+}
+
+// collectionTables maps the repository collections to their SQLite tables.
+var collectionTables = map[string]string{
+	usersCollection:         "users",
+	typesQuizCollection:     "type_quizzes",
+	questionsCollection:     "questions",
+	questionsFlowCollection: "question_flows",
+	historyCollection:       "history",
+	quarantineCollection:    "quarantine",
+	sessionsCollection:      "sessions",
+	revocationsCollection:   "revocations",
+	apiKeysCollection:       "api_keys",
+	auditCollection:         "audit_log",
+}
+
+var sqliteMigrations = []migration{
+	{
+		version: 1,
+		name:    "create collection tables",
+		stmts: []string{
+			`CREATE TABLE users (
+				id         TEXT PRIMARY KEY,
+				data       TEXT NOT NULL,
+				updated_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE TABLE type_quizzes (
+				id         TEXT PRIMARY KEY,
+				data       TEXT NOT NULL,
+				updated_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE TABLE questions (
+				id         TEXT PRIMARY KEY,
+				data       TEXT NOT NULL,
+				updated_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE TABLE question_flows (
+				id         TEXT PRIMARY KEY,
+				data       TEXT NOT NULL,
+				updated_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE TABLE history (
+				id         TEXT PRIMARY KEY,
+				data       TEXT NOT NULL,
+				updated_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE TABLE metadata (
+				key   TEXT PRIMARY KEY,
+				value TEXT NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 2,
+		name:    "create quarantine table",
+		stmts: []string{
+			`CREATE TABLE quarantine (
+				id         TEXT PRIMARY KEY,
+				data       TEXT NOT NULL,
+				updated_at TIMESTAMP NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 3,
+		name:    "create sessions table",
+		stmts: []string{
+			`CREATE TABLE sessions (
+				id         TEXT PRIMARY KEY,
+				data       TEXT NOT NULL,
+				updated_at TIMESTAMP NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 4,
+		name:    "create revocations table",
+		stmts: []string{
+			`CREATE TABLE revocations (
+				id         TEXT PRIMARY KEY,
+				data       TEXT NOT NULL,
+				updated_at TIMESTAMP NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 5,
+		name:    "create api keys table",
+		stmts: []string{
+			`CREATE TABLE api_keys (
+				id         TEXT PRIMARY KEY,
+				data       TEXT NOT NULL,
+				updated_at TIMESTAMP NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 6,
+		name:    "create audit log table",
+		stmts: []string{
+			`CREATE TABLE audit_log (
+				id         TEXT PRIMARY KEY,
+				data       TEXT NOT NULL,
+				updated_at TIMESTAMP NOT NULL
+			)`,
+		},
+	},
+}
@@ -0,0 +1,60 @@
+package memdb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+var ErrIdentityConflict = errors.New("username is already taken by another account")
+
+// FindOrCreateOIDCUser returns the user linked to an OpenID Connect subject.
+// On the first login of the subject a player named username is created, without a password.
+// When username already exists it is linked only with linkExisting, which the caller
+// sets when the username comes from a claim the provider verified, such as a verified email,
+// and only when the user has no password. Anyone can register a username shaped like an email
+// with a password of their own, linking such an account would hand it the identity.
+func (db *DBManager) FindOrCreateOIDCUser(subject, username string, linkExisting bool) (*models.User, error) {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	linked, err := db.userProgressRepo.Query().ByIndex(indexBySubject, subject).WithDeleted().First()
+	if err == nil {
+		if linked.DeletedAt != nil {
+			return nil, fmt.Errorf("FindOrCreateOIDCUser: %w", ErrUserDeleted)
+		}
+		return linked, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("FindOrCreateOIDCUser: %w", err)
+	}
+
+	user, err := db.userProgressRepo.findTx(tx, username)
+	switch {
+	case !models.ValidUsername(username):
+		return nil, fmt.Errorf("FindOrCreateOIDCUser: %w: %q", ErrInvalidUsername, username)
+	case errors.Is(err, ErrNotFound):
+		user = &models.User{
+			Username:         username,
+			Role:             models.RolePlayer,
+			CreatedAt:        tx.now,
+			QuestionsFlowsID: make([]string, 0),
+		}
+	case err != nil:
+		return nil, fmt.Errorf("FindOrCreateOIDCUser: %w", err)
+	case user.DeletedAt != nil:
+		return nil, fmt.Errorf("FindOrCreateOIDCUser: %w", ErrUserDeleted)
+	case user.OIDCSubject != "" || !linkExisting || user.PasswordHash != "":
+		return nil, fmt.Errorf("FindOrCreateOIDCUser: %w: %q", ErrIdentityConflict, username)
+	}
+
+	user.OIDCSubject = subject
+	if err := db.userProgressRepo.SaveTx(tx, user); err != nil {
+		return nil, fmt.Errorf("FindOrCreateOIDCUser: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("FindOrCreateOIDCUser: %w", err)
+	}
+	return user, nil
+}
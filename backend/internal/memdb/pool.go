@@ -0,0 +1,104 @@
+package memdb
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+// drawQuestionsTx draws the questions of a new flow from the pool of typeQuiz.
+// The strata are drawn first and the rest of the draw from the whole pool, a pool
+// smaller than the draw gives every question it has. The drawn questions keep the
+// order of the pool.
+func (db *DBManager) drawQuestionsTx(tx *Tx, typeQuiz *models.TypeQuiz) ([]string, error) {
+	candidates, err := db.poolQuestionsTx(tx, typeQuiz)
+	if err != nil {
+		return nil, err
+	}
+	position := make(map[string]int, len(candidates))
+	for i, question := range candidates {
+		position[question.ID] = i
+	}
+	rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+
+	pool := typeQuiz.Pool
+	drawn := make([]string, 0, pool.Draw)
+	taken := make(map[string]bool, pool.Draw)
+	take := func(count int, matches func(*models.Question) bool) {
+		for _, question := range candidates {
+			if count == 0 || len(drawn) == pool.Draw {
+				return
+			}
+			if !taken[question.ID] && matches(question) {
+				taken[question.ID] = true
+				drawn = append(drawn, question.ID)
+				count--
+			}
+		}
+	}
+	for _, stratum := range pool.Strata {
+		take(stratum.Count, stratum.Matches)
+	}
+	take(pool.Draw-len(drawn), func(*models.Question) bool { return true })
+	if len(drawn) == 0 {
+		return nil, ErrNoQuestions
+	}
+
+	sort.Slice(drawn, func(i, j int) bool { return position[drawn[i]] < position[drawn[j]] })
+	return drawn, nil
+}
+
+// poolQuestionsTx returns the live questions of the pool: those listed by the quiz type,
+// or else the questions of the bank with one of the pool tags.
+func (db *DBManager) poolQuestionsTx(tx *Tx, typeQuiz *models.TypeQuiz) ([]*models.Question, error) {
+	if len(typeQuiz.QuestionsID) > 0 {
+		questions := make([]*models.Question, 0, len(typeQuiz.QuestionsID))
+		for _, qID := range typeQuiz.QuestionsID {
+			question, err := db.questionRepo.FindByIDTx(tx, qID)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			questions = append(questions, question)
+		}
+		return questions, nil
+	}
+	tags := typeQuiz.Pool.Tags
+	return db.questionRepo.Query().
+		Where(func(q *models.Question) bool {
+			for _, tag := range tags {
+				if q.HasTag(tag) {
+					return true
+				}
+			}
+			return len(tags) == 0
+		}).
+		All()
+}
+
+// validatePool checks that the pool draws questions and its strata fit in the draw.
+func validatePool(typeQuiz *models.TypeQuiz) error {
+	pool := typeQuiz.Pool
+	if pool == nil {
+		return nil
+	}
+	if pool.Draw <= 0 {
+		return fmt.Errorf("quiz type %s pool must draw at least one question", typeQuiz.Name)
+	}
+	total := 0
+	for _, stratum := range pool.Strata {
+		if stratum.Count <= 0 {
+			return fmt.Errorf("quiz type %s pool has a stratum without count", typeQuiz.Name)
+		}
+		total += stratum.Count
+	}
+	if total > pool.Draw {
+		return fmt.Errorf("quiz type %s pool strata draw %d questions, more than the %d of the pool", typeQuiz.Name, total, pool.Draw)
+	}
+	return nil
+}
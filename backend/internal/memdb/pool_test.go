@@ -0,0 +1,163 @@
+package memdb
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+// newPoolTestDB returns a bank of 4 hard algebra, 4 easy algebra and 4 geometry questions, a0 to g3
+func newPoolTestDB(t *testing.T) *DBManager {
+	t.Helper()
+	db, err := NewDBManager(NewMemoryDriver())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, group := range []struct {
+		prefix, tag, difficulty string
+	}{
+		{"a", "algebra", "hard"},
+		{"e", "algebra", "easy"},
+		{"g", "geometry", "easy"},
+	} {
+		for i := 0; i < 4; i++ {
+			question := &models.Question{
+				ID:         fmt.Sprintf("%s%d", group.prefix, i),
+				Prompt:     "2 + 2?",
+				Options:    []string{"A: 3", "B: 4"},
+				Answer:     "B",
+				Tags:       []string{group.tag},
+				Difficulty: group.difficulty,
+			}
+			if _, err := db.CreateQuestion(question); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return db
+}
+
+// draw draws the questions of a flow of typeQuiz without storing it
+func draw(t *testing.T, db *DBManager, typeQuiz *models.TypeQuiz) []string {
+	t.Helper()
+	tx := db.Begin()
+	defer tx.Rollback()
+	drawn, err := db.drawQuestionsTx(tx, typeQuiz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return drawn
+}
+
+func TestDrawStrata(t *testing.T) {
+	db := newPoolTestDB(t)
+	typeQuiz := &models.TypeQuiz{Name: "Maths", Pool: &models.QuestionPool{
+		Draw: 5,
+		Strata: []models.PoolStratum{
+			{Tag: "algebra", Difficulty: "hard", Count: 2},
+			{Tag: "geometry", Count: 1},
+		},
+	}}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		drawn := draw(t, db, typeQuiz)
+		if len(drawn) != 5 {
+			t.Fatalf("drew %v, want 5 questions", drawn)
+		}
+		// the draw keeps the order of the bank and takes each question once
+		if !slices.IsSorted(drawn) || len(slices.Compact(slices.Clone(drawn))) != len(drawn) {
+			t.Fatalf("drew %v, want distinct questions in bank order", drawn)
+		}
+		hard, geometry := 0, 0
+		for _, id := range drawn {
+			seen[id] = true
+			switch id[0] {
+			case 'a':
+				hard++
+			case 'g':
+				geometry++
+			}
+		}
+		if hard < 2 || geometry < 1 {
+			t.Fatalf("drew %v, want at least 2 hard algebra and 1 geometry questions", drawn)
+		}
+	}
+	// the rest of the draw comes from the whole pool
+	if len(seen) != 12 {
+		t.Errorf("100 draws covered %d of the 12 questions", len(seen))
+	}
+}
+
+func TestDrawPool(t *testing.T) {
+	db := newPoolTestDB(t)
+	if err := db.DeleteQuestion("e0", DeleteOptions{Soft: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		typeQuiz *models.TypeQuiz
+		want     []string
+	}{
+		{
+			name:     "tags restrict the bank",
+			typeQuiz: &models.TypeQuiz{Name: "Geometry", Pool: &models.QuestionPool{Draw: 10, Tags: []string{"geometry"}}},
+			want:     []string{"g0", "g1", "g2", "g3"},
+		},
+		{
+			name:     "listed questions, retired ones are skipped",
+			typeQuiz: &models.TypeQuiz{Name: "Listed", QuestionsID: []string{"g1", "e0", "a2"}, Pool: &models.QuestionPool{Draw: 3}},
+			want:     []string{"g1", "a2"},
+		},
+		{
+			name: "a stratum larger than its questions",
+			typeQuiz: &models.TypeQuiz{Name: "Easy", Pool: &models.QuestionPool{
+				Draw:   3,
+				Tags:   []string{"algebra"},
+				Strata: []models.PoolStratum{{Difficulty: "easy", Count: 3}},
+			}},
+			want: []string{"e1", "e2", "e3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drawn := draw(t, db, tt.typeQuiz)
+			if !slices.Equal(drawn, tt.want) {
+				t.Errorf("drew %v, want %v", drawn, tt.want)
+			}
+		})
+	}
+
+	tx := db.Begin()
+	defer tx.Rollback()
+	empty := &models.TypeQuiz{Name: "Empty", Pool: &models.QuestionPool{Draw: 2, Tags: []string{"history"}}}
+	if _, err := db.drawQuestionsTx(tx, empty); !errors.Is(err, ErrNoQuestions) {
+		t.Errorf("empty pool: got %v, want %v", err, ErrNoQuestions)
+	}
+}
+
+func TestValidatePool(t *testing.T) {
+	tests := []struct {
+		name  string
+		pool  *models.QuestionPool
+		valid bool
+	}{
+		{"no pool", nil, true},
+		{"draw", &models.QuestionPool{Draw: 3}, true},
+		{"strata fill the draw", &models.QuestionPool{Draw: 3, Strata: []models.PoolStratum{{Tag: "algebra", Count: 2}, {Count: 1}}}, true},
+		{"no draw", &models.QuestionPool{}, false},
+		{"stratum without count", &models.QuestionPool{Draw: 3, Strata: []models.PoolStratum{{Tag: "algebra"}}}, false},
+		{"strata over the draw", &models.QuestionPool{Draw: 2, Strata: []models.PoolStratum{{Tag: "algebra", Count: 2}, {Count: 1}}}, false},
+	}
+	for _, tt := range tests {
+		err := validatePool(&models.TypeQuiz{Name: "Maths", Pool: tt.pool})
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: got %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
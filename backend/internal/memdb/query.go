@@ -0,0 +1,314 @@
+package memdb
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// IndexFunc returns the keys an entry is indexed under, nil to leave it out.
+type IndexFunc[T Identifiable] func(entry T) []string
+
+// index is a secondary index kept up to date on every write of the repository.
+type index[T Identifiable] struct {
+	keysOf IndexFunc[T]
+	ids    map[string]map[string]struct{}
+}
+
+func (idx *index[T]) add(id string, entry T) {
+	for _, key := range idx.keysOf(entry) {
+		if idx.ids[key] == nil {
+			idx.ids[key] = make(map[string]struct{})
+		}
+		idx.ids[key][id] = struct{}{}
+	}
+}
+
+func (idx *index[T]) remove(id string, entry T) {
+	for _, key := range idx.keysOf(entry) {
+		delete(idx.ids[key], id)
+		if len(idx.ids[key]) == 0 {
+			delete(idx.ids, key)
+		}
+	}
+}
+
+// AddIndex declares a secondary index and builds it from the current entries.
+func (r *Repository[T]) AddIndex(name string, keysOf IndexFunc[T]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	idx := &index[T]{keysOf: keysOf, ids: make(map[string]map[string]struct{})}
+	for id, entry := range r.entries {
+		idx.add(id, *entry)
+	}
+	r.indexes[name] = idx
+}
+
+// Query describes a filtered, sorted and paginated read of a Repository.
+// Results are ordered by ID unless OrderBy or OrderByKey is used, ties are always broken
+// by ID so pages are stable between calls.
+type Query[T Identifiable] struct {
+	repo    *Repository[T]
+	index   string
+	key     string
+	filters []func(T) bool
+	less    func(a, b T) bool
+	sortKey func(T) string
+	desc    bool
+	offset  int
+	limit   int
+	after   string
+
+	withDeleted bool
+}
+
+// Page is one page of a query result.
+type Page[T Identifiable] struct {
+	Items      []T    `json:"items"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Query starts a new query over the repository.
+func (r *Repository[T]) Query() *Query[T] {
+	return &Query[T]{repo: r}
+}
+
+// ByIndex restricts the query to the entries stored under key in the named index.
+func (q *Query[T]) ByIndex(name, key string) *Query[T] {
+	q.index, q.key = name, key
+	return q
+}
+
+// Where keeps only the entries matching pred, several calls are combined with AND.
+func (q *Query[T]) Where(pred func(T) bool) *Query[T] {
+	q.filters = append(q.filters, pred)
+	return q
+}
+
+// WithDeleted includes the soft deleted entries in the result.
+func (q *Query[T]) WithDeleted() *Query[T] {
+	q.withDeleted = true
+	return q
+}
+
+// OrderBy sorts the result with less. A cursor into the result only
+// works while the entry it points to is still part of it.
+func (q *Query[T]) OrderBy(less func(a, b T) bool) *Query[T] {
+	q.less = less
+	return q
+}
+
+// OrderByKey sorts the result by the key of each entry, from the greatest
+// when desc is set. The cursors carry the key, so the next page is still
+// found once the entry a cursor points to is deleted.
+func (q *Query[T]) OrderByKey(key func(T) string, desc bool) *Query[T] {
+	q.sortKey, q.desc = key, desc
+	q.less = func(a, b T) bool {
+		if desc {
+			return key(a) > key(b)
+		}
+		return key(a) < key(b)
+	}
+	return q
+}
+
+// Offset skips the first n results.
+func (q *Query[T]) Offset(n int) *Query[T] {
+	q.offset = n
+	return q
+}
+
+// Limit returns at most n results, zero means no limit.
+func (q *Query[T]) Limit(n int) *Query[T] {
+	q.limit = n
+	return q
+}
+
+// After starts the result right after the entry the cursor points to.
+func (q *Query[T]) After(cursor string) *Query[T] {
+	q.after = cursor
+	return q
+}
+
+// All returns the matching entries.
+func (q *Query[T]) All() ([]T, error) {
+	page, err := q.Page()
+	if err != nil {
+		return nil, err
+	}
+	return page.Items, nil
+}
+
+// First returns the first matching entry or ErrNotFound.
+func (q *Query[T]) First() (T, error) {
+	items, err := q.Limit(1).All()
+	if err != nil || len(items) == 0 {
+		var empty T
+		if err == nil {
+			err = ErrNotFound
+		}
+		return empty, err
+	}
+	return items[0], nil
+}
+
+// Count returns how many entries match, ignoring pagination.
+func (q *Query[T]) Count() (int, error) {
+	q.repo.mu.RLock()
+	defer q.repo.mu.RUnlock()
+
+	matches, err := q.matches()
+	if err != nil {
+		return 0, err
+	}
+	return len(matches), nil
+}
+
+// Page returns the requested page, the total number of matches and the cursor of the next page.
+func (q *Query[T]) Page() (Page[T], error) {
+	q.repo.mu.RLock()
+	defer q.repo.mu.RUnlock()
+
+	matches, err := q.matches()
+	if err != nil {
+		return Page[T]{}, err
+	}
+	q.sort(matches)
+
+	start := 0
+	if q.after != "" {
+		after, err := decodeCursor(q.after)
+		if err != nil {
+			return Page[T]{}, err
+		}
+		if start = q.seek(matches, after); start < 0 {
+			return Page[T]{}, fmt.Errorf("%w: entry %s is not part of the result", ErrInvalidCursor, after.ID)
+		}
+	}
+	start += q.offset
+	if start > len(matches) {
+		start = len(matches)
+	}
+	end := len(matches)
+	if q.limit > 0 && start+q.limit < end {
+		end = start + q.limit
+	}
+
+	page := Page[T]{Items: make([]T, 0, end-start), Total: len(matches)}
+	for _, id := range matches[start:end] {
+		page.Items = append(page.Items, clone(*q.repo.entries[id]))
+	}
+	if end < len(matches) && end > start {
+		last := cursor{ID: matches[end-1]}
+		if q.sortKey != nil {
+			last.Key = q.sortKey(*q.repo.entries[last.ID])
+		}
+		page.NextCursor = encodeCursor(last)
+	}
+	return page, nil
+}
+
+// seek returns the position of the first of the sorted ids that comes after the cursor,
+// or -1 when the query is sorted by OrderBy and the cursor entry is gone.
+func (q *Query[T]) seek(ids []string, after cursor) int {
+	if q.less != nil && q.sortKey == nil {
+		for i, id := range ids {
+			if id == after.ID {
+				return i + 1
+			}
+		}
+		return -1
+	}
+	return sort.Search(len(ids), func(i int) bool {
+		if q.sortKey != nil {
+			if key := q.sortKey(*q.repo.entries[ids[i]]); key != after.Key {
+				return (key < after.Key) == q.desc
+			}
+		}
+		return ids[i] > after.ID
+	})
+}
+
+// matches returns the IDs of the entries matching the query, the caller must hold the read lock.
+func (q *Query[T]) matches() ([]string, error) {
+	candidates := q.repo.entries
+	var ids []string
+	if q.index != "" {
+		idx, ok := q.repo.indexes[q.index]
+		if !ok {
+			return nil, fmt.Errorf("unknown index %q on %s", q.index, q.repo.collection)
+		}
+		for id := range idx.ids[q.key] {
+			if q.keep(*candidates[id]) {
+				ids = append(ids, id)
+			}
+		}
+		return ids, nil
+	}
+	for id, entry := range candidates {
+		if q.keep(*entry) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (q *Query[T]) keep(entry T) bool {
+	if !q.withDeleted && isDeleted(entry) {
+		return false
+	}
+	for _, pred := range q.filters {
+		if !pred(entry) {
+			return false
+		}
+	}
+	return true
+}
+
+func (q *Query[T]) sort(ids []string) {
+	entries := q.repo.entries
+	sort.SliceStable(ids, func(i, j int) bool {
+		if q.less != nil {
+			a, b := *entries[ids[i]], *entries[ids[j]]
+			if q.less(a, b) {
+				return true
+			}
+			if q.less(b, a) {
+				return false
+			}
+		}
+		return ids[i] < ids[j]
+	})
+}
+
+// cursor points to the last entry of a page by its ID and, for OrderByKey, its sort key
+type cursor struct {
+	ID  string `json:"id"`
+	Key string `json:"key,omitempty"`
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(encoded string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || json.Unmarshal(data, &c) != nil || c.ID == "" {
+		return cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// timeKey formats t as a sort key of OrderByKey, the keys of two times compare like them
+func timeKey(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000000")
+}
@@ -0,0 +1,68 @@
+package memdb
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+// TestQueryCursorSurvivesDelete pages through a listing while the last entry of
+// each page is deleted, the next page must carry on right after it.
+func TestQueryCursorSurvivesDelete(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	orders := map[string]func(q *Query[*models.AuditEvent]) *Query[*models.AuditEvent]{
+		"by id": func(q *Query[*models.AuditEvent]) *Query[*models.AuditEvent] { return q },
+		"by key": func(q *Query[*models.AuditEvent]) *Query[*models.AuditEvent] {
+			return q.OrderByKey(func(e *models.AuditEvent) string { return timeKey(e.CreatedAt) }, true)
+		},
+	}
+	for name, order := range orders {
+		t.Run(name, func(t *testing.T) {
+			repo, err := NewRepository[*models.AuditEvent](NewMemoryDriver(), auditCollection)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// two events per second so the key has ties broken by ID
+			for i := 0; i < 10; i++ {
+				event := &models.AuditEvent{ID: fmt.Sprintf("e%d", i), CreatedAt: start.Add(time.Duration(i/2) * time.Second)}
+				if err := repo.Save(event); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := order(repo.Query()).All()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			cursor := ""
+			for {
+				page, err := order(repo.Query()).Limit(3).After(cursor).Page()
+				if err != nil {
+					t.Fatalf("page after %q: %v", cursor, err)
+				}
+				for _, event := range page.Items {
+					got = append(got, event.ID)
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				if err := repo.Delete(page.Items[len(page.Items)-1].ID); err != nil {
+					t.Fatal(err)
+				}
+				cursor = page.NextCursor
+			}
+
+			if len(got) != len(want) {
+				t.Fatalf("got %v, want %d entries", got, len(want))
+			}
+			for i, event := range want {
+				if got[i] != event.ID {
+					t.Fatalf("got %v, want the order of %v", got, want)
+				}
+			}
+		})
+	}
+}
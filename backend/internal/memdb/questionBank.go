@@ -0,0 +1,201 @@
+package memdb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+var (
+	ErrQuestionExists = errors.New("question already exists")
+	ErrTypeQuizExists = errors.New("quiz type already exists")
+	ErrTypeQuizInUse  = errors.New("quiz type has question flows, soft delete it instead")
+)
+
+// ListOptions selects a page of a listing.
+type ListOptions struct {
+	IncludeDeleted bool
+	Limit          int
+	Cursor         string
+}
+
+func applyListOptions[T Identifiable](q *Query[T], opts ListOptions) *Query[T] {
+	if opts.IncludeDeleted {
+		q = q.WithDeleted()
+	}
+	return q.Limit(opts.Limit).After(opts.Cursor)
+}
+
+// ListQuestions returns a page of questions ordered by ID.
+func (db *DBManager) ListQuestions(opts ListOptions) (Page[*models.Question], error) {
+	return applyListOptions(db.questionRepo.Query(), opts).Page()
+}
+
+// CreateQuestion validates and stores a new question, an empty ID is generated.
+func (db *DBManager) CreateQuestion(question *models.Question) (*models.Question, error) {
+	if question.ID == "" {
+		question.ID = uuid.NewString()
+	}
+	question.DeletedAt = nil
+	if err := validateQuestion(question); err != nil {
+		return nil, fmt.Errorf("CreateQuestion: %w: %s", ErrInvalidEntry, err.Error())
+	}
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	// tombstones keep their ID, restore them instead of reusing it
+	if _, err := db.questionRepo.findTx(tx, question.ID); err == nil {
+		return nil, fmt.Errorf("CreateQuestion: %w: %s", ErrQuestionExists, question.ID)
+	}
+	if err := db.questionRepo.SaveTx(tx, question); err != nil {
+		return nil, fmt.Errorf("CreateQuestion: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("CreateQuestion: %w", err)
+	}
+	return question, nil
+}
+
+// UpdateQuestion replaces the content of an existing question.
+// Answers already given keep the expected answer they were graded with.
+func (db *DBManager) UpdateQuestion(question *models.Question) (*models.Question, error) {
+	if err := validateQuestion(question); err != nil {
+		return nil, fmt.Errorf("UpdateQuestion: %w: %s", ErrInvalidEntry, err.Error())
+	}
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	current, err := db.questionRepo.FindByIDTx(tx, question.ID)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateQuestion: %w", err)
+	}
+	question.DeletedAt = current.DeletedAt
+	if err := db.questionRepo.SaveTx(tx, question); err != nil {
+		return nil, fmt.Errorf("UpdateQuestion: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("UpdateQuestion: %w", err)
+	}
+	return question, nil
+}
+
+// ListTypeQuizzes returns a page of quiz types ordered by name.
+func (db *DBManager) ListTypeQuizzes(opts ListOptions) (Page[*models.TypeQuiz], error) {
+	return applyListOptions(db.TypeQuizRepo.Query(), opts).Page()
+}
+
+// GetTypeQuiz returns a live quiz type.
+func (db *DBManager) GetTypeQuiz(name string) (*models.TypeQuiz, error) {
+	return db.TypeQuizRepo.FindByID(name)
+}
+
+// CreateTypeQuiz validates and stores a new quiz type.
+func (db *DBManager) CreateTypeQuiz(typeQuiz *models.TypeQuiz) (*models.TypeQuiz, error) {
+	typeQuiz.DeletedAt = nil
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	if _, err := db.TypeQuizRepo.findTx(tx, typeQuiz.Name); err == nil {
+		return nil, fmt.Errorf("CreateTypeQuiz: %w: %s", ErrTypeQuizExists, typeQuiz.Name)
+	}
+	if err := db.validateTypeQuizTx(tx, typeQuiz); err != nil {
+		return nil, fmt.Errorf("CreateTypeQuiz: %w: %s", ErrInvalidEntry, err.Error())
+	}
+	if err := db.TypeQuizRepo.SaveTx(tx, typeQuiz); err != nil {
+		return nil, fmt.Errorf("CreateTypeQuiz: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("CreateTypeQuiz: %w", err)
+	}
+	return typeQuiz, nil
+}
+
+// UpdateTypeQuiz replaces the question list of an existing quiz type.
+// Flows in progress pick up the new list on their next question.
+func (db *DBManager) UpdateTypeQuiz(typeQuiz *models.TypeQuiz) (*models.TypeQuiz, error) {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	current, err := db.TypeQuizRepo.FindByIDTx(tx, typeQuiz.Name)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateTypeQuiz: %w", err)
+	}
+	if err := db.validateTypeQuizTx(tx, typeQuiz); err != nil {
+		return nil, fmt.Errorf("UpdateTypeQuiz: %w: %s", ErrInvalidEntry, err.Error())
+	}
+	typeQuiz.DeletedAt = current.DeletedAt
+	if err := db.TypeQuizRepo.SaveTx(tx, typeQuiz); err != nil {
+		return nil, fmt.Errorf("UpdateTypeQuiz: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("UpdateTypeQuiz: %w", err)
+	}
+	return typeQuiz, nil
+}
+
+// DeleteTypeQuiz removes a quiz type. Quiz types with flows can only be soft deleted,
+// which stops new flows while the ones in progress can still be finished.
+func (db *DBManager) DeleteTypeQuiz(name string, opts DeleteOptions) error {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	if opts.Soft {
+		if err := db.TypeQuizRepo.SoftDeleteTx(tx, name); err != nil {
+			return fmt.Errorf("DeleteTypeQuiz: %w", err)
+		}
+		return tx.Commit()
+	}
+
+	if _, err := db.TypeQuizRepo.findTx(tx, name); err != nil {
+		return fmt.Errorf("DeleteTypeQuiz: %w", err)
+	}
+	flows, err := db.questionsFlowRepo.Query().ByIndex(indexByTypeQuiz, name).WithDeleted().Count()
+	if err != nil {
+		return fmt.Errorf("DeleteTypeQuiz: %w", err)
+	}
+	if flows > 0 {
+		return fmt.Errorf("DeleteTypeQuiz: %w", ErrTypeQuizInUse)
+	}
+	if err := db.TypeQuizRepo.DeleteTx(tx, name); err != nil {
+		return fmt.Errorf("DeleteTypeQuiz: %w", err)
+	}
+	return tx.Commit()
+}
+
+// RestoreTypeQuiz removes the tombstone of a soft deleted quiz type.
+func (db *DBManager) RestoreTypeQuiz(name string) error {
+	return db.Update(func(tx *Tx) error {
+		if err := db.TypeQuizRepo.RestoreTx(tx, name); err != nil {
+			return fmt.Errorf("RestoreTypeQuiz: %w", err)
+		}
+		return nil
+	})
+}
+
+// validateTypeQuizTx checks that the quiz type is named and lists live questions, once each.
+func (db *DBManager) validateTypeQuizTx(tx *Tx, typeQuiz *models.TypeQuiz) error {
+	if typeQuiz.Name == "" {
+		return errors.New("quiz type has no name")
+	}
+	if typeQuiz.MaxAttempts < 0 {
+		return fmt.Errorf("quiz type %s has a negative max_attempts", typeQuiz.Name)
+	}
+	if err := validatePool(typeQuiz); err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(typeQuiz.QuestionsID))
+	for _, qID := range typeQuiz.QuestionsID {
+		if seen[qID] {
+			return fmt.Errorf("question %s is listed twice", qID)
+		}
+		seen[qID] = true
+		if _, err := db.questionRepo.FindByIDTx(tx, qID); err != nil {
+			return fmt.Errorf("unknown question %s", qID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,349 @@
+package memdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/matheuspolitano/quiz-go/backend/internal/grading"
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+var (
+	ErrInvalidQuestionBank = errors.New("invalid question bank")
+	ErrQuestionBankStale   = errors.New("question bank files miss changes made through the API")
+)
+
+// reloadDebounce groups the burst of events an editor produces when saving a file.
+const reloadDebounce = 500 * time.Millisecond
+
+// ReloadReport lists the entries changed by ReloadQuestionBank.
+type ReloadReport struct {
+	QuestionsAdded   []string `json:"questions_added"`
+	QuestionsUpdated []string `json:"questions_updated"`
+	QuestionsRemoved []string `json:"questions_removed"`
+	TypesAdded       []string `json:"types_added"`
+	TypesUpdated     []string `json:"types_updated"`
+	TypesRemoved     []string `json:"types_removed"`
+}
+
+// Changed reports whether the reload changed anything.
+func (r *ReloadReport) Changed() bool {
+	return len(r.QuestionsAdded)+len(r.QuestionsUpdated)+len(r.QuestionsRemoved)+
+		len(r.TypesAdded)+len(r.TypesUpdated)+len(r.TypesRemoved) > 0
+}
+
+func (r *ReloadReport) String() string {
+	return fmt.Sprintf("questions +%d ~%d -%d, quiz types +%d ~%d -%d",
+		len(r.QuestionsAdded), len(r.QuestionsUpdated), len(r.QuestionsRemoved),
+		len(r.TypesAdded), len(r.TypesUpdated), len(r.TypesRemoved))
+}
+
+// ReloadQuestionBank reads the questions and quiz types files of dir, validates them
+// and applies the differences in a single transaction, so any driver persists them.
+// The files are the source of truth for the content, the tombstones stay owned by the server.
+// Entries removed from the files are soft deleted when flows still depend on them,
+// which keeps the flows in progress working, and deleted otherwise.
+// The files are read inside the transaction, after any edit made through the API was written
+// back to them, and the reload is refused while the files cannot be brought up to date.
+func (db *DBManager) ReloadQuestionBank(dir string) (*ReloadReport, error) {
+	tx := db.Begin()
+	defer tx.Rollback()
+	tx.fromBankFiles = true
+
+	if db.bankStale != nil {
+		db.saveQuestionBank()
+	}
+	if db.bankStale != nil {
+		return nil, fmt.Errorf("ReloadQuestionBank: %w: %s", ErrQuestionBankStale, db.bankStale.Error())
+	}
+	questions, err := readJSONFile[*models.Question](dir, questionsCollection)
+	if err != nil {
+		return nil, fmt.Errorf("ReloadQuestionBank: %w: %s", ErrInvalidQuestionBank, err.Error())
+	}
+	types, err := readJSONFile[*models.TypeQuiz](dir, typesQuizCollection)
+	if err != nil {
+		return nil, fmt.Errorf("ReloadQuestionBank: %w: %s", ErrInvalidQuestionBank, err.Error())
+	}
+	if err := validateQuestionBank(questions, types); err != nil {
+		return nil, fmt.Errorf("ReloadQuestionBank: %w: %s", ErrInvalidQuestionBank, err.Error())
+	}
+
+	report := &ReloadReport{}
+	report.QuestionsAdded, report.QuestionsUpdated, report.QuestionsRemoved, err = reloadCollection(tx, db.questionRepo, questions,
+		func(id string) (bool, error) {
+			answers, err := db.historyRepo.Query().ByIndex(indexByQuestion, id).Count()
+			if err != nil || answers > 0 {
+				return answers > 0, err
+			}
+			// like DeleteQuestion, the questions drawn for an attempt stay until it is done
+			flows, err := db.questionsFlowRepo.Query().WithDeleted().
+				Where(func(f *models.QuestionFlow) bool { return containsString(f.QuestionsID, id) }).
+				Count()
+			return flows > 0, err
+		})
+	if err != nil {
+		return nil, fmt.Errorf("ReloadQuestionBank: %w", err)
+	}
+	report.TypesAdded, report.TypesUpdated, report.TypesRemoved, err = reloadCollection(tx, db.TypeQuizRepo, types,
+		func(name string) (bool, error) {
+			flows, err := db.questionsFlowRepo.Query().ByIndex(indexByTypeQuiz, name).WithDeleted().Count()
+			return flows > 0, err
+		})
+	if err != nil {
+		return nil, fmt.Errorf("ReloadQuestionBank: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ReloadQuestionBank: %w", err)
+	}
+	return report, nil
+}
+
+// SetQuestionBankDir makes dir the home of the question bank files. Every change committed
+// to the questions and quiz types is then written back to them, so the files stay the one
+// source of the bank and a reload never undoes an edit made through the API.
+func (db *DBManager) SetQuestionBankDir(dir string) {
+	db.txMu.Lock()
+	defer db.txMu.Unlock()
+	db.bankDir = dir
+}
+
+func touchesQuestionBank(ops []Op) bool {
+	for _, op := range ops {
+		if op.Collection == questionsCollection || op.Collection == typesQuizCollection {
+			return true
+		}
+	}
+	return false
+}
+
+// saveQuestionBank writes the questions and quiz types back to the bank files, it runs
+// under txMu once a transaction is committed. The json driver keeps its snapshots in
+// the bank files, compacting it writes them. A failure is kept in bankStale and refuses
+// reloads until a later write back succeeds, the change itself is committed.
+func (db *DBManager) saveQuestionBank() {
+	if db.bankDir == "" {
+		return
+	}
+	var err error
+	if driver, ok := db.driver.(*jsonDriver); ok && filepath.Clean(driver.dir) == filepath.Clean(db.bankDir) {
+		err = driver.Compact()
+	} else if err = writeBankFile(db.bankDir, db.questionRepo); err == nil {
+		err = writeBankFile(db.bankDir, db.TypeQuizRepo)
+	}
+	if err != nil {
+		log.Printf("Unable to write the question bank files, reloads are refused until they are written: %v", err)
+	}
+	db.bankStale = err
+}
+
+// writeBankFile writes every entry of repo, tombstones included, in the snapshot format of the json driver.
+func writeBankFile[T Identifiable](dir string, repo *Repository[T]) error {
+	entries, err := repo.Query().WithDeleted().All()
+	if err != nil {
+		return err
+	}
+	docs := make(map[string]json.RawMessage, len(entries))
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("error encoding entry %s: %v", entry.GetID(), err)
+		}
+		docs[entry.GetID()] = data
+	}
+	return writeSnapshot(filepath.Join(dir, fmt.Sprintf(defaultDBFilename, repo.collection)), docs)
+}
+
+type reloadable interface {
+	Identifiable
+	SoftDeletable
+}
+
+// reloadCollection stages the differences between the entries of repo and items in tx.
+func reloadCollection[T reloadable](tx *Tx, repo *Repository[T], items []T, inUse func(id string) (bool, error)) (added, updated, removed []string, err error) {
+	current, err := repo.Query().WithDeleted().All()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	existing := make(map[string]T, len(current))
+	for _, entry := range current {
+		existing[entry.GetID()] = entry
+	}
+
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		id := item.GetID()
+		seen[id] = true
+		old, ok := existing[id]
+		if ok {
+			item.SetDeletedAt(old.GetDeletedAt())
+			if sameJSON(old, item) {
+				continue
+			}
+			updated = append(updated, id)
+		} else {
+			added = append(added, id)
+		}
+		if err := repo.SaveTx(tx, item); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	for _, entry := range current {
+		id := entry.GetID()
+		if seen[id] || entry.GetDeletedAt() != nil {
+			continue
+		}
+		used, err := inUse(id)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if used {
+			err = repo.SoftDeleteTx(tx, id)
+		} else {
+			err = repo.DeleteTx(tx, id)
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		removed = append(removed, id)
+	}
+	return added, updated, removed, nil
+}
+
+func sameJSON(a, b any) bool {
+	left, errA := json.Marshal(a)
+	right, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(left) == string(right)
+}
+
+// validateQuestionBank checks that IDs are unique, questions are answerable
+// and quiz types only list questions of the bank.
+func validateQuestionBank(questions []*models.Question, types []*models.TypeQuiz) error {
+	questionIDs := make(map[string]bool, len(questions))
+	for _, q := range questions {
+		if questionIDs[q.ID] {
+			return fmt.Errorf("duplicated question id %s", q.ID)
+		}
+		questionIDs[q.ID] = true
+		if err := validateQuestion(q); err != nil {
+			return err
+		}
+	}
+
+	typeNames := make(map[string]bool, len(types))
+	for _, t := range types {
+		if typeNames[t.Name] {
+			return fmt.Errorf("duplicated quiz type %s", t.Name)
+		}
+		typeNames[t.Name] = true
+		if t.MaxAttempts < 0 {
+			return fmt.Errorf("quiz type %s has a negative max_attempts", t.Name)
+		}
+		if err := validatePool(t); err != nil {
+			return err
+		}
+		for _, qID := range t.QuestionsID {
+			if !questionIDs[qID] {
+				return fmt.Errorf("quiz type %s lists unknown question %s", t.Name, qID)
+			}
+		}
+	}
+	return nil
+}
+
+// validateQuestion checks that a question has a prompt, the grader of its kind checks the rest:
+// choice questions need options written "A: Rome" and an answer that is either a letter or a whole option.
+func validateQuestion(q *models.Question) error {
+	if strings.TrimSpace(q.Prompt) == "" {
+		return fmt.Errorf("question %s has no prompt", q.ID)
+	}
+	return grading.Validate(q)
+}
+
+// QuestionBankWatcher reloads the question bank when its files change on disk.
+type QuestionBankWatcher struct {
+	db      *DBManager
+	dir     string
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+	once    sync.Once
+}
+
+// WatchQuestionBank starts reloading the question bank of dir whenever
+// its files are written. The directory is watched rather than the files,
+// so editors replacing the file on save are followed too.
+func (db *DBManager) WatchQuestionBank(dir string) (*QuestionBankWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("WatchQuestionBank: %w", err)
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("WatchQuestionBank: unable to watch '%s': %w", dir, err)
+	}
+
+	w := &QuestionBankWatcher{db: db, dir: dir, watcher: watcher, done: make(chan struct{})}
+	go w.loop()
+	log.Printf("Watching question bank in '%s'", dir)
+	return w, nil
+}
+
+func (w *QuestionBankWatcher) loop() {
+	defer close(w.done)
+	watched := map[string]bool{
+		fmt.Sprintf(defaultDBFilename, questionsCollection): true,
+		fmt.Sprintf(defaultDBFilename, typesQuizCollection): true,
+	}
+
+	timer := time.NewTimer(reloadDebounce)
+	timer.Stop()
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if watched[filepath.Base(event.Name)] && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+				timer.Reset(reloadDebounce)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Question bank watcher error: %v", err)
+		case <-timer.C:
+			w.db.ReloadAndLog(w.dir)
+		}
+	}
+}
+
+// Close stops watching the question bank.
+func (w *QuestionBankWatcher) Close() error {
+	var err error
+	w.once.Do(func() {
+		err = w.watcher.Close()
+		<-w.done
+	})
+	return err
+}
+
+// ReloadAndLog reloads the question bank of dir and logs the outcome,
+// a malformed bank is rejected and the current one keeps being served.
+func (db *DBManager) ReloadAndLog(dir string) {
+	report, err := db.ReloadQuestionBank(dir)
+	if err != nil {
+		log.Printf("Question bank reload rejected, keeping the current one: %v", err)
+		return
+	}
+	if report.Changed() {
+		log.Printf("Question bank reloaded: %s", report)
+	}
+}
@@ -0,0 +1,148 @@
+package memdb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+const (
+	bankQuestions = `[{"id": "q1", "prompt": "2 + 2?", "options": ["A: 3", "B: 4"], "answer": "B"}]`
+	bankTypes     = `[{"name": "Maths", "questions_id": ["q1"]}]`
+)
+
+func writeBank(t *testing.T, dir string) {
+	t.Helper()
+	for collection, content := range map[string]string{questionsCollection: bankQuestions, typesQuizCollection: bankTypes} {
+		path := filepath.Join(dir, collection+".data.json")
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestReloadKeepsAPIEdits checks that questions created and edited through the store
+// survive a reload of the bank files, whatever the driver keeps them in.
+func TestReloadKeepsAPIEdits(t *testing.T) {
+	drivers := map[string]func(t *testing.T, dir string) Driver{
+		"json": func(t *testing.T, dir string) Driver {
+			// no compaction, the edits only reach the journals until the write back
+			return NewJSONDriver(dir, 0)
+		},
+		"sqlite": func(t *testing.T, dir string) Driver {
+			driver, err := newSQLiteDriver(filepath.Join(t.TempDir(), "quiz.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return driver
+		},
+		"memory": func(t *testing.T, dir string) Driver {
+			return NewMemoryDriver()
+		},
+	}
+	for name, open := range drivers {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeBank(t, dir)
+			db, err := NewDBManager(open(t, dir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			db.SetQuestionBankDir(dir)
+			if _, err := db.ReloadQuestionBank(dir); err != nil {
+				t.Fatalf("initial reload: %v", err)
+			}
+
+			created, err := db.CreateQuestion(&models.Question{ID: "q2", Prompt: "3 + 3?", Options: []string{"A: 6", "B: 7"}, Answer: "A"})
+			if err != nil {
+				t.Fatalf("CreateQuestion: %v", err)
+			}
+			if _, err := db.UpdateQuestion(&models.Question{ID: "q1", Prompt: "2 + 2 =", Options: []string{"A: 3", "B: 4"}, Answer: "B"}); err != nil {
+				t.Fatalf("UpdateQuestion: %v", err)
+			}
+			if _, err := db.UpdateTypeQuiz(&models.TypeQuiz{Name: "Maths", QuestionsID: []string{"q1", created.ID}}); err != nil {
+				t.Fatalf("UpdateTypeQuiz: %v", err)
+			}
+
+			report, err := db.ReloadQuestionBank(dir)
+			if err != nil {
+				t.Fatalf("reload: %v", err)
+			}
+			if report.Changed() {
+				t.Errorf("reload changed the bank: %s", report)
+			}
+			if _, err := db.GetQuestion(created.ID); err != nil {
+				t.Errorf("question created through the store was lost: %v", err)
+			}
+			if q, err := db.GetQuestion("q1"); err != nil || q.Prompt != "2 + 2 =" {
+				t.Errorf("question edit was reverted: %+v, %v", q, err)
+			}
+			if typeQuiz, err := db.GetTypeQuiz("Maths"); err != nil || len(typeQuiz.QuestionsID) != 2 {
+				t.Errorf("quiz type edit was reverted: %+v, %v", typeQuiz, err)
+			}
+		})
+	}
+}
+
+// TestReloadKeepsDrawnQuestions checks that a question removed from the files while an open
+// attempt drew it is only retired, the attempt still asks it and takes its answer.
+func TestReloadKeepsDrawnQuestions(t *testing.T) {
+	dir := t.TempDir()
+	write := func(questions, types string) {
+		t.Helper()
+		for collection, content := range map[string]string{questionsCollection: questions, typesQuizCollection: types} {
+			if err := os.WriteFile(filepath.Join(dir, collection+".data.json"), []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// without listed questions the pool draws from the whole bank
+	const types = `[{"name": "Drawn", "pool": {"draw": 2}}]`
+	write(`[{"id": "q1", "prompt": "2 + 2?", "options": ["A: 3", "B: 4"], "answer": "B"},
+		{"id": "q2", "prompt": "3 + 3?", "options": ["A: 6", "B: 7"], "answer": "A"}]`, types)
+
+	db, err := NewDBManager(NewMemoryDriver())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ReloadQuestionBank(dir); err != nil {
+		t.Fatalf("initial reload: %v", err)
+	}
+	if _, err := db.CreateUser("alice", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	flow, err := db.AddQuestionFlow("alice", "Drawn")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write(bankQuestions, types)
+	report, err := db.ReloadQuestionBank(dir)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if len(report.QuestionsRemoved) != 1 || report.QuestionsRemoved[0] != "q2" {
+		t.Fatalf("removed %v, want [q2]", report.QuestionsRemoved)
+	}
+	if q, err := db.questionRepo.FindByIDWithDeleted("q2"); err != nil || q.DeletedAt == nil {
+		t.Fatalf("q2 should be soft deleted: %+v, %v", q, err)
+	}
+
+	for range flow.QuestionsID {
+		question, err := db.NextQuestion(flow.GetID())
+		if err != nil {
+			t.Fatalf("NextQuestion: %v", err)
+		}
+		if _, err := db.AddAnswer(flow.GetID(), question.ID, "A"); err != nil {
+			t.Fatalf("AddAnswer(%s): %v", question.ID, err)
+		}
+	}
+	if _, err := db.NextQuestion(flow.GetID()); !errors.Is(err, ErrAllQuestionsAnswered) {
+		t.Fatalf("NextQuestion: got %v, want %v", err, ErrAllQuestionsAnswered)
+	}
+}
@@ -0,0 +1,79 @@
+package memdb
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+// FlowSummary is the progress of one attempt of a user in a quiz type.
+type FlowSummary struct {
+	UserID       string     `json:"user_id"`
+	Attempt      int        `json:"attempt"`
+	Answered     int        `json:"answered"`
+	AccuracyRate float32    `json:"accuracy_rate"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ClosedAt     *time.Time `json:"closed_at,omitempty"`
+}
+
+// TypeQuizReport summarizes the flows of a quiz type.
+type TypeQuizReport struct {
+	TypeQuizName    string        `json:"type_quiz"`
+	Questions       int           `json:"questions"`
+	Flows           []FlowSummary `json:"flows"`
+	Closed          int           `json:"closed"`
+	AverageAccuracy float32       `json:"average_accuracy"`
+}
+
+// GetTypeQuizReport lists the progress of every attempt in a quiz type, ordered by user and attempt.
+// The average accuracy only counts the closed flows, as GetScoreUser does.
+func (db *DBManager) GetTypeQuizReport(typeQuizName string) (*TypeQuizReport, error) {
+	typeQuiz, err := db.TypeQuizRepo.FindByIDWithDeleted(typeQuizName)
+	if err != nil {
+		return nil, fmt.Errorf("GetTypeQuizReport: %w", err)
+	}
+	flows, err := db.questionsFlowRepo.Query().
+		ByIndex(indexByTypeQuiz, typeQuizName).
+		OrderBy(func(a, b *models.QuestionFlow) bool {
+			if a.UserID != b.UserID {
+				return a.UserID < b.UserID
+			}
+			return a.Attempt < b.Attempt
+		}).
+		All()
+	if err != nil {
+		return nil, fmt.Errorf("GetTypeQuizReport: %w", err)
+	}
+
+	report := &TypeQuizReport{
+		TypeQuizName: typeQuiz.Name,
+		Questions:    len(typeQuiz.QuestionsID),
+		Flows:        make([]FlowSummary, 0, len(flows)),
+	}
+	if typeQuiz.Pool != nil {
+		// every flow of a pool answers the questions drawn for it
+		report.Questions = typeQuiz.Pool.Draw
+	}
+	var total float32
+	for _, flow := range flows {
+		summary := FlowSummary{
+			UserID:       flow.UserID,
+			Attempt:      flow.Attempt,
+			Answered:     len(flow.History),
+			AccuracyRate: flow.AccuracyRate,
+			CreatedAt:    flow.CreatedAt,
+		}
+		if !flow.ClosedAt.IsZero() {
+			closedAt := flow.ClosedAt
+			summary.ClosedAt = &closedAt
+			report.Closed++
+			total += flow.AccuracyRate
+		}
+		report.Flows = append(report.Flows, summary)
+	}
+	if report.Closed > 0 {
+		report.AverageAccuracy = total / float32(report.Closed)
+	}
+	return report, nil
+}
@@ -4,21 +4,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"log"
-	"os"
-	"path/filepath"
 	"sync"
-)
-
-const (
-	defaultDBFilename = "%s.data.json"
-	baseDir           = "./data"
+	"time"
 )
 
 var (
-	ErrNotFound     = errors.New("entity not found")
-	ErrInvalidEntry = errors.New("invalid Entry")
+	ErrNotFound         = errors.New("entity not found")
+	ErrInvalidEntry     = errors.New("invalid Entry")
+	ErrNotSoftDeletable = errors.New("entity does not support soft delete")
+	ErrNotDeleted       = errors.New("entity is not deleted")
 )
 
 // Identifiable enforces a GetID() method so the generic repository
@@ -27,22 +21,40 @@ type Identifiable interface {
 	GetID() string
 }
 
+// SoftDeletable is implemented by the models that can be tombstoned instead of removed.
+// Tombstoned entries are kept by the driver but hidden from every read,
+// unless asked explicitly with FindByIDWithDeleted or Query().WithDeleted().
+type SoftDeletable interface {
+	GetDeletedAt() *time.Time
+	SetDeletedAt(deletedAt *time.Time)
+}
+
+func isDeleted[T Identifiable](entry T) bool {
+	soft, ok := any(entry).(SoftDeletable)
+	return ok && soft.GetDeletedAt() != nil
+}
+
 // Repository Generic repository
 type Repository[T Identifiable] struct {
-	entries  map[string]*T
-	filePath string
-	mu       sync.RWMutex
+	collection string
+	entries    map[string]*T
+	indexes    map[string]*index[T]
+	driver     Driver
+	mu         sync.RWMutex
 }
 
 // NewRepository creates and returns a new Repository for type
-func NewRepository[T Identifiable](filePath string) (*Repository[T], error) {
+// collection is the name used by the driver to store the entries
+func NewRepository[T Identifiable](driver Driver, collection string) (*Repository[T], error) {
 	repo := &Repository[T]{
-		entries:  make(map[string]*T),
-		filePath: filePath,
+		collection: collection,
+		entries:    make(map[string]*T),
+		indexes:    make(map[string]*index[T]),
+		driver:     driver,
 	}
 
-	if err := repo.loadFromFile(); err != nil {
-		return nil, fmt.Errorf("failed to load data from file: %s", err.Error())
+	if err := repo.load(); err != nil {
+		return nil, fmt.Errorf("failed to load data from driver: %s", err.Error())
 	}
 	return repo, nil
 }
@@ -50,82 +62,60 @@ func NewRepository[T Identifiable](filePath string) (*Repository[T], error) {
 // NewRepositoryDefault creates and returns a new Repository for type
 // collectionName will be the json filename
 func NewRepositoryDefault[T Identifiable](collectionName string) (*Repository[T], error) {
-	repo := &Repository[T]{
-		entries:  make(map[string]*T),
-		filePath: filepath.Join(baseDir, fmt.Sprintf(defaultDBFilename, collectionName)),
-	}
-
-	if err := repo.loadFromFile(); err != nil {
-		return nil, fmt.Errorf("failed to load data from file: %s", err.Error())
-	}
-	return repo, nil
+	return NewRepository[T](NewJSONDriver(baseDir, 0), collectionName)
 }
 
-// createEmptyFile creates an empty JSON file (which starts as an empty array).
-func (r *Repository[T]) createEmptyFile() error {
-	if err := os.MkdirAll(filepath.Dir(r.filePath), os.ModePerm); err != nil {
-		return fmt.Errorf("error creating directories: %s", err.Error())
-	}
+// load reads every entry of the collection and populates r.entries map.
+func (r *Repository[T]) load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	file, err := os.Create(r.filePath)
+	docs, err := r.driver.Load(r.collection, r.keyOf)
 	if err != nil {
-		return fmt.Errorf("error creating file: %s", err.Error())
+		return err
 	}
-	defer file.Close()
-
-	if _, err := file.Write([]byte("[]")); err != nil {
-		return fmt.Errorf("error initializing empty JSON file: %s", err.Error())
+	for _, doc := range docs {
+		item, err := r.decode(doc)
+		if err != nil {
+			return err
+		}
+		r.set(item.GetID(), &item)
 	}
 	return nil
 }
 
-// loadFromFile reads a slice of T from file and populates r.entries map.
-func (r *Repository[T]) loadFromFile() (err error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	log.Printf("Loading data from '%s'", r.filePath)
-
-	// Ensure the directory exists.
-	dir := filepath.Dir(r.filePath)
-	if _, err = os.Stat(dir); os.IsNotExist(err) {
-		if mkErr := os.MkdirAll(dir, 0755); mkErr != nil {
-			return fmt.Errorf("unable to create directory '%s': %v", dir, mkErr)
-		}
-	} else if err != nil {
-		return fmt.Errorf("error checking directory '%s': %v", dir, err)
+// decode unmarshals a stored document into T, rejecting entries without ID.
+func (r *Repository[T]) decode(data json.RawMessage) (T, error) {
+	var item T
+	if err := json.Unmarshal(data, &item); err != nil {
+		return item, fmt.Errorf("error decoding entry: %v", err)
 	}
-
-	file, err := os.Open(r.filePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			if createErr := r.createEmptyFile(); createErr != nil {
-				return fmt.Errorf("unable to create data file: %v", createErr)
-			}
-			return nil
-		}
-		return fmt.Errorf("unable to open data file: %v", err)
+	if item.GetID() == "" {
+		return item, fmt.Errorf("%w: empty ID", ErrInvalidEntry)
 	}
-	defer file.Close()
+	return item, nil
+}
 
-	var items []T
-	decoder := json.NewDecoder(file)
-	if decodeErr := decoder.Decode(&items); decodeErr != nil {
-		if decodeErr == io.EOF {
-			return nil
-		}
-		return fmt.Errorf("error decoding JSON from file: %v", decodeErr)
+func (r *Repository[T]) keyOf(data json.RawMessage) (string, error) {
+	item, err := r.decode(data)
+	if err != nil {
+		return "", err
 	}
+	return item.GetID(), nil
+}
 
-	for _, item := range items {
-		iCopy := item
-		r.entries[iCopy.GetID()] = &iCopy
+// FindByID retrieves an entity by its ID, soft deleted entities are not found.
+func (r *Repository[T]) FindByID(id string) (T, error) {
+	entry, err := r.FindByIDWithDeleted(id)
+	if err == nil && isDeleted(entry) {
+		var empty T
+		return empty, ErrNotFound
 	}
-	return nil
+	return entry, err
 }
 
-// FindByID retrieves an entity by its ID.
-func (r *Repository[T]) FindByID(id string) (T, error) {
+// FindByIDWithDeleted retrieves an entity by its ID, even when it is soft deleted.
+func (r *Repository[T]) FindByIDWithDeleted(id string) (T, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -135,60 +125,184 @@ func (r *Repository[T]) FindByID(id string) (T, error) {
 		return empty, ErrNotFound
 	}
 
-	return *entry, nil
+	return clone(*entry), nil
 }
 
-// ListAll retrieves all items
+// FindByIDTx retrieves an entity by its ID, including the changes staged in tx.
+func (r *Repository[T]) FindByIDTx(tx *Tx, id string) (T, error) {
+	entry, err := r.findTx(tx, id)
+	if err == nil && isDeleted(entry) {
+		var empty T
+		return empty, ErrNotFound
+	}
+	return entry, err
+}
+
+func (r *Repository[T]) findTx(tx *Tx, id string) (T, error) {
+	if staged, ok := tx.staged[stagedKey(r.collection, id)]; ok {
+		if staged == nil {
+			var empty T
+			return empty, ErrNotFound
+		}
+		return clone(staged.(T)), nil
+	}
+	return r.FindByIDWithDeleted(id)
+}
+
+// ListAll retrieves all items ordered by ID
 func (r *Repository[T]) ListAll() ([]T, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	return r.Query().All()
+}
+
+// FindByIndex retrieves the items stored under key in the named index, ordered by ID.
+func (r *Repository[T]) FindByIndex(name, key string) ([]T, error) {
+	return r.Query().ByIndex(name, key).All()
+}
 
-	var responseList []T
-	for _, item := range r.entries {
-		responseList = append(responseList, *item)
+// set stores the entry and refreshes the indexes, the caller must hold r.mu.
+func (r *Repository[T]) set(id string, entry *T) {
+	if old, ok := r.entries[id]; ok {
+		for _, idx := range r.indexes {
+			idx.remove(id, *old)
+		}
+	}
+	r.entries[id] = entry
+	for _, idx := range r.indexes {
+		idx.add(id, *entry)
 	}
-	return responseList, nil
+}
+
+// remove drops the entry and its index keys, the caller must hold r.mu.
+func (r *Repository[T]) remove(id string) {
+	old, ok := r.entries[id]
+	if !ok {
+		return
+	}
+	for _, idx := range r.indexes {
+		idx.remove(id, *old)
+	}
+	delete(r.entries, id)
 }
 
 // Save adds or updates the entity in the repository.
 // The entity must have a valid ID, otherwise it returns ErrInvalidEntry.
+// Repositories owned by a DBManager must be written through SaveTx instead.
 func (r *Repository[T]) Save(entity T) error {
-	id := (entity).GetID()
-	if id == "" {
-		return fmt.Errorf("%w: empty ID", ErrInvalidEntry)
+	op, err := r.putOp(entity)
+	if err != nil {
+		return err
 	}
 
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	copied := entity
-	r.entries[id] = &copied
+	if err := r.driver.Apply([]Op{op}); err != nil {
+		return fmt.Errorf("failed to save entity: %w", err)
+	}
+
+	copied := clone(entity)
+	r.set(op.ID, &copied)
+
+	return nil
+}
 
-	if err := r.saveToFile(); err != nil {
-		return fmt.Errorf("failed to save entity to file: %w", err)
+// SaveTx stages the entity in tx, it is stored once the transaction commits.
+func (r *Repository[T]) SaveTx(tx *Tx, entity T) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	op, err := r.putOp(entity)
+	if err != nil {
+		return err
 	}
 
+	copied := clone(entity)
+	tx.ops = append(tx.ops, op)
+	tx.staged[stagedKey(r.collection, op.ID)] = copied
+	tx.apply = append(tx.apply, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.set(op.ID, &copied)
+	})
 	return nil
 }
 
-// saveToFile writes the slice of T from r.entries to disk.
-func (r *Repository[T]) saveToFile() (err error) {
-	// Prepare a slice for JSON encoding
-	items := make([]T, 0, len(r.entries))
-	for _, entry := range r.entries {
-		items = append(items, *entry)
+func (r *Repository[T]) putOp(entity T) (Op, error) {
+	id := (entity).GetID()
+	if id == "" {
+		return Op{}, fmt.Errorf("%w: empty ID", ErrInvalidEntry)
 	}
 
-	file, err := os.OpenFile(r.filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	data, err := json.Marshal(entity)
 	if err != nil {
-		return fmt.Errorf("unable to open data file for writing: %s", err.Error())
+		return Op{}, fmt.Errorf("failed to encode entity: %w", err)
 	}
-	defer file.Close()
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(items); err != nil {
-		return fmt.Errorf("error encoding JSON to file: %s", err.Error())
+	return Op{Collection: r.collection, Kind: OpPut, ID: id, Data: data}, nil
+}
+
+// Delete removes the entity from the repository and the driver.
+// Repositories owned by a DBManager must be written through DeleteTx instead.
+func (r *Repository[T]) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.entries[id]; !exists {
+		return ErrNotFound
+	}
+	if err := r.driver.Apply([]Op{{Collection: r.collection, Kind: OpDelete, ID: id}}); err != nil {
+		return fmt.Errorf("failed to delete entity: %w", err)
 	}
+	r.remove(id)
+	return nil
+}
 
+// DeleteTx stages the removal of the entity in tx, soft deleted entities included.
+func (r *Repository[T]) DeleteTx(tx *Tx, id string) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	if _, err := r.findTx(tx, id); err != nil {
+		return err
+	}
+
+	tx.ops = append(tx.ops, Op{Collection: r.collection, Kind: OpDelete, ID: id})
+	tx.staged[stagedKey(r.collection, id)] = nil
+	tx.apply = append(tx.apply, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.remove(id)
+	})
 	return nil
 }
+
+// SoftDeleteTx stages a tombstone for the entity in tx, setting its DeletedAt.
+func (r *Repository[T]) SoftDeleteTx(tx *Tx, id string) error {
+	entry, err := r.FindByIDTx(tx, id)
+	if err != nil {
+		return err
+	}
+	soft, ok := any(entry).(SoftDeletable)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotSoftDeletable, r.collection)
+	}
+	now := tx.now
+	soft.SetDeletedAt(&now)
+	return r.SaveTx(tx, entry)
+}
+
+// RestoreTx stages the removal of the tombstone of a soft deleted entity in tx.
+func (r *Repository[T]) RestoreTx(tx *Tx, id string) error {
+	entry, err := r.findTx(tx, id)
+	if err != nil {
+		return err
+	}
+	soft, ok := any(entry).(SoftDeletable)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotSoftDeletable, r.collection)
+	}
+	if soft.GetDeletedAt() == nil {
+		return ErrNotDeleted
+	}
+	soft.SetDeletedAt(nil)
+	return r.SaveTx(tx, entry)
+}
@@ -0,0 +1,135 @@
+package memdb
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+// RevokeToken revokes a single token until expiresAt, when the token expires anyway.
+// When the token is the refresh token of a session, the session is revoked with it.
+// tokenID must be the UUID of the token.
+func (db *DBManager) RevokeToken(tokenID, username, reason, revokedBy string, expiresAt time.Time) (*models.Revocation, error) {
+	if _, err := uuid.Parse(tokenID); err != nil {
+		return nil, fmt.Errorf("RevokeToken: %w: token id %q is not a UUID", ErrInvalidEntry, tokenID)
+	}
+	revocation := &models.Revocation{
+		ID:        models.RevocationTokenPrefix + tokenID,
+		Username:  username,
+		Reason:    reason,
+		RevokedBy: revokedBy,
+		ExpiresAt: expiresAt,
+	}
+	err := db.Update(func(tx *Tx) error {
+		revocation.RevokedAt = tx.now
+		if err := db.pruneRevocationsTx(tx); err != nil {
+			return fmt.Errorf("RevokeToken: %w", err)
+		}
+		if err := db.revocationRepo.SaveTx(tx, revocation); err != nil {
+			return fmt.Errorf("RevokeToken: %w", err)
+		}
+		if _, err := db.sessionRepo.FindByIDTx(tx, tokenID); err == nil {
+			return db.sessionRepo.DeleteTx(tx, tokenID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revocation, nil
+}
+
+// RevokeUserTokens revokes every token of username issued before issuedBefore,
+// along with the sessions opened before it. maxLifetime is the longest a token lives,
+// once it has passed after issuedBefore every covered token has expired.
+// Revoking again keeps the latest of the two times.
+func (db *DBManager) RevokeUserTokens(username string, issuedBefore time.Time, maxLifetime time.Duration, reason, revokedBy string) (*models.Revocation, error) {
+	var revocation *models.Revocation
+	err := db.Update(func(tx *Tx) error {
+		if _, err := db.userProgressRepo.findTx(tx, username); err != nil {
+			return fmt.Errorf("RevokeUserTokens: %w", err)
+		}
+		if err := db.pruneRevocationsTx(tx); err != nil {
+			return fmt.Errorf("RevokeUserTokens: %w", err)
+		}
+
+		id := models.RevocationUserPrefix + username
+		if existing, err := db.revocationRepo.FindByIDTx(tx, id); err == nil && existing.IssuedBefore != nil && existing.IssuedBefore.After(issuedBefore) {
+			issuedBefore = *existing.IssuedBefore
+		}
+		revocation = &models.Revocation{
+			ID:           id,
+			Username:     username,
+			IssuedBefore: &issuedBefore,
+			Reason:       reason,
+			RevokedBy:    revokedBy,
+			RevokedAt:    tx.now,
+			ExpiresAt:    issuedBefore.Add(maxLifetime),
+		}
+		if err := db.revocationRepo.SaveTx(tx, revocation); err != nil {
+			return fmt.Errorf("RevokeUserTokens: %w", err)
+		}
+		if err := db.deleteSessionsTx(tx, username, func(s *models.Session) bool {
+			return s.CreatedAt.Before(issuedBefore)
+		}); err != nil {
+			return fmt.Errorf("RevokeUserTokens: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revocation, nil
+}
+
+// IsTokenRevoked reports whether the token tokenID of username, issued at issuedAt, was revoked.
+func (db *DBManager) IsTokenRevoked(tokenID, username string, issuedAt time.Time) (bool, error) {
+	_, err := db.revocationRepo.FindByID(models.RevocationTokenPrefix + tokenID)
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return false, fmt.Errorf("IsTokenRevoked: %w", err)
+	}
+	userRevocation, err := db.revocationRepo.FindByID(models.RevocationUserPrefix + username)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("IsTokenRevoked: %w", err)
+	}
+	if userRevocation.IssuedBefore == nil {
+		return false, nil
+	}
+	return issuedAt.Before(*userRevocation.IssuedBefore), nil
+}
+
+// ListRevocations returns the revocations still covering unexpired tokens.
+func (db *DBManager) ListRevocations(opts ListOptions) (Page[*models.Revocation], error) {
+	now := time.Now()
+	query := db.revocationRepo.Query().Where(func(r *models.Revocation) bool { return r.ExpiresAt.After(now) })
+	page, err := applyListOptions(query, opts).Page()
+	if err != nil {
+		return page, fmt.Errorf("ListRevocations: %w", err)
+	}
+	return page, nil
+}
+
+// pruneRevocationsTx drops the revocations whose tokens have all expired
+func (db *DBManager) pruneRevocationsTx(tx *Tx) error {
+	expired, err := db.revocationRepo.Query().
+		Where(func(r *models.Revocation) bool { return !r.ExpiresAt.After(tx.now) }).
+		All()
+	if err != nil {
+		return err
+	}
+	for _, revocation := range expired {
+		if err := db.revocationRepo.DeleteTx(tx, revocation.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
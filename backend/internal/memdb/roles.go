@@ -0,0 +1,116 @@
+package memdb
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+var (
+	ErrInvalidRole = errors.New("invalid role")
+	ErrLastAdmin   = errors.New("the last admin cannot lose the admin role")
+)
+
+// GetUser returns a live user.
+func (db *DBManager) GetUser(username string) (*models.User, error) {
+	return db.userProgressRepo.FindByID(username)
+}
+
+// SetUserRole changes the role of a user, it is carried by the tokens issued from the next login.
+// The last admin is never demoted, so the admin endpoints always stay reachable.
+func (db *DBManager) SetUserRole(username, role string) (*models.User, error) {
+	if !models.ValidRole(role) {
+		return nil, fmt.Errorf("SetUserRole: %w: %q", ErrInvalidRole, role)
+	}
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	user, err := db.userProgressRepo.FindByIDTx(tx, username)
+	if err != nil {
+		return nil, fmt.Errorf("SetUserRole: %w", err)
+	}
+	if user.GetRole() == models.RoleAdmin && role != models.RoleAdmin {
+		admins, err := db.countAdmins()
+		if err != nil {
+			return nil, fmt.Errorf("SetUserRole: %w", err)
+		}
+		if admins <= 1 {
+			return nil, fmt.Errorf("SetUserRole: %w", ErrLastAdmin)
+		}
+	}
+
+	user.Role = role
+	if err := db.userProgressRepo.SaveTx(tx, user); err != nil {
+		return nil, fmt.Errorf("SetUserRole: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("SetUserRole: %w", err)
+	}
+	return user, nil
+}
+
+// BootstrapAdmin makes username an admin, creating the user when needed,
+// as long as no admin exists yet. passwordHash is set when the user has no password.
+// It reports whether the user was promoted.
+func (db *DBManager) BootstrapAdmin(username, passwordHash string) (bool, error) {
+	if !models.ValidUsername(username) {
+		return false, fmt.Errorf("BootstrapAdmin: %w: %q", ErrInvalidUsername, username)
+	}
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	admins, err := db.countAdmins()
+	if err != nil {
+		return false, fmt.Errorf("BootstrapAdmin: %w", err)
+	}
+	if admins > 0 {
+		return false, nil
+	}
+
+	user, err := db.userProgressRepo.FindByIDTx(tx, username)
+	if errors.Is(err, ErrNotFound) {
+		if _, deletedErr := db.userProgressRepo.findTx(tx, username); deletedErr == nil {
+			return false, fmt.Errorf("BootstrapAdmin: %w", ErrUserDeleted)
+		}
+		user = &models.User{
+			Username:         username,
+			CreatedAt:        time.Now(),
+			QuestionsFlowsID: make([]string, 0),
+		}
+	} else if err != nil {
+		return false, fmt.Errorf("BootstrapAdmin: %w", err)
+	}
+
+	user.Role = models.RoleAdmin
+	if user.PasswordHash == "" {
+		user.PasswordHash = passwordHash
+	}
+	if err := db.userProgressRepo.SaveTx(tx, user); err != nil {
+		return false, fmt.Errorf("BootstrapAdmin: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("BootstrapAdmin: %w", err)
+	}
+	return true, nil
+}
+
+// SetPasswordHash replaces the password of a user, passwordHash must already be hashed.
+func (db *DBManager) SetPasswordHash(username, passwordHash string) error {
+	return db.Update(func(tx *Tx) error {
+		user, err := db.userProgressRepo.FindByIDTx(tx, username)
+		if err != nil {
+			return fmt.Errorf("SetPasswordHash: %w", err)
+		}
+		user.PasswordHash = passwordHash
+		return db.userProgressRepo.SaveTx(tx, user)
+	})
+}
+
+func (db *DBManager) countAdmins() (int, error) {
+	return db.userProgressRepo.Query().
+		Where(func(u *models.User) bool { return u.GetRole() == models.RoleAdmin }).
+		Count()
+}
@@ -6,40 +6,19 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/matheuspolitano/quiz-go/backend/internal/grading"
 	"github.com/matheuspolitano/quiz-go/backend/internal/models"
-	"github.com/matheuspolitano/quiz-go/backend/internal/utils"
 )
 
 var (
 	ErrFlowClosed           = errors.New("question flow is already closed")
 	ErrUsernameAlreadyExist = errors.New("username already exist")
+	ErrInvalidUsername      = errors.New("username cannot be empty or contain ':'")
 	ErrNoQuestions          = errors.New("no questions available for this question type")
 	ErrAllQuestionsAnswered = errors.New("all questions have been answered in this flow")
+	ErrUserDeleted          = errors.New("user has been deleted")
 )
 
-func (db *DBManager) GetScoreUser(userID, quizType string) (*models.QuestionFlow, float32, error) {
-	questionFlow, err := db.questionsFlowRepo.FindByID(utils.CombineIDs(userID, quizType))
-	if err != nil {
-		return nil, 0, err
-	}
-
-	allQuestionsFlow, err := db.questionsFlowRepo.ListAll()
-	if err != nil {
-		return nil, 0, err
-	}
-	var total float32
-	var length float32
-	for _, item := range allQuestionsFlow {
-		if !item.ClosedAt.IsZero() && item.TypeQuizName == questionFlow.TypeQuizName {
-			total += float32(item.AccuracyRate)
-			length++
-		}
-
-	}
-
-	return questionFlow, total / length, nil
-}
-
 func (db *DBManager) GetQuestion(id string) (*models.Question, error) {
 	question, err := db.questionRepo.FindByID(id)
 	if err != nil {
@@ -48,6 +27,64 @@ func (db *DBManager) GetQuestion(id string) (*models.Question, error) {
 	return question, nil
 }
 
+// AnswerRevealed reports whether a player may see the answer of a question:
+// they answered it in a flow that has since closed, and no open attempt of
+// theirs asks it again.
+func (db *DBManager) AnswerRevealed(userID, questionID string) (bool, error) {
+	answers, err := db.historyRepo.Query().
+		ByIndex(indexByQuestion, questionID).
+		Where(func(h *models.History) bool { return h.UserID == userID }).
+		All()
+	if err != nil || len(answers) == 0 {
+		return false, err
+	}
+	answered := make(map[string]bool, len(answers))
+	for _, h := range answers {
+		answered[h.ID] = true
+	}
+	closed, err := db.questionsFlowRepo.Query().
+		ByIndex(indexByUser, userID).
+		Where(func(f *models.QuestionFlow) bool {
+			if f.ClosedAt.IsZero() {
+				return false
+			}
+			for _, histID := range f.History {
+				if answered[histID] {
+					return true
+				}
+			}
+			return false
+		}).
+		Count()
+	if err != nil || closed == 0 {
+		return false, err
+	}
+	open, err := db.questionsFlowRepo.Query().
+		ByIndex(indexByUser, userID).
+		Where(func(f *models.QuestionFlow) bool { return f.ClosedAt.IsZero() }).
+		All()
+	if err != nil {
+		return false, err
+	}
+	for _, flow := range open {
+		questions := flow.QuestionsID
+		if questions == nil {
+			typeQuiz, err := db.TypeQuizRepo.FindByIDWithDeleted(flow.TypeQuizName)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return false, fmt.Errorf("AnswerRevealed: %w", err)
+			}
+			questions = flow.Questions(typeQuiz)
+		}
+		if containsString(questions, questionID) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 func (db *DBManager) ListAllTypes() ([]*models.TypeQuiz, error) {
 	TypesQuiz, err := db.TypeQuizRepo.ListAll()
 	if err != nil {
@@ -56,84 +93,81 @@ func (db *DBManager) ListAllTypes() ([]*models.TypeQuiz, error) {
 	return TypesQuiz, nil
 }
 
-func (db *DBManager) CreateUser(username string) (*models.User, error) {
-	_, err := db.userProgressRepo.FindByID(username)
+// CreateUser registers a new player, passwordHash must already be hashed.
+func (db *DBManager) CreateUser(username, passwordHash string) (*models.User, error) {
+	if !models.ValidUsername(username) {
+		return nil, ErrInvalidUsername
+	}
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	existing, err := db.userProgressRepo.findTx(tx, username)
 	if err == nil {
+		if existing.DeletedAt != nil {
+			return nil, ErrUserDeleted
+		}
 		return nil, ErrUsernameAlreadyExist
 	}
 	user := &models.User{
 		Username:         username,
+		Role:             models.RolePlayer,
+		PasswordHash:     passwordHash,
 		CreatedAt:        time.Now(),
 		QuestionsFlowsID: make([]string, 0),
 	}
-	err = db.userProgressRepo.Save(user)
-	if err != nil {
+	if err := db.userProgressRepo.SaveTx(tx, user); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
+// AddQuestionFlow returns the latest attempt of a user at a quiz type, open or closed,
+// and starts the first attempt when there is none.
 func (db *DBManager) AddQuestionFlow(userID, TypeQuizName string) (*models.QuestionFlow, error) {
-	db.globalMu.Lock()
-	defer db.globalMu.Unlock()
+	tx := db.Begin()
+	defer tx.Rollback()
 
-	_, err := db.TypeQuizRepo.FindByID(TypeQuizName)
+	attempts, err := db.attempts(userID, TypeQuizName)
 	if err != nil {
-		return nil, fmt.Errorf("AddQuestionFlow: TypeQuiz does not exist: %s", err.Error())
+		return nil, fmt.Errorf("AddQuestionFlow: %w", err)
 	}
-
-	flowID := utils.CombineIDs(userID, TypeQuizName)
-	questionFlow, err := db.questionsFlowRepo.FindByID(flowID)
-	if err == nil {
-		return questionFlow, nil
-	}
-	if !errors.Is(err, ErrNotFound) {
-		return nil, err
+	if latest := latestAttempt(attempts); latest != nil {
+		return latest, nil
 	}
-
-	newFlow := &models.QuestionFlow{
-		UserID:       userID,
-		TypeQuizName: TypeQuizName,
-		CreatedAt:    time.Now(),
-		AccuracyRate: 1.0,
-		ClosedAt:     time.Time{},
-		History:      make([]string, 0),
-	}
-	user, uErr := db.userProgressRepo.FindByID(userID)
-	if uErr != nil {
-		return nil, fmt.Errorf("AddQuestionFlow: failed to find user: %s", uErr.Error())
-	}
-	if saveErr := db.questionsFlowRepo.Save(newFlow); saveErr != nil {
-		return nil, fmt.Errorf("AddQuestionFlow: failed to save new flow: %s", saveErr.Error())
+	newFlow, err := db.startAttemptTx(tx, userID, TypeQuizName, attempts)
+	if err != nil {
+		return nil, fmt.Errorf("AddQuestionFlow: %w", err)
 	}
-
-	user.QuestionsFlowsID = append(user.QuestionsFlowsID, newFlow.GetID())
-	if saveUserErr := db.userProgressRepo.Save(user); saveUserErr != nil {
-		return nil, fmt.Errorf("AddQuestionFlow: failed to update user flows: %s", saveUserErr.Error())
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("AddQuestionFlow: %s", err.Error())
 	}
-
 	return newFlow, nil
 }
 
-// NextQuestion retrieves the next question for an existing QuestionFlow.
+// NextQuestion retrieves the next question for an existing QuestionFlow, as the flow shows it.
 // Returns (Question, nil) when a question is found,
 // returns an error (ErrFlowClosed, ErrNoQuestions, ErrAllQuestionsAnswered, etc.) otherwise.
 func (db *DBManager) NextQuestion(questionFlowID string) (*models.Question, error) {
-	db.globalMu.Lock()
-	defer db.globalMu.Unlock()
+	tx := db.Begin()
+	defer tx.Rollback()
 
-	qFlow, err := db.questionsFlowRepo.FindByID(questionFlowID)
+	qFlow, err := db.questionsFlowRepo.FindByIDTx(tx, questionFlowID)
 	if err != nil {
 		return nil, fmt.Errorf("NextQuestion: question flow not found: %s", err.Error())
 	}
 	if !qFlow.ClosedAt.IsZero() {
 		return nil, ErrFlowClosed
 	}
-	tQuestion, err := db.TypeQuizRepo.FindByID(qFlow.TypeQuizName)
+	// a quiz type retired after the flow started still serves the flow until it is done
+	tQuestion, err := db.TypeQuizRepo.FindByIDWithDeleted(qFlow.TypeQuizName)
 	if err != nil {
 		return nil, fmt.Errorf("NextQuestion: TypeQuiz not found: %s", err.Error())
 	}
-	if len(tQuestion.QuestionsID) == 0 {
+	questions := qFlow.Questions(tQuestion)
+	if len(questions) == 0 {
 		return nil, ErrNoQuestions
 	}
 
@@ -146,19 +180,23 @@ func (db *DBManager) NextQuestion(questionFlowID string) (*models.Question, erro
 		answeredQuestionIDs[histEntry.QuestionID] = true
 	}
 
-	for _, qID := range tQuestion.QuestionsID {
+	for _, qID := range qFlow.QuestionOrder(questions) {
 		if !answeredQuestionIDs[qID] {
-			nextQ, qErr := db.questionRepo.FindByID(qID)
+			// a question retired after the flow drew it is still asked, like its quiz type
+			nextQ, qErr := db.questionRepo.FindByIDWithDeleted(qID)
 			if qErr != nil {
 				continue
 			}
-			return nextQ, nil
+			return qFlow.Present(nextQ), nil
 		}
 	}
 
 	var lastAnswerTime time.Time
 	for _, histID := range qFlow.History {
-		histEntry, _ := db.historyRepo.FindByID(histID)
+		histEntry, hErr := db.historyRepo.FindByID(histID)
+		if hErr != nil {
+			continue
+		}
 		if histEntry.CreatedAt.After(lastAnswerTime) {
 			lastAnswerTime = histEntry.CreatedAt
 		}
@@ -168,17 +206,20 @@ func (db *DBManager) NextQuestion(questionFlowID string) (*models.Question, erro
 	}
 
 	qFlow.ClosedAt = lastAnswerTime
-	_ = db.questionsFlowRepo.Save(qFlow)
+	if err := db.questionsFlowRepo.SaveTx(tx, qFlow); err == nil {
+		_ = tx.Commit()
+	}
 
 	return nil, ErrAllQuestionsAnswered
 }
 
-// AddAnswer stores an answer for a specific question in the flow.
+// AddAnswer stores an answer for a specific question in the flow. The answer is given on the options
+// shown by the flow, the history keeps it on the stored options like the expected answer.
 func (db *DBManager) AddAnswer(questionFlowID, questionID, userAnswer string) (*models.History, error) {
-	db.globalMu.Lock()
-	defer db.globalMu.Unlock()
+	tx := db.Begin()
+	defer tx.Rollback()
 
-	qFlow, err := db.questionsFlowRepo.FindByID(questionFlowID)
+	qFlow, err := db.questionsFlowRepo.FindByIDTx(tx, questionFlowID)
 	if err != nil {
 		return nil, fmt.Errorf("AddAnswer: question flow not found: %s", err.Error())
 	}
@@ -186,18 +227,12 @@ func (db *DBManager) AddAnswer(questionFlowID, questionID, userAnswer string) (*
 		return nil, ErrFlowClosed
 	}
 
-	typeQ, err := db.TypeQuizRepo.FindByID(qFlow.TypeQuizName)
+	// like NextQuestion, a quiz type retired after the flow started still takes its answers
+	typeQ, err := db.TypeQuizRepo.FindByIDWithDeleted(qFlow.TypeQuizName)
 	if err != nil {
 		return nil, fmt.Errorf("AddAnswer: invalid TypeQuiz: %s", err.Error())
 	}
-	found := false
-	for _, qID := range typeQ.QuestionsID {
-		if qID == questionID {
-			found = true
-			break
-		}
-	}
-	if !found {
+	if !containsString(qFlow.Questions(typeQ), questionID) {
 		return nil, fmt.Errorf("AddAnswer: question %s not part of TypeQuiz %s", questionID, typeQ.Name)
 	}
 
@@ -214,42 +249,57 @@ func (db *DBManager) AddAnswer(questionFlowID, questionID, userAnswer string) (*
 		return nil, fmt.Errorf("AddAnswer: question already answer. Use the next to get the question without answer")
 	}
 
-	questionObj, err := db.questionRepo.FindByID(questionID)
+	questionObj, err := db.questionRepo.FindByIDWithDeleted(questionID)
 	if err != nil {
 		return nil, fmt.Errorf("AddAnswer: cannot find question %s: %w", questionID, err)
 	}
 
+	answer := qFlow.CanonicalAnswer(questionObj, userAnswer)
+	score := grading.Grade(questionObj, answer)
 	newHist := &models.History{
 		ID:             uuid.NewString(),
 		UserID:         qFlow.UserID,
 		QuestionID:     questionID,
-		Answer:         userAnswer,
+		Answer:         answer,
 		ExpectedAnswer: questionObj.Answer,
+		Score:          &score,
 		CreatedAt:      time.Now(),
 	}
-	if err := db.historyRepo.Save(newHist); err != nil {
+	if err := db.historyRepo.SaveTx(tx, newHist); err != nil {
 		return nil, fmt.Errorf("AddAnswer: failed to save new History: %s", err.Error())
 	}
 
 	qFlow.History = append(qFlow.History, newHist.ID)
 
-	correctCount := 0
+	var credit float32
 	for _, histID := range qFlow.History {
-		h, herr := db.historyRepo.FindByID(histID)
-		if herr == nil && h.ExpectedAnswer == h.Answer {
-			correctCount++
+		h, herr := db.historyRepo.FindByIDTx(tx, histID)
+		if herr == nil {
+			credit += h.Credit()
 		}
 	}
 	totalAnswers := len(qFlow.History)
 	if totalAnswers > 0 {
-		qFlow.AccuracyRate = float32(correctCount) / float32(totalAnswers)
+		qFlow.AccuracyRate = credit / float32(totalAnswers)
 	} else {
 		qFlow.AccuracyRate = 1.0
 	}
 
-	if err := db.questionsFlowRepo.Save(qFlow); err != nil {
+	if err := db.questionsFlowRepo.SaveTx(tx, qFlow); err != nil {
 		return nil, fmt.Errorf("AddAnswer: failed to update question flow with new history: %s", err.Error())
 	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("AddAnswer: %s", err.Error())
+	}
 
 	return newHist, nil
 }
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package memdb
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+var ErrSessionExpired = errors.New("session has expired")
+
+// CreateSession stores a new session, the expired sessions of the same user are dropped on the way.
+func (db *DBManager) CreateSession(session *models.Session) error {
+	return db.Update(func(tx *Tx) error {
+		if _, err := db.userProgressRepo.FindByIDTx(tx, session.Username); err != nil {
+			return fmt.Errorf("CreateSession: %w", err)
+		}
+		if err := db.deleteSessionsTx(tx, session.Username, func(s *models.Session) bool {
+			return s.Expired(tx.now)
+		}); err != nil {
+			return fmt.Errorf("CreateSession: %w", err)
+		}
+		if err := db.sessionRepo.SaveTx(tx, session); err != nil {
+			return fmt.Errorf("CreateSession: %w", err)
+		}
+		return nil
+	})
+}
+
+// RefreshSession returns the session of a refresh token and records it was used.
+// A session of another user is reported as not found, an expired one is removed.
+func (db *DBManager) RefreshSession(id, username string) (*models.Session, error) {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	session, err := db.sessionRepo.FindByIDTx(tx, id)
+	if err != nil {
+		return nil, fmt.Errorf("RefreshSession: %w", err)
+	}
+	if session.Username != username {
+		return nil, fmt.Errorf("RefreshSession: %w", ErrNotFound)
+	}
+	if session.Expired(tx.now) {
+		if err := db.sessionRepo.DeleteTx(tx, id); err != nil {
+			return nil, fmt.Errorf("RefreshSession: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("RefreshSession: %w", err)
+		}
+		return nil, fmt.Errorf("RefreshSession: %w", ErrSessionExpired)
+	}
+
+	session.LastUsedAt = tx.now
+	if err := db.sessionRepo.SaveTx(tx, session); err != nil {
+		return nil, fmt.Errorf("RefreshSession: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("RefreshSession: %w", err)
+	}
+	return session, nil
+}
+
+// ListSessions returns the live sessions of a user, the most recently used first.
+func (db *DBManager) ListSessions(username string) ([]*models.Session, error) {
+	now := time.Now()
+	sessions, err := db.sessionRepo.Query().
+		ByIndex(indexByUser, username).
+		Where(func(s *models.Session) bool { return !s.Expired(now) }).
+		All()
+	if err != nil {
+		return nil, fmt.Errorf("ListSessions: %w", err)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt) })
+	return sessions, nil
+}
+
+// DeleteSession revokes a session of username, the sessions of other users are reported as not found.
+func (db *DBManager) DeleteSession(username, id string) error {
+	return db.Update(func(tx *Tx) error {
+		session, err := db.sessionRepo.FindByIDTx(tx, id)
+		if err != nil {
+			return fmt.Errorf("DeleteSession: %w", err)
+		}
+		if session.Username != username {
+			return fmt.Errorf("DeleteSession: %w", ErrNotFound)
+		}
+		return db.sessionRepo.DeleteTx(tx, id)
+	})
+}
+
+// DeleteUserSessions revokes every session of username.
+func (db *DBManager) DeleteUserSessions(username string) error {
+	return db.Update(func(tx *Tx) error {
+		if err := db.deleteSessionsTx(tx, username, func(*models.Session) bool { return true }); err != nil {
+			return fmt.Errorf("DeleteUserSessions: %w", err)
+		}
+		return nil
+	})
+}
+
+func (db *DBManager) deleteSessionsTx(tx *Tx, username string, match func(*models.Session) bool) error {
+	sessions, err := db.sessionRepo.FindByIndex(indexByUser, username)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if !match(session) {
+			continue
+		}
+		if err := db.sessionRepo.DeleteTx(tx, session.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
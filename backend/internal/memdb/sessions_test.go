@@ -0,0 +1,181 @@
+package memdb
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+// newSessionTestDB returns a database holding the users alice and bob
+func newSessionTestDB(t *testing.T) *DBManager {
+	t.Helper()
+	db, err := NewDBManager(NewMemoryDriver())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, username := range []string{"alice", "bob"} {
+		if _, err := db.CreateUser(username, "hash"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// openSession stores a session of username expiring after lifetime, negative for an expired one
+func openSession(t *testing.T, db *DBManager, username string, lifetime time.Duration) *models.Session {
+	t.Helper()
+	now := time.Now()
+	session := &models.Session{
+		ID:         uuid.NewString(),
+		Username:   username,
+		CreatedAt:  now.Add(-time.Minute),
+		LastUsedAt: now.Add(-time.Minute),
+		ExpiresAt:  now.Add(lifetime),
+	}
+	if err := db.CreateSession(session); err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+func TestRefreshSession(t *testing.T) {
+	db := newSessionTestDB(t)
+	session := openSession(t, db, "alice", time.Hour)
+
+	refreshed, err := db.RefreshSession(session.ID, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !refreshed.LastUsedAt.After(session.LastUsedAt) {
+		t.Errorf("last used at %v, want after %v", refreshed.LastUsedAt, session.LastUsedAt)
+	}
+	// the refresh token keeps its session, it is used again on the next refresh
+	if _, err := db.RefreshSession(session.ID, "alice"); err != nil {
+		t.Errorf("second refresh: %v", err)
+	}
+	// a refresh token naming another user does not find the session
+	if _, err := db.RefreshSession(session.ID, "bob"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("refresh as another user: got %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestRefreshExpiredSession(t *testing.T) {
+	db := newSessionTestDB(t)
+	session := openSession(t, db, "alice", -time.Second)
+
+	if _, err := db.RefreshSession(session.ID, "alice"); !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("refresh of an expired session: got %v, want %v", err, ErrSessionExpired)
+	}
+	// the expired session is removed by the refresh
+	if _, err := db.RefreshSession(session.ID, "alice"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("refresh after removal: got %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestCreateSessionDropsExpired(t *testing.T) {
+	db := newSessionTestDB(t)
+	expired := openSession(t, db, "alice", -time.Second)
+	live := openSession(t, db, "alice", time.Hour)
+
+	if _, err := db.sessionRepo.FindByID(expired.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expired session: got %v, want %v", err, ErrNotFound)
+	}
+	sessions, err := db.ListSessions("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != live.ID {
+		t.Errorf("sessions %v, want only %s", sessions, live.ID)
+	}
+}
+
+func TestDeleteSession(t *testing.T) {
+	db := newSessionTestDB(t)
+	first := openSession(t, db, "alice", time.Hour)
+	second := openSession(t, db, "alice", time.Hour)
+	other := openSession(t, db, "bob", time.Hour)
+
+	// a user cannot revoke the session of another
+	if err := db.DeleteSession("alice", other.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("delete the session of another user: got %v, want %v", err, ErrNotFound)
+	}
+	if err := db.DeleteSession("alice", first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.RefreshSession(first.ID, "alice"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("refresh of a revoked session: got %v, want %v", err, ErrNotFound)
+	}
+	if _, err := db.RefreshSession(second.ID, "alice"); err != nil {
+		t.Errorf("refresh of the other session: %v", err)
+	}
+
+	if err := db.DeleteUserSessions("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if sessions, err := db.ListSessions("alice"); err != nil || len(sessions) != 0 {
+		t.Errorf("sessions after revoking all: %v, %v", sessions, err)
+	}
+	if _, err := db.RefreshSession(other.ID, "bob"); err != nil {
+		t.Errorf("the sessions of another user were revoked: %v", err)
+	}
+}
+
+func TestRevokeTokens(t *testing.T) {
+	db := newSessionTestDB(t)
+	session := openSession(t, db, "alice", time.Hour)
+	accessID := uuid.NewString()
+
+	// revoking the refresh token revokes its session
+	if _, err := db.RevokeToken(session.ID, "alice", "lost laptop", "admin", session.ExpiresAt); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.RefreshSession(session.ID, "alice"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("refresh of a revoked token: got %v, want %v", err, ErrNotFound)
+	}
+	for id, want := range map[string]bool{session.ID: true, accessID: false} {
+		revoked, err := db.IsTokenRevoked(id, "alice", time.Now())
+		if err != nil || revoked != want {
+			t.Errorf("IsTokenRevoked(%s) = %v, %v, want %v", id, revoked, err, want)
+		}
+	}
+	if _, err := db.RevokeToken("not-a-uuid", "alice", "", "admin", session.ExpiresAt); !errors.Is(err, ErrInvalidEntry) {
+		t.Errorf("revoke a token without UUID: got %v, want %v", err, ErrInvalidEntry)
+	}
+}
+
+func TestRevokeUserTokens(t *testing.T) {
+	db := newSessionTestDB(t)
+	before := openSession(t, db, "alice", time.Hour)
+	cutoff := time.Now()
+
+	if _, err := db.RevokeUserTokens("alice", cutoff, time.Hour, "password reset", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.RefreshSession(before.ID, "alice"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("session opened before the revocation: got %v, want %v", err, ErrNotFound)
+	}
+	for issuedAt, want := range map[time.Time]bool{cutoff.Add(-time.Second): true, cutoff.Add(time.Second): false} {
+		revoked, err := db.IsTokenRevoked(uuid.NewString(), "alice", issuedAt)
+		if err != nil || revoked != want {
+			t.Errorf("token issued at %v: revoked %v, %v, want %v", issuedAt, revoked, err, want)
+		}
+	}
+	if revoked, err := db.IsTokenRevoked(uuid.NewString(), "bob", cutoff.Add(-time.Second)); err != nil || revoked {
+		t.Errorf("token of another user: revoked %v, %v", revoked, err)
+	}
+
+	// revoking again with an earlier time keeps the later one
+	if _, err := db.RevokeUserTokens("alice", cutoff.Add(-time.Hour), time.Hour, "", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, _ := db.IsTokenRevoked(uuid.NewString(), "alice", cutoff.Add(-time.Second)); !revoked {
+		t.Error("an earlier revocation replaced the later one")
+	}
+	if _, err := db.RevokeUserTokens("nobody", cutoff, time.Hour, "", "admin"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("revoke the tokens of an unknown user: got %v, want %v", err, ErrNotFound)
+	}
+}
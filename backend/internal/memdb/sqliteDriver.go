@@ -0,0 +1,177 @@
+package memdb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	// pure Go SQLite, keeps the CGO_ENABLED=0 build working
+	_ "modernc.org/sqlite"
+)
+
+// sqliteDriver stores every collection in its own SQLite table,
+// one row per entry with the entry encoded as JSON.
+type sqliteDriver struct {
+	db *sql.DB
+}
+
+// NewSQLiteDriver opens (or creates) the database at path and runs the pending migrations.
+func NewSQLiteDriver(path string) (Driver, error) {
+	return newSQLiteDriver(path)
+}
+
+func newSQLiteDriver(path string) (*sqliteDriver, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("unable to create directory for '%s': %v", path, err)
+	}
+
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open sqlite database: %v", err)
+	}
+	// SQLite allows a single writer, a single connection avoids SQLITE_BUSY between our own goroutines.
+	db.SetMaxOpenConns(1)
+
+	driver := &sqliteDriver{db: db}
+	if err := driver.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return driver, nil
+}
+
+// migrate applies every migration newer than the current schema version.
+func (d *sqliteDriver) migrate() error {
+	_, err := d.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("unable to create schema_migrations table: %v", err)
+	}
+
+	var current int
+	if err := d.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("unable to read schema version: %v", err)
+	}
+
+	for _, m := range sqliteMigrations {
+		if m.version <= current {
+			continue
+		}
+		log.Printf("Applying sqlite migration %d: %s", m.version, m.name)
+		if err := d.withTx(func(tx *sql.Tx) error {
+			for _, stmt := range m.stmts {
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			_, err := tx.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+				m.version, m.name, time.Now().UTC())
+			return err
+		}); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %v", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+func (d *sqliteDriver) withTx(fn func(tx *sql.Tx) error) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func tableFor(collection string) (string, error) {
+	table, ok := collectionTables[collection]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown collection %q", ErrInvalidEntry, collection)
+	}
+	return table, nil
+}
+
+// Load reads every row of the collection table.
+func (d *sqliteDriver) Load(collection string, _ KeyFunc) ([]json.RawMessage, error) {
+	table, err := tableFor(collection)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Loading data from sqlite table '%s'", table)
+
+	rows, err := d.db.Query(fmt.Sprintf(`SELECT data FROM %s ORDER BY id`, table))
+	if err != nil {
+		return nil, fmt.Errorf("unable to query table '%s': %v", table, err)
+	}
+	defer rows.Close()
+
+	var docs []json.RawMessage
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("unable to scan row from '%s': %v", table, err)
+		}
+		docs = append(docs, json.RawMessage(data))
+	}
+	return docs, rows.Err()
+}
+
+// Apply persists the whole batch in a single SQL transaction.
+func (d *sqliteDriver) Apply(ops []Op) error {
+	now := time.Now().UTC()
+	return d.withTx(func(tx *sql.Tx) error {
+		for _, op := range ops {
+			table, err := tableFor(op.Collection)
+			if err != nil {
+				return err
+			}
+			switch op.Kind {
+			case OpPut:
+				_, err = tx.Exec(fmt.Sprintf(`INSERT INTO %s (id, data, updated_at) VALUES (?, ?, ?)
+					ON CONFLICT(id) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at`, table),
+					op.ID, string(op.Data), now)
+			case OpDelete:
+				_, err = tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE id = ?`, table), op.ID)
+			default:
+				err = fmt.Errorf("%w: unknown operation %q", ErrInvalidEntry, op.Kind)
+			}
+			if err != nil {
+				return fmt.Errorf("unable to apply %s on %s/%s: %w", op.Kind, op.Collection, op.ID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// metadata returns the value stored under key, or "" when it is not set.
+func (d *sqliteDriver) metadata(key string) (string, error) {
+	var value string
+	err := d.db.QueryRow(`SELECT value FROM metadata WHERE key = ?`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return value, err
+}
+
+func (d *sqliteDriver) setMetadata(key, value string) error {
+	_, err := d.db.Exec(`INSERT INTO metadata (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value`, key, value)
+	return err
+}
+
+// Close closes the database.
+func (d *sqliteDriver) Close() error {
+	return d.db.Close()
+}
@@ -0,0 +1,70 @@
+package memdb
+
+import (
+	"time"
+
+	"github.com/matheuspolitano/quiz-go/backend/internal/models"
+)
+
+// Store is the storage contract used by the api package.
+// DBManager implements it on top of a Driver, any other backend only needs
+// to satisfy this interface to be plugged into the server.
+type Store interface {
+	CreateUser(username, passwordHash string) (*models.User, error)
+	AddQuestionFlow(userID, TypeQuizName string) (*models.QuestionFlow, error)
+	NextQuestion(questionFlowID string) (*models.Question, error)
+	AddAnswer(questionFlowID, questionID, userAnswer string) (*models.History, error)
+	StartAttempt(userID, typeQuizName string) (*models.QuestionFlow, error)
+	CurrentAttempt(userID, typeQuizName string) (*models.QuestionFlow, error)
+	ListAttempts(userID, typeQuizName string) ([]*models.QuestionFlow, error)
+	GetScoreUser(userID, quizType string) (*UserScore, error)
+	ListAllTypes() ([]*models.TypeQuiz, error)
+	GetQuestion(id string) (*models.Question, error)
+	AnswerRevealed(userID, questionID string) (bool, error)
+
+	DeleteUser(username string, opts DeleteOptions) error
+	RestoreUser(username string) error
+	DeleteQuestion(id string, opts DeleteOptions) error
+	RestoreQuestion(id string) error
+	DeleteQuestionFlow(userID, typeQuizName string, attempt int, opts DeleteOptions) error
+	RestoreQuestionFlow(userID, typeQuizName string, attempt int) error
+
+	ReloadQuestionBank(dir string) (*ReloadReport, error)
+
+	ListQuestions(opts ListOptions) (Page[*models.Question], error)
+	CreateQuestion(question *models.Question) (*models.Question, error)
+	UpdateQuestion(question *models.Question) (*models.Question, error)
+	ListTypeQuizzes(opts ListOptions) (Page[*models.TypeQuiz], error)
+	GetTypeQuiz(name string) (*models.TypeQuiz, error)
+	CreateTypeQuiz(typeQuiz *models.TypeQuiz) (*models.TypeQuiz, error)
+	UpdateTypeQuiz(typeQuiz *models.TypeQuiz) (*models.TypeQuiz, error)
+	DeleteTypeQuiz(name string, opts DeleteOptions) error
+	RestoreTypeQuiz(name string) error
+
+	GetUser(username string) (*models.User, error)
+	SetUserRole(username, role string) (*models.User, error)
+	SetPasswordHash(username, passwordHash string) error
+	FindOrCreateOIDCUser(subject, username string, linkExisting bool) (*models.User, error)
+	GetTypeQuizReport(typeQuizName string) (*TypeQuizReport, error)
+
+	CreateSession(session *models.Session) error
+	RefreshSession(id, username string) (*models.Session, error)
+	ListSessions(username string) ([]*models.Session, error)
+	DeleteSession(username, id string) error
+	DeleteUserSessions(username string) error
+
+	RevokeToken(tokenID, username, reason, revokedBy string, expiresAt time.Time) (*models.Revocation, error)
+	RevokeUserTokens(username string, issuedBefore time.Time, maxLifetime time.Duration, reason, revokedBy string) (*models.Revocation, error)
+	IsTokenRevoked(tokenID, username string, issuedAt time.Time) (bool, error)
+	ListRevocations(opts ListOptions) (Page[*models.Revocation], error)
+
+	CreateAPIKey(key *models.APIKey) (*models.APIKey, error)
+	GetAPIKey(id string) (*models.APIKey, error)
+	ListAPIKeys(opts ListOptions) (Page[*models.APIKey], error)
+	RevokeAPIKey(id string) error
+
+	AppendAudit(event *models.AuditEvent) error
+	ListAuditEvents(filter AuditFilter, opts ListOptions) (Page[*models.AuditEvent], error)
+}
+
+var _ Store = (*DBManager)(nil)
@@ -0,0 +1,85 @@
+package memdb
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrTxDone = errors.New("transaction has already been committed or rolled back")
+
+// Tx groups the changes made to several repositories so they are applied
+// together or not at all, on disk and in memory.
+// Write transactions are serialized, reads outside a transaction only
+// ever observe committed data.
+type Tx struct {
+	db     *DBManager
+	ops    []Op
+	apply  []func()
+	staged map[string]any
+	done   bool
+	// now is stamped on the changes of the transaction, e.g. DeletedAt
+	now time.Time
+	// fromBankFiles marks the changes read from the bank files, they need no write back
+	fromBankFiles bool
+}
+
+// Begin starts a write transaction, it must be ended with Commit or Rollback.
+func (db *DBManager) Begin() *Tx {
+	db.txMu.Lock()
+	return &Tx{db: db, staged: make(map[string]any), now: time.Now()}
+}
+
+// Update runs fn in a transaction, committing it when fn returns nil.
+func (db *DBManager) Update(fn func(tx *Tx) error) error {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Commit persists every staged operation through the driver in a single batch
+// and then publishes them to the in-memory repositories.
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	defer tx.finish()
+
+	if len(tx.ops) == 0 {
+		return nil
+	}
+	if err := tx.db.driver.Apply(tx.ops); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	for _, apply := range tx.apply {
+		apply()
+	}
+	if !tx.fromBankFiles && touchesQuestionBank(tx.ops) {
+		tx.db.saveQuestionBank()
+	}
+	return nil
+}
+
+// Rollback discards the staged operations. It is a no-op after Commit.
+func (tx *Tx) Rollback() {
+	if tx.done {
+		return
+	}
+	tx.finish()
+}
+
+func (tx *Tx) finish() {
+	tx.done = true
+	tx.ops = nil
+	tx.apply = nil
+	tx.staged = nil
+	tx.db.txMu.Unlock()
+}
+
+func stagedKey(collection, id string) string {
+	return collection + "/" + id
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// RoleService is the role of the requests authenticated by an API key, it is never given to a User
+const RoleService = "service"
+
+// Scopes an APIKey can hold, each one opens a group of routes to the key
+const (
+	ScopeReportsRead    = "reports:read"
+	ScopeQuestionsRead  = "questions:read"
+	ScopeQuestionsWrite = "questions:write"
+)
+
+// ValidScope reports whether scope is one of the known scopes
+func ValidScope(scope string) bool {
+	switch scope {
+	case ScopeReportsRead, ScopeQuestionsRead, ScopeQuestionsWrite:
+		return true
+	}
+	return false
+}
+
+// APIKey is a long-lived credential of a service account.
+// Only the hash of its secret is stored, the key itself is shown once when it is created.
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	SecretHash string     `json:"secret_hash"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Implement the Identifiable interface
+func (k *APIKey) GetID() string {
+	return k.ID
+}
+
+// HasScope reports whether the key holds one of scopes
+func (k *APIKey) HasScope(scopes ...string) bool {
+	for _, scope := range scopes {
+		if slices.Contains(k.Scopes, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired reports whether the key has an expiry that has passed
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && now.After(*k.ExpiresAt)
+}
+
+// GetDeletedAt implements soft delete, a deleted APIKey is revoked
+func (k *APIKey) GetDeletedAt() *time.Time {
+	return k.DeletedAt
+}
+
+// SetDeletedAt revokes the APIKey, nil restores it
+func (k *APIKey) SetDeletedAt(deletedAt *time.Time) {
+	k.DeletedAt = deletedAt
+}
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// Actions recorded in the audit log, named "<subject>.<verb>"
+const (
+	AuditRegister        = "auth.register"
+	AuditLogin           = "auth.login"
+	AuditLoginFailed     = "auth.login_failed"
+	AuditLogout          = "auth.logout"
+	AuditAuthFailed      = "auth.denied"
+	AuditPasswordChanged = "auth.password_changed"
+	AuditSessionRevoked  = "auth.session_revoked"
+
+	AuditPasswordReset = "user.password_reset"
+	AuditRoleChanged   = "user.role_changed"
+	AuditUserDeleted   = "user.deleted"
+	AuditUserRestored  = "user.restored"
+	AuditFlowReset     = "flow.reset"
+	AuditFlowRestored  = "flow.restored"
+	AuditTokensRevoked = "token.revoked"
+	AuditAPIKeyCreated = "api_key.created"
+	AuditAPIKeyRevoked = "api_key.revoked"
+
+	AuditQuestionCreated  = "question.created"
+	AuditQuestionUpdated  = "question.updated"
+	AuditQuestionDeleted  = "question.deleted"
+	AuditQuestionRestored = "question.restored"
+	AuditTypeQuizCreated  = "type_quiz.created"
+	AuditTypeQuizUpdated  = "type_quiz.updated"
+	AuditTypeQuizDeleted  = "type_quiz.deleted"
+	AuditTypeQuizRestored = "type_quiz.restored"
+	AuditBankReloaded     = "question_bank.reloaded"
+)
+
+// Outcomes of an audited action
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEvent records who did what and from where, it is never changed once stored.
+// Actor is the username of the request, or the username tried for a failed login.
+type AuditEvent struct {
+	ID        string    `json:"id"`
+	Actor     string    `json:"actor,omitempty"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target,omitempty"`
+	Outcome   string    `json:"outcome"`
+	Detail    string    `json:"detail,omitempty"`
+	ClientIP  string    `json:"client_ip"`
+	RequestID string    `json:"request_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Implement the Identifiable interface
+func (e *AuditEvent) GetID() string {
+	return e.ID
+}
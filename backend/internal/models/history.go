@@ -8,9 +8,22 @@ type History struct {
 	QuestionID     string    `json:"question_id"`
 	Answer         string    `json:"answer"`
 	ExpectedAnswer string    `json:"expected_answer"`
+	Score          *float32  `json:"score,omitempty"`
 	CreatedAt      time.Time `json:"created_at"`
 }
 
+// Credit is the share of the answer that was right, from 0 to 1. Answers stored
+// before questions had kinds have no Score, they are right when they match exactly.
+func (h *History) Credit() float32 {
+	if h.Score != nil {
+		return *h.Score
+	}
+	if h.Answer == h.ExpectedAnswer {
+		return 1
+	}
+	return 0
+}
+
 // Implement the Identifiable interface
 func (h *History) GetID() string {
 	return h.ID
@@ -0,0 +1,27 @@
+package models
+
+// QuestionPool makes a quiz type draw the questions of each flow from the bank
+// instead of serving a fixed list. The drawn questions are frozen into the flow.
+type QuestionPool struct {
+	// Tags restricts the pool to the questions having one of them, when the quiz type lists no questions
+	Tags []string `json:"tags,omitempty"`
+	// Draw is how many questions each flow gets
+	Draw int `json:"draw"`
+	// Strata are drawn first, the rest of the draw comes from the whole pool
+	Strata []PoolStratum `json:"strata,omitempty"`
+}
+
+// PoolStratum draws Count questions of the pool with Tag and Difficulty, an empty field matches any question
+type PoolStratum struct {
+	Tag        string `json:"tag,omitempty"`
+	Difficulty string `json:"difficulty,omitempty"`
+	Count      int    `json:"count"`
+}
+
+// Matches reports whether question belongs to the stratum
+func (s PoolStratum) Matches(question *Question) bool {
+	if s.Difficulty != "" && s.Difficulty != question.Difficulty {
+		return false
+	}
+	return s.Tag == "" || question.HasTag(s.Tag)
+}
@@ -1,13 +1,78 @@
 package models
 
+import "time"
+
+// Kinds of Question, each one is graded by its own grader
+const (
+	// KindChoice has a single right option, Answer is its letter or the whole option
+	KindChoice = "choice"
+	// KindTrueFalse has no options, Answer is "true" or "false"
+	KindTrueFalse = "true_false"
+	// KindMultiSelect has several right options, Answer lists their letters such as "A,C"
+	KindMultiSelect = "multi_select"
+	// KindNumeric has no options, Answer is a number matched within Tolerance
+	KindNumeric = "numeric"
+	// KindText has no options, Answer and the Accepted synonyms are matched on normalized text
+	KindText = "text"
+)
+
+// Question is asked in the flows of the quiz types listing it. A Question without
+// Kind is a KindChoice, as every question was before kinds existed.
 type Question struct {
 	ID      string   `json:"id"` // new field for unique ID
+	Kind    string   `json:"kind,omitempty"`
 	Prompt  string   `json:"prompt"`
 	Options []string `json:"options"`
 	Answer  string   `json:"answer"`
+	// PartialCredit, Tolerance and Accepted tune the graders of KindMultiSelect, KindNumeric and KindText
+	PartialCredit bool     `json:"partial_credit,omitempty"`
+	Tolerance     float64  `json:"tolerance,omitempty"`
+	Accepted      []string `json:"accepted,omitempty"`
+	// Tags and Difficulty are used by quiz types drawing their questions from a pool
+	Tags       []string `json:"tags,omitempty"`
+	Difficulty string   `json:"difficulty,omitempty"`
+
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// GetKind returns the kind of the question, KindChoice when it is not set
+func (q *Question) GetKind() string {
+	if q.Kind == "" {
+		return KindChoice
+	}
+	return q.Kind
+}
+
+// HasOptions reports whether the answer is picked among the options
+func (q *Question) HasOptions() bool {
+	switch q.GetKind() {
+	case KindChoice, KindMultiSelect:
+		return true
+	}
+	return false
 }
 
 // Implement the Identifiable interface
 func (q *Question) GetID() string {
 	return q.ID
 }
+
+// HasTag reports whether the question is tagged with tag
+func (q *Question) HasTag(tag string) bool {
+	for _, t := range q.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDeletedAt implements soft delete, a nil time means the Question is live
+func (q *Question) GetDeletedAt() *time.Time {
+	return q.DeletedAt
+}
+
+// SetDeletedAt tombstones the Question, nil restores it
+func (q *Question) SetDeletedAt(deletedAt *time.Time) {
+	q.DeletedAt = deletedAt
+}
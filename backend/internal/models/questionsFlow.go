@@ -1,21 +1,61 @@
 package models
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/matheuspolitano/quiz-go/backend/internal/utils"
 )
 
+// QuestionFlow is one attempt of a user at a quiz type, attempts are numbered from 1.
+// A non zero Seed shuffles the questions and their options, QuestionsID holds the
+// questions drawn for the flow when its quiz type has a pool.
 type QuestionFlow struct {
-	UserID       string    `json:"user_id"`
-	TypeQuizName string    `json:"type_quiz"`
-	History      []string  `json:"history"`
-	CreatedAt    time.Time `json:"created_at"`
-	ClosedAt     time.Time `json:"closed_at,omitempty"`
-	AccuracyRate float32   `json:"accuracy_rate"`
+	UserID       string     `json:"user_id"`
+	TypeQuizName string     `json:"type_quiz"`
+	Attempt      int        `json:"attempt"`
+	Seed         uint64     `json:"seed,omitempty"`
+	QuestionsID  []string   `json:"questions_id,omitempty"`
+	History      []string   `json:"history"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ClosedAt     time.Time  `json:"closed_at,omitempty"`
+	AccuracyRate float32    `json:"accuracy_rate"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+}
+
+// FlowID is the ID of the attempt of a user at a quiz type
+func FlowID(userID, typeQuizName string, attempt int) string {
+	return utils.CombineIDs(utils.CombineIDs(userID, typeQuizName), strconv.Itoa(attempt))
 }
 
 // the Identifiable interface
 func (q *QuestionFlow) GetID() string {
-	return utils.CombineIDs(q.UserID, q.TypeQuizName)
+	if q.Attempt == 0 {
+		// flows stored before attempts were numbered, they are renamed to attempt 1 at startup
+		return utils.CombineIDs(q.UserID, q.TypeQuizName)
+	}
+	return FlowID(q.UserID, q.TypeQuizName, q.Attempt)
+}
+
+// Questions returns the questions of the flow, those drawn for it or else those of its quiz type
+func (q *QuestionFlow) Questions(typeQuiz *TypeQuiz) []string {
+	if q.QuestionsID != nil {
+		return q.QuestionsID
+	}
+	return typeQuiz.QuestionsID
+}
+
+// IsClosed reports whether every question of the attempt was answered
+func (q *QuestionFlow) IsClosed() bool {
+	return !q.ClosedAt.IsZero()
+}
+
+// GetDeletedAt implements soft delete, a nil time means the QuestionFlow is live
+func (q *QuestionFlow) GetDeletedAt() *time.Time {
+	return q.DeletedAt
+}
+
+// SetDeletedAt tombstones the QuestionFlow, nil restores it
+func (q *QuestionFlow) SetDeletedAt(deletedAt *time.Time) {
+	q.DeletedAt = deletedAt
 }
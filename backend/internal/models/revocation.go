@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// RevocationUserPrefix prefixes the ID of the revocations covering every token of a user,
+// RevocationTokenPrefix the ID of those covering a single token, so the two never share an ID
+const (
+	RevocationUserPrefix  = "user:"
+	RevocationTokenPrefix = "token:"
+)
+
+// Revocation invalidates a token before it expires.
+// It covers either the token whose Payload.ID is ID, or, when IssuedBefore is set,
+// every token of Username issued before that time.
+// ExpiresAt is when the covered tokens have all expired, the entry is useless afterwards.
+type Revocation struct {
+	ID           string     `json:"id"`
+	Username     string     `json:"username,omitempty"`
+	IssuedBefore *time.Time `json:"issued_before,omitempty"`
+	Reason       string     `json:"reason,omitempty"`
+	RevokedBy    string     `json:"revoked_by"`
+	RevokedAt    time.Time  `json:"revoked_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+}
+
+// Implement the Identifiable interface
+func (r *Revocation) GetID() string {
+	return r.ID
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Session is a login, it lives as long as its refresh token.
+// ID is the Payload.ID of the refresh token, so the token finds its session.
+type Session struct {
+	ID         string    `json:"id"`
+	Username   string    `json:"username"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	ClientIP   string    `json:"client_ip,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// Implement the Identifiable interface
+func (s *Session) GetID() string {
+	return s.ID
+}
+
+// Expired reports whether the refresh token of the session has expired
+func (s *Session) Expired(now time.Time) bool {
+	return now.After(s.ExpiresAt)
+}
@@ -0,0 +1,152 @@
+package models
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// A flow with a Seed serves the questions of its quiz type in its own order and
+// shows the options of each question in its own order, relabelled from A.
+// Both only depend on the seed and the question IDs, so they stay the same
+// for the whole flow, even when the quiz type gains or loses questions.
+
+// QuestionOrder returns ids in the order the flow serves them, unchanged without a seed
+func (q *QuestionFlow) QuestionOrder(ids []string) []string {
+	ordered := append([]string(nil), ids...)
+	if q.Seed == 0 {
+		return ordered
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return q.hash(ordered[i]) < q.hash(ordered[j])
+	})
+	return ordered
+}
+
+// Present returns the question as the flow shows it, with its options shuffled and relabelled
+func (q *QuestionFlow) Present(question *Question) *Question {
+	perm := q.optionOrder(question)
+	if perm == nil {
+		return question
+	}
+	shown := *question
+	shown.Options = make([]string, len(perm))
+	for i, canonical := range perm {
+		shown.Options[i] = shownOption(i, question.Options[canonical])
+	}
+	shown.Answer = q.ShownAnswer(question, question.Answer)
+	return &shown
+}
+
+// CanonicalAnswer maps an answer given on the shown options back to the stored options,
+// a letter gives the letter of the stored option and a whole option the stored option.
+// The letters of a multi-select answer are mapped one by one.
+func (q *QuestionFlow) CanonicalAnswer(question *Question, answer string) string {
+	perm := q.optionOrder(question)
+	if perm == nil {
+		return answer
+	}
+	return mapOptions(question, answer, func(answer string) string {
+		for i, canonical := range perm {
+			switch {
+			case strings.EqualFold(strings.TrimSpace(answer), optionLabel(i, "")):
+				// an unlabelled option is only known by its text to the grader
+				if letter, ok := optionLetter(question.Options[canonical]); ok {
+					return letter
+				}
+				return question.Options[canonical]
+			case answer == shownOption(i, question.Options[canonical]):
+				return question.Options[canonical]
+			}
+		}
+		return answer
+	})
+}
+
+// ShownAnswer maps an answer written on the stored options to the options shown by the flow,
+// it is the reverse of CanonicalAnswer.
+func (q *QuestionFlow) ShownAnswer(question *Question, answer string) string {
+	perm := q.optionOrder(question)
+	if perm == nil {
+		return answer
+	}
+	return mapOptions(question, answer, func(answer string) string {
+		for i, canonical := range perm {
+			switch {
+			case strings.EqualFold(strings.TrimSpace(answer), optionLabel(canonical, question.Options[canonical])):
+				return optionLabel(i, "")
+			case answer == question.Options[canonical]:
+				return shownOption(i, question.Options[canonical])
+			}
+		}
+		return answer
+	})
+}
+
+// mapOptions applies mapping to the answer, or to each of its options for a multi-select
+// question, whose mapped options are sorted so the answer reads the same however it was written.
+func mapOptions(question *Question, answer string, mapping func(string) string) string {
+	if question.GetKind() != KindMultiSelect {
+		return mapping(answer)
+	}
+	parts := make([]string, 0)
+	for _, part := range strings.Split(answer, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, strings.ToUpper(mapping(part)))
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// optionOrder returns the stored index of each shown option, nil when the options are not shuffled
+// or the question kind has no options.
+func (q *QuestionFlow) optionOrder(question *Question) []int {
+	if q.Seed == 0 || !question.HasOptions() {
+		return nil
+	}
+	rng := rand.New(rand.NewPCG(q.Seed, q.hash(question.ID)))
+	return rng.Perm(len(question.Options))
+}
+
+func (q *QuestionFlow) hash(id string) uint64 {
+	h := fnv.New64a()
+	var seed [8]byte
+	binary.LittleEndian.PutUint64(seed[:], q.Seed)
+	h.Write(seed[:])
+	h.Write([]byte(id))
+	return h.Sum64()
+}
+
+// optionLetter returns the letter of an option written "A: Rome". Only a single letter
+// before the colon is a label, "Ratio 1:2" is an unlabelled option holding a colon.
+func optionLetter(option string) (string, bool) {
+	label, _, ok := strings.Cut(option, ":")
+	label = strings.TrimSpace(label)
+	if !ok || len(label) != 1 || !unicode.IsLetter(rune(label[0])) {
+		return "", false
+	}
+	return label, true
+}
+
+// optionLabel returns the letter of an option written "A: Rome", or the letter of its position
+func optionLabel(position int, option string) string {
+	if letter, ok := optionLetter(option); ok {
+		return letter
+	}
+	return string(rune('A' + position))
+}
+
+// shownOption relabels option with the letter of the position it is shown at
+func shownOption(position int, option string) string {
+	text := option
+	if _, ok := optionLetter(option); ok {
+		_, rest, _ := strings.Cut(option, ":")
+		text = strings.TrimSpace(rest)
+	}
+	return fmt.Sprintf("%s: %s", optionLabel(position, ""), text)
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// shuffledFlow returns a flow whose seed moves the first option of question
+func shuffledFlow(t *testing.T, question *Question) *QuestionFlow {
+	t.Helper()
+	for seed := uint64(1); seed < 100; seed++ {
+		flow := &QuestionFlow{Seed: seed}
+		if flow.optionOrder(question)[0] != 0 {
+			return flow
+		}
+	}
+	t.Fatal("no seed shuffles the options")
+	return nil
+}
+
+func TestPresentUnlabelledOptions(t *testing.T) {
+	question := &Question{ID: "ratio", Options: []string{"Ratio 1:2", "Ratio 2:1", "Ratio 1:1"}, Answer: "Ratio 1:2"}
+	flow := shuffledFlow(t, question)
+	shown := flow.Present(question)
+
+	for i, option := range shown.Options {
+		letter := string(rune('A' + i))
+		label, text, _ := strings.Cut(option, ": ")
+		if label != letter || !slices.Contains(question.Options, text) {
+			t.Errorf("shown option %d is %q, want a stored option labelled %s", i, option, letter)
+		}
+		// the letter maps back to the option text, the grader has no letter for it
+		if got := flow.CanonicalAnswer(question, letter); got != text {
+			t.Errorf("CanonicalAnswer(%q) = %q, want %q", letter, got, text)
+		}
+		if got := flow.CanonicalAnswer(question, option); got != text {
+			t.Errorf("CanonicalAnswer(%q) = %q, want %q", option, got, text)
+		}
+	}
+	if want := string(rune('A'+slices.Index(flow.optionOrder(question), 0))) + ": Ratio 1:2"; shown.Answer != want {
+		t.Errorf("shown answer %q, want %q", shown.Answer, want)
+	}
+}
+
+func TestPresentLabelledOptions(t *testing.T) {
+	question := &Question{ID: "capital", Options: []string{"A: Rome", "B: Milan", "C: Venice"}, Answer: "A"}
+	flow := shuffledFlow(t, question)
+	shown := flow.Present(question)
+
+	perm := flow.optionOrder(question)
+	for i, option := range shown.Options {
+		letter := string(rune('A' + i))
+		stored := question.Options[perm[i]]
+		_, text, _ := strings.Cut(stored, ": ")
+		if want := letter + ": " + text; option != want {
+			t.Errorf("shown option %d is %q, want %q", i, option, want)
+		}
+		if got, want := flow.CanonicalAnswer(question, strings.ToLower(letter)), stored[:1]; got != want {
+			t.Errorf("CanonicalAnswer(%q) = %q, want %q", letter, got, want)
+		}
+		if got := flow.CanonicalAnswer(question, option); got != stored {
+			t.Errorf("CanonicalAnswer(%q) = %q, want %q", option, got, stored)
+		}
+	}
+	if want := string(rune('A' + slices.Index(perm, 0))); shown.Answer != want {
+		t.Errorf("shown answer %q, want %q", shown.Answer, want)
+	}
+}
+
+func TestPresentMultiSelect(t *testing.T) {
+	question := &Question{ID: "primes", Kind: KindMultiSelect, Options: []string{"A: 2", "B: 3", "C: 4", "D: 5"}, Answer: "A,B,D"}
+	flow := shuffledFlow(t, question)
+	shown := flow.Present(question)
+
+	if got := flow.CanonicalAnswer(question, shown.Answer); got != question.Answer {
+		t.Errorf("CanonicalAnswer(%q) = %q, want %q", shown.Answer, got, question.Answer)
+	}
+}
+
+func TestPresentWithoutSeed(t *testing.T) {
+	question := &Question{ID: "ratio", Options: []string{"Ratio 1:2", "Ratio 2:1"}, Answer: "Ratio 1:2"}
+	flow := &QuestionFlow{}
+	if shown := flow.Present(question); shown != question {
+		t.Errorf("a flow without a seed changed the question: %+v", shown)
+	}
+	if got := flow.CanonicalAnswer(question, "B"); got != "B" {
+		t.Errorf("CanonicalAnswer without a seed = %q, want the answer unchanged", got)
+	}
+}
@@ -1,11 +1,31 @@
 package models
 
+import "time"
+
+// TypeQuiz is a quiz users take in attempts. MaxAttempts caps the attempts of each
+// user, 0 allows any number. Shuffle gives every new flow its own order of questions
+// and options. Pool draws the questions of each flow, from QuestionsID or from the
+// whole bank when QuestionsID is empty.
 type TypeQuiz struct {
-	Name        string   `json:"name"`
-	QuestionsID []string `json:"questions_id"`
+	Name        string        `json:"name"`
+	QuestionsID []string      `json:"questions_id"`
+	MaxAttempts int           `json:"max_attempts,omitempty"`
+	Shuffle     bool          `json:"shuffle,omitempty"`
+	Pool        *QuestionPool `json:"pool,omitempty"`
+	DeletedAt   *time.Time    `json:"deleted_at,omitempty"`
 }
 
 // Implement the Identifiable interface
 func (u *TypeQuiz) GetID() string {
 	return u.Name
 }
+
+// GetDeletedAt implements soft delete, a nil time means the TypeQuiz is live
+func (u *TypeQuiz) GetDeletedAt() *time.Time {
+	return u.DeletedAt
+}
+
+// SetDeletedAt tombstones the TypeQuiz, nil restores it
+func (u *TypeQuiz) SetDeletedAt(deletedAt *time.Time) {
+	u.DeletedAt = deletedAt
+}
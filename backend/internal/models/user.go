@@ -1,16 +1,64 @@
 package models
 
 import (
+	"strings"
 	"time"
 )
 
+// Roles a User can hold, each one is carried by the tokens issued to the User
+const (
+	RolePlayer     = "player"
+	RoleAuthor     = "author"
+	RoleInstructor = "instructor"
+	RoleAdmin      = "admin"
+)
+
+// ValidRole reports whether role is one of the known roles
+func ValidRole(role string) bool {
+	switch role {
+	case RolePlayer, RoleAuthor, RoleInstructor, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+// ValidUsername reports whether username can name a User. A colon is refused: it separates
+// the user from the quiz type in the IDs of flows and marks the API keys, "apikey:<name>",
+// in token payloads, so a username holding one could pass for another identity.
+func ValidUsername(username string) bool {
+	return username != "" && !strings.Contains(username, ":")
+}
+
 type User struct {
-	Username         string    `json:"username"`
-	CreatedAt        time.Time `json:"created_at"`
-	QuestionsFlowsID []string  `json:"questions_flows_id"`
+	Username     string `json:"username"`
+	Role         string `json:"role,omitempty"`
+	PasswordHash string `json:"password_hash,omitempty"`
+	// OIDCSubject links the User to an OpenID Connect identity, "issuer|sub"
+	OIDCSubject      string     `json:"oidc_subject,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	QuestionsFlowsID []string   `json:"questions_flows_id"`
+	DeletedAt        *time.Time `json:"deleted_at,omitempty"`
 }
 
 // Implement the Identifiable interface
 func (u *User) GetID() string {
 	return u.Username
 }
+
+// GetRole returns the role of the User, users stored before roles existed are players
+func (u *User) GetRole() string {
+	if u.Role == "" {
+		return RolePlayer
+	}
+	return u.Role
+}
+
+// GetDeletedAt implements soft delete, a nil time means the User is live
+func (u *User) GetDeletedAt() *time.Time {
+	return u.DeletedAt
+}
+
+// SetDeletedAt tombstones the User, nil restores it
+func (u *User) SetDeletedAt(deletedAt *time.Time) {
+	u.DeletedAt = deletedAt
+}
@@ -0,0 +1,376 @@
+// Package mockidp is an OpenID provider for development and tests.
+// It approves any username typed in its login page, with no password,
+// and supports the authorization code flow with PKCE and the device flow.
+// Never expose it in production.
+package mockidp
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/matheuspolitano/quiz-go/backend/internal/oidc"
+	"github.com/matheuspolitano/quiz-go/backend/internal/token"
+)
+
+const (
+	keyID            = "mock"
+	codeLifetime     = time.Minute
+	deviceLifetime   = 10 * time.Minute
+	idTokenLifetime  = 5 * time.Minute
+	devicePollPeriod = 1
+	// EmailDomain is the domain of the email claim, a user "alice" is alice@quiz.example
+	EmailDomain = "quiz.example"
+)
+
+type authCode struct {
+	clientID      string
+	redirectURI   string
+	codeChallenge string
+	nonce         string
+	username      string
+	expiresAt     time.Time
+}
+
+type deviceGrant struct {
+	clientID  string
+	userCode  string
+	username  string
+	denied    bool
+	expiresAt time.Time
+}
+
+// Server is the mock provider, an http.Handler serving the endpoints below the path of its issuer.
+type Server struct {
+	issuer   string
+	clientID string
+	key      *rsa.PrivateKey
+	mux      *http.ServeMux
+
+	mu      sync.Mutex
+	codes   map[string]*authCode
+	devices map[string]*deviceGrant // by device code
+}
+
+// New creates a provider for issuer accepting a single public client, clientID
+func New(issuer, clientID string) (*Server, error) {
+	issuerURL, err := url.Parse(issuer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid issuer: %w", err)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		issuer:   strings.TrimSuffix(issuer, "/"),
+		clientID: clientID,
+		key:      key,
+		mux:      http.NewServeMux(),
+		codes:    make(map[string]*authCode),
+		devices:  make(map[string]*deviceGrant),
+	}
+	prefix := strings.TrimSuffix(issuerURL.Path, "/")
+	s.mux.HandleFunc(prefix+"/.well-known/openid-configuration", s.handleDiscovery)
+	s.mux.HandleFunc(prefix+"/jwks", s.handleJWKS)
+	s.mux.HandleFunc(prefix+"/authorize", s.handleAuthorize)
+	s.mux.HandleFunc(prefix+"/token", s.handleToken)
+	s.mux.HandleFunc(prefix+"/device_authorization", s.handleDeviceAuthorization)
+	s.mux.HandleFunc(prefix+"/device", s.handleDevice)
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Subject is the sub claim issued for username
+func Subject(username string) string {
+	return "mock|" + username
+}
+
+func (s *Server) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                s.issuer,
+		"authorization_endpoint":                s.issuer + "/authorize",
+		"token_endpoint":                        s.issuer + "/token",
+		"device_authorization_endpoint":         s.issuer + "/device_authorization",
+		"jwks_uri":                              s.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"grant_types_supported":                 []string{"authorization_code", "urn:ietf:params:oauth:grant-type:device_code"},
+	})
+}
+
+func (s *Server) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	public := s.key.PublicKey
+	writeJSON(w, http.StatusOK, token.JWKSet{Keys: []token.JWK{{
+		KeyType:   "RSA",
+		KeyID:     keyID,
+		Use:       "sig",
+		Algorithm: "RS256",
+		N:         base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+		E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+	}}})
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!doctype html>
+<title>Mock IdP</title>
+<h1>Mock identity provider</h1>
+<p>Any username signs in, no password is asked.</p>
+<form method="post">
+{{range $name, $value := .Hidden}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{end}}{{if .UserCode}}<label>Code <input name="user_code" value="{{.UserCode}}"></label><br>
+{{end}}<label>Username <input name="login_hint" autofocus></label>
+<button type="submit">Sign in</button>
+</form>
+{{if .Message}}<p>{{.Message}}</p>{{end}}`))
+
+type loginPageData struct {
+	Hidden   map[string]string
+	UserCode string
+	Message  string
+}
+
+// handleAuthorize approves the login_hint user right away, without it a login page asks for a username
+func (s *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params := map[string]string{}
+	for _, name := range []string{"response_type", "client_id", "redirect_uri", "scope", "state", "nonce", "code_challenge", "code_challenge_method"} {
+		params[name] = r.Form.Get(name)
+	}
+	if params["client_id"] != s.clientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+	redirectURI, err := url.Parse(params["redirect_uri"])
+	if err != nil || params["redirect_uri"] == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if params["response_type"] != "code" || params["code_challenge_method"] != "S256" || params["code_challenge"] == "" {
+		http.Error(w, "only response_type=code with a S256 code_challenge is supported", http.StatusBadRequest)
+		return
+	}
+
+	username := strings.TrimSpace(r.Form.Get("login_hint"))
+	if username == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = loginPage.Execute(w, loginPageData{Hidden: params})
+		return
+	}
+
+	code, err := oidc.RandomToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.mu.Lock()
+	s.codes[code] = &authCode{
+		clientID:      params["client_id"],
+		redirectURI:   params["redirect_uri"],
+		codeChallenge: params["code_challenge"],
+		nonce:         params["nonce"],
+		username:      username,
+		expiresAt:     time.Now().Add(codeLifetime),
+	}
+	s.mu.Unlock()
+
+	query := redirectURI.Query()
+	query.Set("code", code)
+	query.Set("state", params["state"])
+	redirectURI.RawQuery = query.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("client_id") != s.clientID {
+		tokenError(w, "invalid_client")
+		return
+	}
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		s.redeemCode(w, r.PostForm)
+	case "urn:ietf:params:oauth:grant-type:device_code":
+		s.redeemDeviceCode(w, r.PostForm)
+	default:
+		tokenError(w, "unsupported_grant_type")
+	}
+}
+
+func (s *Server) redeemCode(w http.ResponseWriter, form url.Values) {
+	s.mu.Lock()
+	code, ok := s.codes[form.Get("code")]
+	delete(s.codes, form.Get("code")) // codes are single use
+	s.mu.Unlock()
+
+	if !ok || time.Now().After(code.expiresAt) || code.redirectURI != form.Get("redirect_uri") {
+		tokenError(w, "invalid_grant")
+		return
+	}
+	if oidc.CodeChallenge(form.Get("code_verifier")) != code.codeChallenge {
+		tokenError(w, "invalid_grant")
+		return
+	}
+	s.issueTokens(w, code.username, code.nonce)
+}
+
+func (s *Server) redeemDeviceCode(w http.ResponseWriter, form url.Values) {
+	s.mu.Lock()
+	grant, ok := s.devices[form.Get("device_code")]
+	s.mu.Unlock()
+
+	switch {
+	case !ok:
+		tokenError(w, "invalid_grant")
+	case time.Now().After(grant.expiresAt):
+		tokenError(w, oidc.ErrDeviceCodeExpired.Error())
+	case grant.denied:
+		tokenError(w, oidc.ErrAccessDenied.Error())
+	case grant.username == "":
+		tokenError(w, oidc.ErrAuthorizationPending.Error())
+	default:
+		s.mu.Lock()
+		delete(s.devices, form.Get("device_code"))
+		s.mu.Unlock()
+		s.issueTokens(w, grant.username, "")
+	}
+}
+
+func (s *Server) issueTokens(w http.ResponseWriter, username, nonce string) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":                s.issuer,
+		"sub":                Subject(username),
+		"aud":                s.clientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(idTokenLifetime).Unix(),
+		"email":              username + "@" + EmailDomain,
+		"email_verified":     true,
+		"preferred_username": username,
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = keyID
+	signed, err := idToken.SignedString(s.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	accessToken, err := oidc.RandomToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(idTokenLifetime.Seconds()),
+		"id_token":     signed,
+	})
+}
+
+func (s *Server) handleDeviceAuthorization(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("client_id") != s.clientID {
+		tokenError(w, "invalid_client")
+		return
+	}
+	deviceCode, err := oidc.RandomToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	userCode, err := newUserCode()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.mu.Lock()
+	s.devices[deviceCode] = &deviceGrant{clientID: s.clientID, userCode: userCode, expiresAt: time.Now().Add(deviceLifetime)}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, oidc.DeviceAuthorization{
+		DeviceCode:              deviceCode,
+		UserCode:                userCode,
+		VerificationURI:         s.issuer + "/device",
+		VerificationURIComplete: s.issuer + "/device?user_code=" + url.QueryEscape(userCode),
+		ExpiresIn:               int(deviceLifetime.Seconds()),
+		Interval:                devicePollPeriod,
+	})
+}
+
+// handleDevice is the verification page of the device flow, the user enters the code shown by the CLI
+func (s *Server) handleDevice(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	userCode := strings.ToUpper(strings.TrimSpace(r.Form.Get("user_code")))
+	username := strings.TrimSpace(r.Form.Get("login_hint"))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method != http.MethodPost || username == "" {
+		_ = loginPage.Execute(w, loginPageData{UserCode: userCode})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, grant := range s.devices {
+		if grant.userCode == userCode && grant.username == "" && time.Now().Before(grant.expiresAt) {
+			grant.username = username
+			_ = loginPage.Execute(w, loginPageData{Message: "Signed in as " + username + ", you can return to your terminal."})
+			return
+		}
+	}
+	w.WriteHeader(http.StatusBadRequest)
+	_ = loginPage.Execute(w, loginPageData{UserCode: userCode, Message: "Unknown or expired code."})
+}
+
+// newUserCode returns a code such as WDJB-MJHT, without vowels or ambiguous letters
+func newUserCode() (string, error) {
+	const alphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = alphabet[int(b[i])%len(alphabet)]
+	}
+	return string(b[:4]) + "-" + string(b[4:]), nil
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,408 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/matheuspolitano/quiz-go/backend/internal/token"
+)
+
+// Errors of the device flow, named after their OAuth error codes (RFC 8628)
+var (
+	ErrAuthorizationPending = errors.New("authorization_pending")
+	ErrSlowDown             = errors.New("slow_down")
+	ErrAccessDenied         = errors.New("access_denied")
+	ErrDeviceCodeExpired    = errors.New("expired_token")
+)
+
+var ErrInvalidIDToken = errors.New("invalid ID token")
+
+// clockSkew is tolerated on the expiry of the ID tokens
+const clockSkew = time.Minute
+
+// jwksRefreshInterval limits how often an unknown kid refetches the keys of the issuer
+const jwksRefreshInterval = time.Minute
+
+// Config configures the relying party of an OpenID provider.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	HTTPClient   *http.Client
+}
+
+// Provider is an OpenID Connect relying party: it builds the authorization URL,
+// redeems codes and device codes, and verifies the ID tokens with the keys of the issuer.
+// The discovery document is fetched on first use, so the issuer may be served by this process.
+type Provider struct {
+	config Config
+
+	mu            sync.Mutex
+	discovery     *discovery
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+type discovery struct {
+	Issuer                      string `json:"issuer"`
+	AuthorizationEndpoint       string `json:"authorization_endpoint"`
+	TokenEndpoint               string `json:"token_endpoint"`
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+	JWKSURI                     string `json:"jwks_uri"`
+}
+
+// Claims are the claims of a verified ID token used to find the user.
+type Claims struct {
+	Issuer            string `json:"iss"`
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	Nonce             string `json:"nonce"`
+}
+
+// idTokenClaims validates the registered claims, aud may be a string or a list
+type idTokenClaims struct {
+	Claims
+	Audience  audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	IssuedAt  int64    `json:"iat"`
+}
+
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// Valid is called by jwt.Parse, the issuer and the audience are checked by VerifyIDToken
+func (c *idTokenClaims) Valid() error {
+	if time.Now().After(time.Unix(c.ExpiresAt, 0).Add(clockSkew)) {
+		return errors.New("ID token has expired")
+	}
+	return nil
+}
+
+// DeviceAuthorization is the answer of the device authorization endpoint (RFC 8628).
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// NewProvider creates a relying party, the openid scope is always requested
+func NewProvider(config Config) *Provider {
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if !slices.Contains(config.Scopes, "openid") {
+		config.Scopes = append([]string{"openid"}, config.Scopes...)
+	}
+	return &Provider{config: config}
+}
+
+// AuthCodeURL returns the URL sending the user to the provider, with a S256 PKCE challenge.
+// loginHint is optional, it prefills the username at the provider.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge, loginHint string) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	if loginHint != "" {
+		query.Set("login_hint", loginHint)
+	}
+	return d.AuthorizationEndpoint + "?" + query.Encode(), nil
+}
+
+// Exchange redeems an authorization code and verifies the ID token, including its nonce
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Claims, error) {
+	claims, err := p.redeem(ctx, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {codeVerifier},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce does not match", ErrInvalidIDToken)
+	}
+	return claims, nil
+}
+
+// DeviceAuthorization starts a device flow
+func (p *Provider) DeviceAuthorization(ctx context.Context) (*DeviceAuthorization, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if d.DeviceAuthorizationEndpoint == "" {
+		return nil, errors.New("the provider does not support the device flow")
+	}
+	resp, err := p.postForm(ctx, d.DeviceAuthorizationEndpoint, url.Values{"scope": {strings.Join(p.config.Scopes, " ")}})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("device authorization failed with status %d", resp.StatusCode)
+	}
+	var auth DeviceAuthorization
+	if err := json.NewDecoder(resp.Body).Decode(&auth); err != nil {
+		return nil, fmt.Errorf("decoding device authorization: %w", err)
+	}
+	return &auth, nil
+}
+
+// PollDeviceToken redeems a device code once, it returns ErrAuthorizationPending
+// or ErrSlowDown until the user approves the login
+func (p *Provider) PollDeviceToken(ctx context.Context, deviceCode string) (*Claims, error) {
+	return p.redeem(ctx, url.Values{
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		"device_code": {deviceCode},
+	})
+}
+
+// VerifyIDToken checks the signature, the issuer, the audience and the expiry of an ID token
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken string) (*Claims, error) {
+	claims := &idTokenClaims{}
+	keyFunc := func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := p.publicKey(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		// the algorithm comes from the key, never from the token header
+		switch key.(type) {
+		case *rsa.PublicKey:
+			if t.Method.Alg() != jwt.SigningMethodRS256.Alg() {
+				return nil, errors.New("unexpected signing method")
+			}
+		case ed25519.PublicKey:
+			if t.Method.Alg() != jwt.SigningMethodEdDSA.Alg() {
+				return nil, errors.New("unexpected signing method")
+			}
+		}
+		return key, nil
+	}
+	if _, err := jwt.ParseWithClaims(rawIDToken, claims, keyFunc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Issuer != d.Issuer {
+		return nil, fmt.Errorf("%w: issuer %q", ErrInvalidIDToken, claims.Issuer)
+	}
+	if !slices.Contains(claims.Audience, p.config.ClientID) {
+		return nil, fmt.Errorf("%w: not issued for this client", ErrInvalidIDToken)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	}
+	return &claims.Claims, nil
+}
+
+// redeem calls the token endpoint and verifies the ID token of the answer
+func (p *Provider) redeem(ctx context.Context, form url.Values) (*Claims, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.postForm(ctx, d.TokenEndpoint, form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var tokens tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("decoding token response: %w", err)
+	}
+	switch tokens.Error {
+	case "":
+	case ErrAuthorizationPending.Error():
+		return nil, ErrAuthorizationPending
+	case ErrSlowDown.Error():
+		return nil, ErrSlowDown
+	case ErrAccessDenied.Error():
+		return nil, ErrAccessDenied
+	case ErrDeviceCodeExpired.Error():
+		return nil, ErrDeviceCodeExpired
+	default:
+		return nil, fmt.Errorf("token endpoint: %s %s", tokens.Error, tokens.ErrorDescription)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("%w: missing from the token response", ErrInvalidIDToken)
+	}
+	return p.VerifyIDToken(ctx, tokens.IDToken)
+}
+
+// postForm authenticates the client with client_secret_post, public clients send only their ID
+func (p *Provider) postForm(ctx context.Context, endpoint string, form url.Values) (*http.Response, error) {
+	form.Set("client_id", p.config.ClientID)
+	if p.config.ClientSecret != "" {
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	return p.config.HTTPClient.Do(req)
+}
+
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var d discovery
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.config.Issuer, "/")+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, fmt.Errorf("OIDC discovery: %w", err)
+	}
+	if d.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("OIDC discovery: issuer %q does not match %q", d.Issuer, p.config.Issuer)
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// publicKey returns the key of kid, the key set is fetched again when kid is unknown
+func (p *Provider) publicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < jwksRefreshInterval && p.keys != nil {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	var set token.JWKSet
+	if err := p.getJSON(ctx, d.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("fetching the provider keys: %w", err)
+	}
+	p.keys = make(map[string]crypto.PublicKey, len(set.Keys))
+	p.keysFetchedAt = time.Now()
+	for _, jwk := range set.Keys {
+		if key, err := publicKeyOfJWK(jwk); err == nil {
+			p.keys[jwk.KeyID] = key
+		}
+	}
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+func (p *Provider) getJSON(ctx context.Context, endpoint string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func publicKeyOfJWK(jwk token.JWK) (crypto.PublicKey, error) {
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || jwk.Curve != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("unsupported OKP key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.KeyType)
+	}
+}
+
+// CodeChallenge returns the S256 PKCE challenge of a verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// RandomToken returns 32 random bytes encoded in base64url, for states, nonces and PKCE verifiers
+func RandomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidRate = errors.New(`invalid rate, use "<requests>/<period>" such as 10/1m`)
+
+// sweepInterval is how often the buckets left full by idle clients are dropped
+const sweepInterval = time.Minute
+
+// Rate allows Requests requests every Per, in bursts of up to Requests.
+type Rate struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseRate reads a rate such as "10/1m" or "300/1h", an empty string or "0" is no limit.
+// A rate with a period allows at least one request, "0/1m" is refused rather than read as no limit.
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return Rate{}, nil
+	}
+	requests, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("%w: %q", ErrInvalidRate, s)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n <= 0 {
+		return Rate{}, fmt.Errorf("%w: %q", ErrInvalidRate, s)
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Rate{}, fmt.Errorf("%w: %q", ErrInvalidRate, s)
+	}
+	return Rate{Requests: n, Per: d}, nil
+}
+
+// Unlimited reports whether the rate lets every request through
+func (r Rate) Unlimited() bool {
+	return r.Requests == 0
+}
+
+func (r Rate) String() string {
+	return fmt.Sprintf("%d/%s", r.Requests, r.Per)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a token bucket per key: each key holds up to Rate.Requests tokens,
+// a request takes one and they are refilled continuously over Rate.Per.
+type Limiter struct {
+	rate      Rate
+	perToken  time.Duration
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewLimiter creates a limiter enforcing rate on every key
+func NewLimiter(rate Rate) *Limiter {
+	limiter := &Limiter{rate: rate, buckets: make(map[string]*bucket), now: time.Now}
+	if !rate.Unlimited() {
+		limiter.perToken = rate.Per / time.Duration(rate.Requests)
+	}
+	limiter.lastSweep = limiter.now()
+	return limiter
+}
+
+// Rate returns the rate enforced by the limiter
+func (l *Limiter) Rate() Rate {
+	return l.rate
+}
+
+// Allow takes a token from the bucket of key. When the bucket is empty it
+// returns false and how long the client must wait for the next token.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l.rate.Unlimited() {
+		return true, 0
+	}
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.rate.Requests), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.rate.Requests), b.tokens+float64(now.Sub(b.last))/float64(l.perToken))
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) * float64(l.perToken))
+}
+
+// sweep drops the buckets that have refilled, they are the same as a new one
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.rate.Per {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
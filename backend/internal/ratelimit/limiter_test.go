@@ -0,0 +1,146 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock the tests move by hand
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestLimiter(rate Rate) (*Limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	limiter := NewLimiter(rate)
+	limiter.now = clock.Now
+	limiter.lastSweep = clock.Now()
+	return limiter, clock
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Rate
+		err  bool
+	}{
+		{"", Rate{}, false},
+		{"0", Rate{}, false},
+		{" 10/1m ", Rate{Requests: 10, Per: time.Minute}, false},
+		{"300/1h", Rate{Requests: 300, Per: time.Hour}, false},
+		{"0/1m", Rate{}, true},
+		{"-1/1m", Rate{}, true},
+		{"10", Rate{}, true},
+		{"10/", Rate{}, true},
+		{"10/0s", Rate{}, true},
+		{"ten/1m", Rate{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRate(tt.in)
+		if tt.err {
+			if !errors.Is(err, ErrInvalidRate) {
+				t.Errorf("ParseRate(%q): got error %v, want %v", tt.in, err, ErrInvalidRate)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseRate(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestAllowBurst(t *testing.T) {
+	limiter, _ := newTestLimiter(Rate{Requests: 3, Per: time.Minute})
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.Allow("a"); !ok {
+			t.Fatalf("request %d of the burst refused", i+1)
+		}
+	}
+	ok, wait := limiter.Allow("a")
+	if ok {
+		t.Fatal("request over the burst allowed")
+	}
+	if wait != 20*time.Second {
+		t.Errorf("retry in %v, want %v", wait, 20*time.Second)
+	}
+	// every key has its own bucket
+	if ok, _ := limiter.Allow("b"); !ok {
+		t.Error("another key was refused")
+	}
+}
+
+func TestAllowRefill(t *testing.T) {
+	limiter, clock := newTestLimiter(Rate{Requests: 2, Per: time.Minute})
+	limiter.Allow("a")
+	limiter.Allow("a")
+
+	clock.Advance(10 * time.Second)
+	ok, wait := limiter.Allow("a")
+	if ok {
+		t.Fatal("allowed before a token was refilled")
+	}
+	if wait != 20*time.Second {
+		t.Errorf("retry in %v, want %v", wait, 20*time.Second)
+	}
+
+	clock.Advance(20 * time.Second)
+	if ok, _ := limiter.Allow("a"); !ok {
+		t.Fatal("refused once a token was refilled")
+	}
+	if ok, _ := limiter.Allow("a"); ok {
+		t.Fatal("allowed a second request with one token refilled")
+	}
+
+	// an idle key refills up to the burst, not beyond
+	clock.Advance(time.Hour)
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.Allow("a"); !ok {
+			t.Fatalf("request %d refused after idling", i+1)
+		}
+	}
+	if ok, _ := limiter.Allow("a"); ok {
+		t.Error("the bucket refilled beyond the burst")
+	}
+}
+
+func TestSweep(t *testing.T) {
+	limiter, clock := newTestLimiter(Rate{Requests: 2, Per: 2 * time.Minute})
+	limiter.Allow("idle")
+	// a sweep runs here, but no bucket has refilled yet
+	clock.Advance(90 * time.Second)
+	limiter.Allow("busy")
+	if len(limiter.buckets) != 2 {
+		t.Fatalf("%d buckets, want 2", len(limiter.buckets))
+	}
+
+	// the idle bucket has been full for a while, the busy one is still refilling
+	clock.Advance(time.Minute)
+	limiter.Allow("other")
+	if _, ok := limiter.buckets["idle"]; ok {
+		t.Error("the idle bucket was not swept")
+	}
+	if _, ok := limiter.buckets["busy"]; !ok {
+		t.Error("the busy bucket was swept")
+	}
+}
+
+func TestUnlimited(t *testing.T) {
+	limiter, _ := newTestLimiter(Rate{})
+	for i := 0; i < 100; i++ {
+		if ok, _ := limiter.Allow("a"); !ok {
+			t.Fatal("unlimited rate refused a request")
+		}
+	}
+	if len(limiter.buckets) != 0 {
+		t.Error("unlimited rate kept buckets")
+	}
+}
@@ -0,0 +1,201 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const minRSAKeyBits = 2048
+
+// publicKey is a key able to verify tokens, with the algorithm it was generated for
+type publicKey struct {
+	method jwt.SigningMethod
+	key    crypto.PublicKey
+}
+
+// AsymmetricMaker is a JSON Web Token maker signing with an Ed25519 (EdDSA) or RSA (RS256) private key.
+// Other services verify its tokens with the public keys published as a JWK set.
+type AsymmetricMaker struct {
+	activeKID  string
+	method     jwt.SigningMethod
+	privateKey crypto.PrivateKey
+	publicKeys map[string]publicKey
+}
+
+// NewAsymmetricMaker creates a maker signing with privateKeys[activeKID].
+// Every private key verifies tokens, publicKeys adds verify-only keys, e.g. retired signing keys.
+// Both maps go from kid to a PEM encoded key.
+func NewAsymmetricMaker(privateKeys, publicKeys map[string][]byte, activeKID string) (Maker, error) {
+	maker := &AsymmetricMaker{activeKID: activeKID, publicKeys: make(map[string]publicKey)}
+	for kid, data := range privateKeys {
+		private, err := parsePrivateKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("private key %q: %w", kid, err)
+		}
+		public, err := publicKeyOf(private)
+		if err != nil {
+			return nil, fmt.Errorf("private key %q: %w", kid, err)
+		}
+		maker.publicKeys[kid] = public
+		if kid == activeKID {
+			maker.privateKey, maker.method = private, public.method
+		}
+	}
+	for kid, data := range publicKeys {
+		if _, exists := maker.publicKeys[kid]; exists {
+			return nil, fmt.Errorf("duplicated kid %q", kid)
+		}
+		public, err := parsePublicKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("public key %q: %w", kid, err)
+		}
+		maker.publicKeys[kid] = public
+	}
+	if maker.privateKey == nil {
+		return nil, fmt.Errorf("active kid %q is not one of the private keys", activeKID)
+	}
+	return maker, nil
+}
+
+// ReadKeyFiles reads every file of a kid to path map.
+func ReadKeyFiles(paths map[string]string) (map[string][]byte, error) {
+	keys := make(map[string][]byte, len(paths))
+	for kid, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read key %q: %v", kid, err)
+		}
+		keys[kid] = data
+	}
+	return keys, nil
+}
+
+// CreateToken creates a new token for a specific username and duration
+func (maker *AsymmetricMaker) CreateToken(username string, role string, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(username, role, duration)
+	if err != nil {
+		return "", payload, err
+	}
+	return maker.sign(payload)
+}
+
+// CreateRefreshToken creates a new refresh token for a specific username and duration
+func (maker *AsymmetricMaker) CreateRefreshToken(username string, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewRefreshPayload(username, duration)
+	if err != nil {
+		return "", payload, err
+	}
+	return maker.sign(payload)
+}
+
+func (maker *AsymmetricMaker) sign(payload *Payload) (string, *Payload, error) {
+	jwtToken := jwt.NewWithClaims(maker.method, payload)
+	jwtToken.Header[kidHeader] = maker.activeKID
+	token, err := jwtToken.SignedString(maker.privateKey)
+	return token, payload, err
+}
+
+// VerifyToken checks if the token is valid or not
+func (maker *AsymmetricMaker) VerifyToken(token string) (*Payload, error) {
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header[kidHeader].(string)
+		public, ok := maker.publicKeys[kid]
+		// the algorithm comes from our key, never from the token header
+		if !ok || token.Method.Alg() != public.method.Alg() {
+			return nil, ErrInvalidToken
+		}
+		return public.key, nil
+	}
+
+	jwtToken, err := jwt.ParseWithClaims(token, &Payload{}, keyFunc)
+	if err != nil {
+		verr, ok := err.(*jwt.ValidationError)
+		if ok && errors.Is(verr.Inner, ErrExpiredToken) {
+			return nil, ErrExpiredToken
+		}
+		return nil, ErrInvalidToken
+	}
+
+	payload, ok := jwtToken.Claims.(*Payload)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return payload, nil
+}
+
+// JWKS returns the public keys verifying the tokens of the maker.
+func (maker *AsymmetricMaker) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0, len(maker.publicKeys))}
+	for _, kid := range sortedKIDs(maker.publicKeys) {
+		set.Keys = append(set.Keys, newJWK(kid, maker.publicKeys[kid]))
+	}
+	return set
+}
+
+func parsePrivateKeyPEM(data []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	switch block.Type {
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+func parsePublicKeyPEM(data []byte) (publicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return publicKey{}, errors.New("no PEM block found")
+	}
+	var key crypto.PublicKey
+	var err error
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return publicKey{}, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return publicKey{}, err
+	}
+	return newPublicKey(key)
+}
+
+func publicKeyOf(private crypto.PrivateKey) (publicKey, error) {
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return publicKey{}, errors.New("unsupported private key")
+	}
+	return newPublicKey(signer.Public())
+}
+
+// newPublicKey picks the signing method of a key: EdDSA for Ed25519, RS256 for RSA.
+func newPublicKey(key crypto.PublicKey) (publicKey, error) {
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		return publicKey{method: jwt.SigningMethodEdDSA, key: k}, nil
+	case *rsa.PublicKey:
+		if k.N.BitLen() < minRSAKeyBits {
+			return publicKey{}, fmt.Errorf("RSA key must be at least %d bits", minRSAKeyBits)
+		}
+		return publicKey{method: jwt.SigningMethodRS256, key: k}, nil
+	default:
+		return publicKey{}, fmt.Errorf("unsupported key type %T, use Ed25519 or RSA", key)
+	}
+}
@@ -0,0 +1,56 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"sort"
+)
+
+// KeySetProvider is implemented by the makers whose tokens can be verified with public keys.
+type KeySetProvider interface {
+	JWKS() JWKSet
+}
+
+// JWKSet is a JSON Web Key Set (RFC 7517).
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is a public JSON Web Key, only the fields of Ed25519 and RSA keys are used.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// Ed25519 (RFC 8037)
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	// RSA (RFC 7518)
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+}
+
+func newJWK(kid string, public publicKey) JWK {
+	jwk := JWK{KeyID: kid, Use: "sig", Algorithm: public.method.Alg()}
+	switch k := public.key.(type) {
+	case ed25519.PublicKey:
+		jwk.KeyType, jwk.Curve = "OKP", "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(k)
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+	}
+	return jwk
+}
+
+func sortedKIDs[T any](keys map[string]T) []string {
+	kids := make([]string, 0, len(keys))
+	for kid := range keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+	return kids
+}
@@ -3,6 +3,7 @@ package token
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt"
@@ -10,17 +11,58 @@ import (
 
 const minSecretKeySize = 32
 
+// kidHeader names the key a token was signed with
+const kidHeader = "kid"
+
 // JWTMaker is a JSON Web Token maker
+// It signs with the active key and verifies with any of its keys, selected by the kid header,
+// so tokens signed with a retired key stay valid until they expire.
 type JWTMaker struct {
-	secretKey string
+	keys      map[string][]byte
+	activeKID string
 }
 
-// NewJWTMaker creates a new JWTMaker
+// NewJWTMaker creates a new JWTMaker with a single key, its tokens carry no kid
 func NewJWTMaker(secretKey string) (Maker, error) {
-	if len(secretKey) < minSecretKeySize {
-		return nil, fmt.Errorf("invalid key size: must be at least %d characters", minSecretKeySize)
+	return NewJWTMakerWithKeys(map[string]string{"": secretKey}, "")
+}
+
+// NewJWTMakerWithKeys creates a new JWTMaker signing with keys[activeKID]
+func NewJWTMakerWithKeys(keys map[string]string, activeKID string) (Maker, error) {
+	maker := &JWTMaker{keys: make(map[string][]byte, len(keys)), activeKID: activeKID}
+	for kid, secretKey := range keys {
+		if len(secretKey) < minSecretKeySize {
+			return nil, fmt.Errorf("invalid key size for kid %q: must be at least %d characters", kid, minSecretKeySize)
+		}
+		maker.keys[kid] = []byte(secretKey)
+	}
+	if _, ok := maker.keys[activeKID]; !ok {
+		return nil, fmt.Errorf("active kid %q is not one of the signing keys", activeKID)
+	}
+	return maker, nil
+}
+
+// ParseKeys reads a key list written as "kid1=secret1,kid2=secret2"
+func ParseKeys(spec string) (map[string]string, error) {
+	keys := make(map[string]string)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kid, secret, ok := strings.Cut(entry, "=")
+		if !ok || kid == "" {
+			return nil, fmt.Errorf("invalid key %q: expected kid=secret", entry)
+		}
+		if _, exists := keys[kid]; exists {
+			return nil, fmt.Errorf("duplicated kid %q", kid)
+		}
+		keys[kid] = secret
 	}
-	return &JWTMaker{secretKey}, nil
+	if len(keys) == 0 {
+		return nil, errors.New("no signing key configured")
+	}
+	return keys, nil
 }
 
 // CreateToken creates a new token for a specific username and duration
@@ -29,9 +71,24 @@ func (maker *JWTMaker) CreateToken(username string, role string, duration time.D
 	if err != nil {
 		return "", payload, err
 	}
+	return maker.sign(payload)
+}
 
+// CreateRefreshToken creates a new refresh token for a specific username and duration
+func (maker *JWTMaker) CreateRefreshToken(username string, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewRefreshPayload(username, duration)
+	if err != nil {
+		return "", payload, err
+	}
+	return maker.sign(payload)
+}
+
+func (maker *JWTMaker) sign(payload *Payload) (string, *Payload, error) {
 	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
-	token, err := jwtToken.SignedString([]byte(maker.secretKey))
+	if maker.activeKID != "" {
+		jwtToken.Header[kidHeader] = maker.activeKID
+	}
+	token, err := jwtToken.SignedString(maker.keys[maker.activeKID])
 	return token, payload, err
 }
 
@@ -42,7 +99,12 @@ func (maker *JWTMaker) VerifyToken(token string) (*Payload, error) {
 		if !ok {
 			return nil, ErrInvalidToken
 		}
-		return []byte(maker.secretKey), nil
+		kid, _ := token.Header[kidHeader].(string)
+		key, ok := maker.keys[kid]
+		if !ok {
+			return nil, ErrInvalidToken
+		}
+		return key, nil
 	}
 
 	jwtToken, err := jwt.ParseWithClaims(token, &Payload{}, keyFunc)
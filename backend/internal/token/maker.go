@@ -1,6 +1,7 @@
 package token
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -9,6 +10,26 @@ type Maker interface {
 	// CreateToken creates a new token for a specific username and duration
 	CreateToken(username string, role string, duration time.Duration) (string, *Payload, error)
 
+	// CreateRefreshToken creates a new refresh token for a specific username and duration
+	CreateRefreshToken(username string, duration time.Duration) (string, *Payload, error)
+
 	// VerifyToken checks if the token is valid or not
 	VerifyToken(token string) (*Payload, error)
 }
+
+// SelfCheck signs a token with maker and verifies it back, so a misconfigured maker fails at startup.
+// The behaviour every Maker must share is covered by the conformance tests of this package.
+func SelfCheck(maker Maker) error {
+	token, created, err := maker.CreateToken("self-check", "player", time.Minute)
+	if err != nil {
+		return fmt.Errorf("%T self check: %w", maker, err)
+	}
+	payload, err := maker.VerifyToken(token)
+	if err != nil {
+		return fmt.Errorf("%T self check: %w", maker, err)
+	}
+	if payload.ID != created.ID {
+		return fmt.Errorf("%T self check: verified token %s, want %s", maker, payload.ID, created.ID)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"testing"
+	"time"
+)
+
+// makerCase builds a maker with fresh keys, building it twice gives two makers
+// with the same kid but different keys, each must refuse the tokens of the other.
+type makerCase struct {
+	name  string
+	build func(t *testing.T) Maker
+}
+
+func makerCases() []makerCase {
+	return []makerCase{
+		{"JWTMaker", func(t *testing.T) Maker {
+			return mustMaker(t)(NewJWTMakerWithKeys(map[string]string{"k1": randomSecret(t)}, "k1"))
+		}},
+		{"AsymmetricMaker Ed25519", func(t *testing.T) Maker {
+			return mustMaker(t)(NewAsymmetricMaker(map[string][]byte{"k1": ed25519PEM(t)}, nil, "k1"))
+		}},
+		{"AsymmetricMaker RS256", func(t *testing.T) Maker {
+			return mustMaker(t)(NewAsymmetricMaker(map[string][]byte{"k1": rsaPEM(t)}, nil, "k1"))
+		}},
+		{"PASETO v4 local", func(t *testing.T) Maker {
+			return mustMaker(t)(NewPasetoLocalMaker(map[string]string{"k1": NewPasetoLocalKey()}, "k1"))
+		}},
+		{"PASETO v4 public", func(t *testing.T) Maker {
+			return mustMaker(t)(NewPasetoPublicMaker(map[string][]byte{"k1": ed25519PEM(t)}, nil, "k1"))
+		}},
+	}
+}
+
+func TestMakerConformance(t *testing.T) {
+	for _, tc := range makerCases() {
+		t.Run(tc.name, func(t *testing.T) {
+			maker := tc.build(t)
+
+			t.Run("roundtrip", func(t *testing.T) {
+				token, created, err := maker.CreateToken("alice", "admin", time.Minute)
+				if err != nil {
+					t.Fatal(err)
+				}
+				payload, err := maker.VerifyToken(token)
+				if err != nil {
+					t.Fatalf("VerifyToken: %v", err)
+				}
+				if payload.ID != created.ID || payload.Username != "alice" || payload.Role != "admin" || payload.IsRefresh() {
+					t.Errorf("got %+v, want %+v", payload, created)
+				}
+				if !sameSecond(payload.IssuedAt, created.IssuedAt) || !sameSecond(payload.ExpiredAt, created.ExpiredAt) {
+					t.Errorf("got times %s %s, want %s %s", payload.IssuedAt, payload.ExpiredAt, created.IssuedAt, created.ExpiredAt)
+				}
+			})
+
+			t.Run("refresh token", func(t *testing.T) {
+				token, created, err := maker.CreateRefreshToken("alice", time.Minute)
+				if err != nil {
+					t.Fatal(err)
+				}
+				payload, err := maker.VerifyToken(token)
+				if err != nil {
+					t.Fatalf("VerifyToken: %v", err)
+				}
+				if !payload.IsRefresh() || payload.ID != created.ID || payload.Username != "alice" {
+					t.Errorf("got %+v, want %+v", payload, created)
+				}
+			})
+
+			t.Run("expired token", func(t *testing.T) {
+				token, _, err := maker.CreateToken("alice", "player", -time.Minute)
+				if err != nil {
+					t.Fatal(err)
+				}
+				wantError(t, maker, token, ErrExpiredToken)
+			})
+
+			t.Run("tampered token", func(t *testing.T) {
+				token, _, err := maker.CreateToken("alice", "player", time.Minute)
+				if err != nil {
+					t.Fatal(err)
+				}
+				wantError(t, maker, tamper(token), ErrInvalidToken)
+			})
+
+			t.Run("malformed token", func(t *testing.T) {
+				for _, token := range []string{"", "not-a-token", "a.b.c", "v4.local.AAAA", "v4.public.AAAA"} {
+					wantError(t, maker, token, ErrInvalidToken)
+				}
+			})
+
+			t.Run("other key", func(t *testing.T) {
+				token, _, err := tc.build(t).CreateToken("alice", "admin", time.Minute)
+				if err != nil {
+					t.Fatal(err)
+				}
+				wantError(t, maker, token, ErrInvalidToken)
+			})
+		})
+	}
+}
+
+func wantError(t *testing.T, maker Maker, token string, want error) {
+	t.Helper()
+	payload, err := maker.VerifyToken(token)
+	if err != want {
+		t.Errorf("VerifyToken(%.20q): got error %v, want %v", token, err, want)
+	}
+	if payload != nil {
+		t.Errorf("VerifyToken(%.20q): got a payload with the error", token)
+	}
+}
+
+// tamper changes a character in the middle of token, away from the padding bits of the last one
+func tamper(token string) string {
+	tampered := []byte(token)
+	for i := len(tampered) / 2; i < len(tampered)-1; i++ {
+		if tampered[i] == '.' {
+			continue
+		}
+		if tampered[i] == 'A' {
+			tampered[i] = 'B'
+		} else {
+			tampered[i] = 'A'
+		}
+		break
+	}
+	return string(tampered)
+}
+
+func sameSecond(a, b time.Time) bool {
+	diff := a.Sub(b)
+	return diff > -time.Second && diff < time.Second
+}
+
+func mustMaker(t *testing.T) func(Maker, error) Maker {
+	return func(maker Maker, err error) Maker {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return maker
+	}
+}
+
+func randomSecret(t *testing.T) string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+func ed25519PEM(t *testing.T) []byte {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkcs8PEM(t, private)
+}
+
+func rsaPEM(t *testing.T) []byte {
+	private, err := rsa.GenerateKey(rand.Reader, minRSAKeyBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkcs8PEM(t, private)
+}
+
+func pkcs8PEM(t *testing.T, key any) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
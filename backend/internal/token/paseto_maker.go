@@ -0,0 +1,234 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"aidanwoods.dev/go-paseto"
+)
+
+// pasetoFooter is stored in clear in the token footer, it only names the key
+type pasetoFooter struct {
+	KeyID string `json:"kid"`
+}
+
+// PasetoLocalMaker is a PASETO v4.local token maker, tokens are encrypted and
+// authenticated with a symmetric key so their payload cannot be read by clients.
+type PasetoLocalMaker struct {
+	keys      map[string]paseto.V4SymmetricKey
+	activeKID string
+	parser    paseto.Parser
+}
+
+// NewPasetoLocalMaker creates a maker encrypting with keys[activeKID].
+// Keys go from kid to a 32 bytes key encoded in hex.
+func NewPasetoLocalMaker(keys map[string]string, activeKID string) (Maker, error) {
+	maker := &PasetoLocalMaker{
+		keys:      make(map[string]paseto.V4SymmetricKey, len(keys)),
+		activeKID: activeKID,
+		parser:    paseto.MakeParser(nil),
+	}
+	for kid, hexKey := range keys {
+		key, err := paseto.V4SymmetricKeyFromHex(hexKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: must be 32 bytes encoded in hex: %v", kid, err)
+		}
+		maker.keys[kid] = key
+	}
+	if _, ok := maker.keys[activeKID]; !ok {
+		return nil, fmt.Errorf("active kid %q is not one of the keys", activeKID)
+	}
+	return maker, nil
+}
+
+// CreateToken creates a new token for a specific username and duration
+func (maker *PasetoLocalMaker) CreateToken(username string, role string, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(username, role, duration)
+	if err != nil {
+		return "", payload, err
+	}
+	return maker.sign(payload)
+}
+
+// CreateRefreshToken creates a new refresh token for a specific username and duration
+func (maker *PasetoLocalMaker) CreateRefreshToken(username string, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewRefreshPayload(username, duration)
+	if err != nil {
+		return "", payload, err
+	}
+	return maker.sign(payload)
+}
+
+func (maker *PasetoLocalMaker) sign(payload *Payload) (string, *Payload, error) {
+	pasetoToken, err := newPasetoToken(payload, maker.activeKID)
+	if err != nil {
+		return "", payload, err
+	}
+	return pasetoToken.V4Encrypt(maker.keys[maker.activeKID], nil), payload, nil
+}
+
+// VerifyToken checks if the token is valid or not
+func (maker *PasetoLocalMaker) VerifyToken(token string) (*Payload, error) {
+	kid, err := pasetoKeyID(maker.parser, paseto.V4Local, token)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := maker.keys[kid]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	pasetoToken, err := maker.parser.ParseV4Local(key, token, nil)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	return pasetoPayload(pasetoToken)
+}
+
+// PasetoPublicMaker is a PASETO v4.public token maker, tokens are signed with
+// an Ed25519 key and can be verified by other services with the public key.
+type PasetoPublicMaker struct {
+	secretKey  paseto.V4AsymmetricSecretKey
+	activeKID  string
+	publicKeys map[string]paseto.V4AsymmetricPublicKey
+	parser     paseto.Parser
+}
+
+// NewPasetoPublicMaker creates a maker signing with privateKeys[activeKID].
+// Both maps go from kid to a PEM encoded Ed25519 key, publicKeys only verify tokens.
+func NewPasetoPublicMaker(privateKeys, publicKeys map[string][]byte, activeKID string) (Maker, error) {
+	maker := &PasetoPublicMaker{
+		activeKID:  activeKID,
+		publicKeys: make(map[string]paseto.V4AsymmetricPublicKey),
+		parser:     paseto.MakeParser(nil),
+	}
+	for kid, data := range privateKeys {
+		private, err := parsePrivateKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("private key %q: %w", kid, err)
+		}
+		edKey, ok := private.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private key %q: PASETO v4 requires an Ed25519 key", kid)
+		}
+		secretKey, err := paseto.NewV4AsymmetricSecretKeyFromEd25519(edKey)
+		if err != nil {
+			return nil, fmt.Errorf("private key %q: %w", kid, err)
+		}
+		maker.publicKeys[kid] = secretKey.Public()
+		if kid == activeKID {
+			maker.secretKey = secretKey
+		}
+	}
+	for kid, data := range publicKeys {
+		if _, exists := maker.publicKeys[kid]; exists {
+			return nil, fmt.Errorf("duplicated kid %q", kid)
+		}
+		public, err := parsePublicKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("public key %q: %w", kid, err)
+		}
+		edKey, ok := public.key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("public key %q: PASETO v4 requires an Ed25519 key", kid)
+		}
+		if maker.publicKeys[kid], err = paseto.NewV4AsymmetricPublicKeyFromEd25519(edKey); err != nil {
+			return nil, fmt.Errorf("public key %q: %w", kid, err)
+		}
+	}
+	if _, ok := privateKeys[activeKID]; !ok {
+		return nil, fmt.Errorf("active kid %q is not one of the private keys", activeKID)
+	}
+	return maker, nil
+}
+
+// CreateToken creates a new token for a specific username and duration
+func (maker *PasetoPublicMaker) CreateToken(username string, role string, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(username, role, duration)
+	if err != nil {
+		return "", payload, err
+	}
+	return maker.sign(payload)
+}
+
+// CreateRefreshToken creates a new refresh token for a specific username and duration
+func (maker *PasetoPublicMaker) CreateRefreshToken(username string, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewRefreshPayload(username, duration)
+	if err != nil {
+		return "", payload, err
+	}
+	return maker.sign(payload)
+}
+
+func (maker *PasetoPublicMaker) sign(payload *Payload) (string, *Payload, error) {
+	pasetoToken, err := newPasetoToken(payload, maker.activeKID)
+	if err != nil {
+		return "", payload, err
+	}
+	return pasetoToken.V4Sign(maker.secretKey, nil), payload, nil
+}
+
+// VerifyToken checks if the token is valid or not
+func (maker *PasetoPublicMaker) VerifyToken(token string) (*Payload, error) {
+	kid, err := pasetoKeyID(maker.parser, paseto.V4Public, token)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := maker.publicKeys[kid]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	pasetoToken, err := maker.parser.ParseV4Public(key, token, nil)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	return pasetoPayload(pasetoToken)
+}
+
+func newPasetoToken(payload *Payload, kid string) (*paseto.Token, error) {
+	claims, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	footer, err := json.Marshal(pasetoFooter{KeyID: kid})
+	if err != nil {
+		return nil, err
+	}
+	return paseto.NewTokenFromClaimsJSON(claims, footer)
+}
+
+// pasetoKeyID reads the kid of the footer, it is authenticated once the token is verified with that key
+func pasetoKeyID(parser paseto.Parser, protocol paseto.Protocol, token string) (string, error) {
+	data, err := parser.UnsafeParseFooter(protocol, token)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	var footer pasetoFooter
+	if err := json.Unmarshal(data, &footer); err != nil {
+		return "", ErrInvalidToken
+	}
+	return footer.KeyID, nil
+}
+
+// pasetoPayload decodes the payload and applies the expiry check shared with the JWT makers
+func pasetoPayload(pasetoToken *paseto.Token) (*Payload, error) {
+	payload := &Payload{}
+	if err := json.Unmarshal(pasetoToken.ClaimsJSON(), payload); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err := payload.Valid(); err != nil {
+		if errors.Is(err, ErrExpiredToken) {
+			return nil, ErrExpiredToken
+		}
+		return nil, ErrInvalidToken
+	}
+	return payload, nil
+}
+
+// NewPasetoLocalKey returns a random v4.local key encoded in hex, for the config
+func NewPasetoLocalKey() string {
+	return hex.EncodeToString(paseto.NewV4SymmetricKey().ExportBytes())
+}
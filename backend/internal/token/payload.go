@@ -13,17 +13,36 @@ var (
 	ErrExpiredToken = errors.New("token has expired")
 )
 
+// Token types, an access token authorizes API calls and a refresh token only renews access tokens.
+// API keys are not tokens, the payload of the requests they authenticate has the api_key type.
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	TokenTypeAPIKey  = "api_key"
+)
+
 // Payload contains the payload data of the token
 type Payload struct {
 	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type,omitempty"`
 	Username  string    `json:"username"`
 	Role      string    `json:"role"`
 	IssuedAt  time.Time `json:"issued_at"`
 	ExpiredAt time.Time `json:"expired_at"`
 }
 
-// NewPayload creates a new token payload with a specific username and duration
+// NewPayload creates a new access token payload with a specific username and duration
 func NewPayload(username string, role string, duration time.Duration) (*Payload, error) {
+	return newPayload(TokenTypeAccess, username, role, duration)
+}
+
+// NewRefreshPayload creates a new refresh token payload, it carries no role
+// since the role is read again from the user when the access token is renewed
+func NewRefreshPayload(username string, duration time.Duration) (*Payload, error) {
+	return newPayload(TokenTypeRefresh, username, "", duration)
+}
+
+func newPayload(tokenType, username, role string, duration time.Duration) (*Payload, error) {
 	tokenID, err := uuid.NewRandom()
 	if err != nil {
 		return nil, err
@@ -31,6 +50,7 @@ func NewPayload(username string, role string, duration time.Duration) (*Payload,
 
 	payload := &Payload{
 		ID:        tokenID,
+		Type:      tokenType,
 		Username:  username,
 		Role:      role,
 		IssuedAt:  time.Now(),
@@ -39,6 +59,12 @@ func NewPayload(username string, role string, duration time.Duration) (*Payload,
 	return payload, nil
 }
 
+// IsRefresh reports whether the payload is a refresh token,
+// tokens issued before token types existed are access tokens
+func (payload *Payload) IsRefresh() bool {
+	return payload.Type == TokenTypeRefresh
+}
+
 // Valid checks if the token payload is valid or not
 func (payload *Payload) Valid() error {
 	if time.Now().After(payload.ExpiredAt) {
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// apiKeyPrefix starts every API key, so leaked keys are easy to recognize
+const apiKeyPrefix = "qk_"
+
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// NewAPIKey returns a key written as qk_<id>_<secret> and the hash of its secret.
+// id is stored in clear to find the key, the secret only as a hash.
+func NewAPIKey(id string) (key, secretHash string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+	return apiKeyPrefix + id + "_" + encoded, HashAPIKeySecret(encoded), nil
+}
+
+// ParseAPIKey splits a key into its id and secret, id must not contain an underscore
+func ParseAPIKey(key string) (id, secret string, err error) {
+	rest, ok := strings.CutPrefix(key, apiKeyPrefix)
+	if !ok {
+		return "", "", ErrInvalidAPIKey
+	}
+	id, secret, ok = strings.Cut(rest, "_")
+	if !ok || id == "" || secret == "" {
+		return "", "", ErrInvalidAPIKey
+	}
+	return id, secret, nil
+}
+
+// HashAPIKeySecret hashes a secret with SHA-256, the secret is random so no salt or slow hash is needed
+func HashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckAPIKeySecret reports whether secret matches the hash, in constant time
+func CheckAPIKeySecret(hash, secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(HashAPIKeySecret(secret))) == 1
+}
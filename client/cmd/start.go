@@ -22,10 +22,12 @@ var startCmd = &cobra.Command{
 		}
 
 		// 2. Use the loaded config's APIURL
-		quiz.RunQuizFlow(cfg.API_URL)
+		sso, _ := cmd.Flags().GetBool("sso")
+		quiz.RunQuizFlow(cfg.API_URL, sso)
 	},
 }
 
 func init() {
+	startCmd.Flags().Bool("sso", false, "sign in with the identity provider of the server instead of a password")
 	rootCmd.AddCommand(startCmd)
 }
@@ -7,16 +7,37 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/matheuspolitano/quiz-go/client/internal/models"
 )
 
+// ErrInvalidCredentials is returned by Login when the username or the password is wrong.
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// ErrUsernameTaken is returned by Register when the username is already used.
+var ErrUsernameTaken = errors.New("username already exists")
+
+// ErrSessionExpired is returned when the session cannot be refreshed anymore, the user must login again.
+var ErrSessionExpired = errors.New("session expired, please login again")
+
+// ErrSSOUnavailable is returned by StartDeviceLogin when the server has no identity provider.
+var ErrSSOUnavailable = errors.New("single sign-on is not enabled on this server")
+
+// ErrDeviceLoginExpired is returned by CompleteDeviceLogin when the user did not approve the login in time.
+var ErrDeviceLoginExpired = errors.New("the login was not approved in time")
+
+// ErrNewAttemptRefused is returned by StartAttempt when the quiz type allows no more attempts
+// or the previous attempt is not finished.
+var ErrNewAttemptRefused = errors.New("a new attempt is not allowed for this quiz type")
+
 // Client wraps the configuration needed to make API calls.
 type Client struct {
-	BaseURL    string
-	httpClient *http.Client
-	token      string
+	BaseURL      string
+	httpClient   *http.Client
+	token        string
+	refreshToken string
 }
 
 // NewClient creates a new Client instance. The http.Client can be customized for timeouts, etc.
@@ -34,45 +55,205 @@ func (c *Client) SetToken(token string) {
 	c.token = token
 }
 
-// Login sends a POST to /api/login with the given username
+// Login sends a POST to /api/login with the given credentials
+// and stores the received token in the client's token field.
+func (c *Client) Login(username, password string) error {
+	return c.authenticate("/api/login", username, password)
+}
+
+// Register creates a new account with a POST to /api/register
 // and stores the received token in the client's token field.
-func (c *Client) Login(username string) error {
-	payload := map[string]string{"username": username}
+func (c *Client) Register(username, password string) error {
+	return c.authenticate("/api/register", username, password)
+}
+
+func (c *Client) authenticate(path, username, password string) error {
+	payload := map[string]string{"username": username, "password": password}
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal login payload: %w", err)
 	}
 
-	url := c.BaseURL + "/api/login"
+	url := c.BaseURL + path
 	resp, err := c.httpClient.Post(url, "application/json", bytes.NewBuffer(data))
 	if err != nil {
 		return fmt.Errorf("failed to make login request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusUnauthorized {
+		return ErrInvalidCredentials
+	}
+	if resp.StatusCode == http.StatusConflict {
+		return ErrUsernameTaken
+	}
 	if resp.StatusCode != http.StatusCreated {
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(bodyBytes))
 	}
 
+	return c.readTokens(resp.Body)
+}
+
+// readTokens stores the tokens of a login response
+func (c *Client) readTokens(body io.Reader) error {
 	var tokenResp models.AccessTokenResponse
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+	if err := json.NewDecoder(body).Decode(&tokenResp); err != nil {
 		return fmt.Errorf("decoding token response: %w", err)
 	}
 
 	c.token = tokenResp.AccessToken
+	c.refreshToken = tokenResp.RefreshToken
+	return nil
+}
+
+// StartDeviceLogin starts a single sign-on with the identity provider of the server.
+// The user approves it in a browser while CompleteDeviceLogin waits for the tokens.
+func (c *Client) StartDeviceLogin() (*models.DeviceAuthorization, error) {
+	resp, err := c.httpClient.Post(c.BaseURL+"/api/oidc/device", "application/json", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the device login: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrSSOUnavailable
+	}
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var auth models.DeviceAuthorization
+	if err := json.NewDecoder(resp.Body).Decode(&auth); err != nil {
+		return nil, fmt.Errorf("decoding device authorization: %w", err)
+	}
+	return &auth, nil
+}
+
+// CompleteDeviceLogin polls the server until the user approves the device login,
+// then stores the received tokens like Login.
+func (c *Client) CompleteDeviceLogin(auth *models.DeviceAuthorization) error {
+	data, err := json.Marshal(map[string]string{"device_code": auth.DeviceCode})
+	if err != nil {
+		return fmt.Errorf("failed to marshal device token payload: %w", err)
+	}
+	interval := time.Duration(auth.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	deadline := time.Now().Add(time.Duration(auth.ExpiresIn) * time.Second)
+
+	for time.Now().Before(deadline) {
+		time.Sleep(interval)
+		resp, err := c.httpClient.Post(c.BaseURL+"/api/oidc/device/token", "application/json", bytes.NewBuffer(data))
+		if err != nil {
+			return fmt.Errorf("failed to poll the device login: %w", err)
+		}
+		if resp.StatusCode == http.StatusCreated {
+			err := c.readTokens(resp.Body)
+			resp.Body.Close()
+			return err
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			// the server rate limit asks for slower polls like slow_down, waiting at least Retry-After
+			resp.Body.Close()
+			interval += 5 * time.Second
+			if wait := retryAfter(resp); wait > interval {
+				interval = wait
+			}
+			continue
+		}
+
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		_ = json.Unmarshal(bodyBytes, &errResp)
+		switch errResp.Error {
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		default:
+			return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(bodyBytes))
+		}
+	}
+	return ErrDeviceLoginExpired
+}
+
+// retryAfter reads the Retry-After header of resp in seconds, zero when it is missing
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Refresh renews the access token with the refresh token received at login.
+func (c *Client) Refresh() error {
+	if c.refreshToken == "" {
+		return ErrSessionExpired
+	}
+	resp, err := c.postRefreshToken("/api/token/refresh")
+	if err != nil {
+		return fmt.Errorf("sending refresh request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		c.refreshToken = ""
+		return ErrSessionExpired
+	}
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var tokenResp models.AccessTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return fmt.Errorf("decoding token response: %w", err)
+	}
+	c.token = tokenResp.AccessToken
+	return nil
+}
+
+// Logout revokes the session on the server and forgets the tokens.
+func (c *Client) Logout() error {
+	if c.refreshToken == "" {
+		return nil
+	}
+	resp, err := c.postRefreshToken("/api/logout")
+	if err != nil {
+		return fmt.Errorf("sending logout request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	c.token, c.refreshToken = "", ""
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusUnauthorized {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
 	return nil
 }
 
+func (c *Client) postRefreshToken(path string) (*http.Response, error) {
+	data, err := json.Marshal(map[string]string{"refresh_token": c.refreshToken})
+	if err != nil {
+		return nil, err
+	}
+	return c.httpClient.Post(c.BaseURL+path, "application/json", bytes.NewBuffer(data))
+}
+
 // GetQuizTypes retrieves the available quiz types for the user.
 func (c *Client) GetQuizTypes() ([]models.QuizType, error) {
 	req, err := http.NewRequest(http.MethodGet, c.BaseURL+"/api/quiz/types", nil)
 	if err != nil {
 		return nil, fmt.Errorf("creating GetQuizTypes request: %w", err)
 	}
-	c.addAuthHeader(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("sending GetQuizTypes request: %w", err)
 	}
@@ -90,33 +271,60 @@ func (c *Client) GetQuizTypes() ([]models.QuizType, error) {
 	return quizTypes, nil
 }
 
-// JoinQuiz joins a specific quiz type for the logged-in user.
-func (c *Client) JoinQuiz(quizType string) error {
+// JoinQuiz joins a specific quiz type for the logged-in user and returns the latest attempt,
+// the first attempt is started by the server.
+func (c *Client) JoinQuiz(quizType string) (*models.QuestionFlow, error) {
 	url := fmt.Sprintf("%s/api/quiz/joinQuiz/%s", c.BaseURL, quizType)
 	req, err := http.NewRequest(http.MethodPost, url, nil)
 	if err != nil {
-		return fmt.Errorf("creating JoinQuiz request: %w", err)
+		return nil, fmt.Errorf("creating JoinQuiz request: %w", err)
 	}
-	c.addAuthHeader(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return fmt.Errorf("sending JoinQuiz request: %w", err)
+		return nil, fmt.Errorf("sending JoinQuiz request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// 400 => user may already have joined this quiz.
-	if resp.StatusCode == http.StatusBadRequest {
+	if resp.StatusCode != http.StatusAccepted {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("already joined? status %d: %s", resp.StatusCode, string(bodyBytes))
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var flow models.QuestionFlow
+	if err := json.NewDecoder(resp.Body).Decode(&flow); err != nil {
+		return nil, fmt.Errorf("decoding question flow: %w", err)
+	}
+	return &flow, nil
+}
+
+// StartAttempt starts a new attempt at a quiz type once the previous one is closed.
+func (c *Client) StartAttempt(quizType string) (*models.QuestionFlow, error) {
+	url := fmt.Sprintf("%s/api/quiz/attempts/%s", c.BaseURL, quizType)
+	req, err := http.NewRequest(http.MethodPost, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating StartAttempt request: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending StartAttempt request: %w", err)
 	}
+	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusCreated {
+	if resp.StatusCode == http.StatusConflict {
+		return nil, ErrNewAttemptRefused
+	}
+	if resp.StatusCode != http.StatusCreated {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(bodyBytes))
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(bodyBytes))
 	}
 
-	return nil
+	var flow models.QuestionFlow
+	if err := json.NewDecoder(resp.Body).Decode(&flow); err != nil {
+		return nil, fmt.Errorf("decoding question flow: %w", err)
+	}
+	return &flow, nil
 }
 
 // GetNextQuestion fetches the next question for the user.
@@ -126,9 +334,8 @@ func (c *Client) GetNextQuestion(quizType string) (*models.Question, error) {
 	if err != nil {
 		return nil, fmt.Errorf("creating GetNextQuestion request: %w", err)
 	}
-	c.addAuthHeader(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("sending GetNextQuestion request: %w", err)
 	}
@@ -168,10 +375,9 @@ func (c *Client) SubmitAnswer(quizType, questionID, answer string) (*models.Hist
 	if err != nil {
 		return nil, fmt.Errorf("creating SubmitAnswer request: %w", err)
 	}
-	c.addAuthHeader(req)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("sending SubmitAnswer request: %w", err)
 	}
@@ -196,9 +402,8 @@ func (c *Client) GetScore(quizType string) (*models.ScoreResponse, error) {
 	if err != nil {
 		return nil, fmt.Errorf("creating GetScore request: %w", err)
 	}
-	c.addAuthHeader(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("sending GetScore request: %w", err)
 	}
@@ -217,6 +422,29 @@ func (c *Client) GetScore(quizType string) (*models.ScoreResponse, error) {
 	return &score, nil
 }
 
+// do sends req with the access token. When the access token has expired it is
+// refreshed once and the request is sent again, so callers never see the refresh.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	c.addAuthHeader(req)
+	resp, err := c.httpClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || c.refreshToken == "" {
+		return resp, err
+	}
+	resp.Body.Close()
+
+	if err := c.Refresh(); err != nil {
+		return nil, err
+	}
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	c.addAuthHeader(retry)
+	return c.httpClient.Do(retry)
+}
+
 // addAuthHeader sets the "Authorization: Bearer {token}" header, if a token is available.
 func (c *Client) addAuthHeader(req *http.Request) {
 	if c.token != "" {
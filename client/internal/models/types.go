@@ -3,8 +3,22 @@ package models
 import "time"
 
 // AccessTokenResponse is the structure we expect when we login successfully.
+// A refresh returns only the access token.
 type AccessTokenResponse struct {
-	AccessToken string `json:"access_token"`
+	SessionID    string `json:"session_id"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// DeviceAuthorization is returned when a single sign-on starts with POST /api/oidc/device,
+// the user approves the login at VerificationURI by entering UserCode.
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
 }
 
 // QuizType represents the structure of a quiz type from GET /quiz/types.
@@ -13,25 +27,51 @@ type QuizType struct {
 	QuestionsID []string `json:"questions_id"`
 }
 
+// Kinds of Question, the kind tells how the question is answered
+const (
+	KindChoice      = "choice"
+	KindTrueFalse   = "true_false"
+	KindMultiSelect = "multi_select"
+	KindNumeric     = "numeric"
+	KindText        = "text"
+)
+
 // Question represents the structure of each question from the server.
 type Question struct {
 	ID      string   `json:"id"`
+	Kind    string   `json:"kind"`
 	Prompt  string   `json:"prompt"`
 	Options []string `json:"options"`
-	Answer  string   `json:"answer"` // The correct answer (often not sent in real scenarios)
+	// Answer is only sent once revealed, after the question was answered in a closed quiz
+	Answer string `json:"answer,omitempty"`
+}
+
+// QuestionFlow is one attempt of the user at a quiz type, attempts are numbered from 1.
+// ClosedAt is the zero time while the attempt is open.
+type QuestionFlow struct {
+	UserID       string    `json:"user_id"`
+	TypeQuiz     string    `json:"type_quiz"`
+	Attempt      int       `json:"attempt"`
+	History      []string  `json:"history"`
+	CreatedAt    time.Time `json:"created_at"`
+	ClosedAt     time.Time `json:"closed_at"`
+	AccuracyRate float64   `json:"accuracy_rate"`
+}
+
+// IsClosed reports whether every question of the attempt was answered
+func (f *QuestionFlow) IsClosed() bool {
+	return !f.ClosedAt.IsZero()
 }
 
 // ScoreResponse is the structure of the final score response from the server.
+// UserQuiz is the latest attempt, best and average only count the closed attempts.
 type ScoreResponse struct {
-	UserQuiz struct {
-		UserID       string   `json:"user_id"`
-		TypeQuiz     string   `json:"type_quiz"`
-		History      []string `json:"history"`
-		CreatedAt    string   `json:"created_at"`
-		ClosedAt     string   `json:"closed_at"`
-		AccuracyRate float64  `json:"accuracy_rate"`
-	} `json:"user_quiz"`
-	GeneralAccuracyRates float64 `json:"general_accuracy_rates"`
+	UserQuiz             QuestionFlow  `json:"user_quiz"`
+	BestAttempt          *QuestionFlow `json:"best_attempt"`
+	Attempts             int           `json:"attempts"`
+	ClosedAttempts       int           `json:"closed_attempts"`
+	AverageAccuracy      float64       `json:"average_accuracy"`
+	GeneralAccuracyRates float64       `json:"general_accuracy_rates"`
 }
 
 type History struct {
@@ -40,5 +80,6 @@ type History struct {
 	QuestionID     string    `json:"question_id"`
 	Answer         string    `json:"answer"`
 	ExpectedAnswer string    `json:"expected_answer"`
+	Score          *float64  `json:"score,omitempty"`
 	CreatedAt      time.Time `json:"created_at"`
 }
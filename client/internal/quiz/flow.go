@@ -2,24 +2,29 @@ package quiz
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
+	"golang.org/x/term"
 
 	"github.com/matheuspolitano/quiz-go/client/internal/api"
 	"github.com/matheuspolitano/quiz-go/client/internal/models"
 )
 
 // RunQuizFlow orchestrates the entire quiz process:
-// 1. Ask user for username and login
+// 1. Ask user for username and password, login or register, or sign in with the identity provider when sso is set
 // 2. Retrieve quiz types
 // 3. User selects a quiz type
-// 4. Join the quiz
+// 4. Join the quiz, offering a new attempt when the last one is finished
 // 5. Fetch next question, answer, repeat
 // 6. Retrieve final score
-func RunQuizFlow(baseURL string) {
+func RunQuizFlow(baseURL string, sso bool) {
 	// Create a new client for the quiz API.
 	client := api.NewClient(baseURL)
 	reader := bufio.NewReader(os.Stdin)
@@ -27,21 +32,22 @@ func RunQuizFlow(baseURL string) {
 	color.Cyan("Welcome to the Quiz CLI!")
 	fmt.Println(strings.Repeat("=", 40))
 
-	// 1. Prompt for username and login
-	username, err := promptForUsername(reader)
-	if err != nil {
-		color.Red("Error reading username: %v", err)
-		return
+	// 1. Prompt for credentials and login, offering to register unknown users
+	login := loginOrRegister
+	if sso {
+		login = loginWithSSO
 	}
-
-	// Use the client to log in
-	err = client.Login(username)
-	if err != nil {
+	if err := login(reader, client); err != nil {
 		color.Red("Login failed: %v", err)
 		return
 	}
 
 	color.Green("Successfully logged in! Your access token is saved.\n")
+	defer func() {
+		if err := client.Logout(); err != nil {
+			color.Yellow("Unable to close the session: %v", err)
+		}
+	}()
 
 	// Outer loop to allow multiple quiz attempts
 	for {
@@ -64,16 +70,18 @@ func RunQuizFlow(baseURL string) {
 		}
 
 		// 4. Join the quiz
-		if err := client.JoinQuiz(selectedQuizType); err != nil {
+		attempt, err := joinQuiz(reader, client, selectedQuizType)
+		if err != nil {
 			color.Red("Cannot join quiz: %v", err)
 			continue
 		}
-		color.Green("Joined quiz: %s", selectedQuizType)
+		color.Green("Joined quiz: %s (attempt %d)", selectedQuizType, attempt.Attempt)
 
-		// 5. Question loop
-		err = questionLoop(reader, client, selectedQuizType)
-		if err != nil {
-			color.Red("Error during question flow: %v", err)
+		// 5. Question loop, a finished attempt only shows its score
+		if !attempt.IsClosed() {
+			if err := questionLoop(reader, client, selectedQuizType); err != nil {
+				color.Red("Error during question flow: %v", err)
+			}
 		}
 
 		// 6. Fetch final score
@@ -96,10 +104,35 @@ func RunQuizFlow(baseURL string) {
 	}
 }
 
+// joinQuiz returns the latest attempt at quizType. When it is already finished the user
+// may start a new one, otherwise the finished attempt is kept and only its score is shown.
+func joinQuiz(reader *bufio.Reader, client *api.Client, quizType string) (*models.QuestionFlow, error) {
+	attempt, err := client.JoinQuiz(quizType)
+	if err != nil || !attempt.IsClosed() {
+		return attempt, err
+	}
+	color.Yellow("You finished attempt %d of %s with %.2f%%.", attempt.Attempt, quizType, attempt.AccuracyRate*100)
+	again, err := promptYesNo(reader, "Do you want to start a new attempt? (Y/N): ")
+	if err != nil || !again {
+		return attempt, err
+	}
+	next, err := client.StartAttempt(quizType)
+	if errors.Is(err, api.ErrNewAttemptRefused) {
+		color.Yellow("%v.", err)
+		return attempt, nil
+	}
+	return next, err
+}
+
 // promptForAnotherQuiz asks the user if they want to try another quiz type.
 func promptForAnotherQuiz(reader *bufio.Reader) (bool, error) {
+	return promptYesNo(reader, "Do you want to try another quiz type? (Y/N): ")
+}
+
+// promptYesNo asks question until the user answers Y or N.
+func promptYesNo(reader *bufio.Reader, question string) (bool, error) {
 	for {
-		fmt.Print("Do you want to try another quiz type? (Y/N): ")
+		fmt.Print(question)
 		input, err := reader.ReadString('\n')
 		if err != nil {
 			return false, err
@@ -139,7 +172,7 @@ func questionLoop(reader *bufio.Reader, client *api.Client, quizType string) err
 		}
 
 		// Prompt for answer
-		answer, err := promptForAnswer(reader)
+		answer, err := promptForAnswer(reader, question)
 		if err != nil {
 			return err
 		}
@@ -155,9 +188,18 @@ func questionLoop(reader *bufio.Reader, client *api.Client, quizType string) err
 			return err
 		}
 		color.Green("Your answer (%s) was submitted.\n", answer)
-		if resultAnswer.Answer == resultAnswer.ExpectedAnswer {
+		score := 0.0
+		if resultAnswer.Score != nil {
+			score = *resultAnswer.Score
+		} else if resultAnswer.Answer == resultAnswer.ExpectedAnswer {
+			score = 1
+		}
+		switch {
+		case score >= 1:
 			color.Green("Your answer is right :) \n")
-		} else {
+		case score > 0:
+			color.Yellow("Your answer is partially right (%.0f%%). The right is (%s) \n", score*100, resultAnswer.ExpectedAnswer)
+		default:
 			color.Red("Your answer is wrong :(. The right is (%s) \n", resultAnswer.ExpectedAnswer)
 		}
 
@@ -176,16 +218,96 @@ func fetchAndDisplayScore(client *api.Client, quizType string) error {
 	color.Magenta("========================================")
 
 	fmt.Printf("Quiz Type        : %s\n", scoreResp.UserQuiz.TypeQuiz)
+	fmt.Printf("Attempt          : %d\n", scoreResp.UserQuiz.Attempt)
 	fmt.Printf("Answered         : %d questions\n", len(scoreResp.UserQuiz.History))
 	fmt.Printf("Accuracy Rate    : %.2f%%\n", scoreResp.UserQuiz.AccuracyRate*100)
+	if scoreResp.BestAttempt != nil {
+		fmt.Printf("Best Attempt     : %d with %.2f%%\n", scoreResp.BestAttempt.Attempt, scoreResp.BestAttempt.AccuracyRate*100)
+		fmt.Printf("Your Avg Rate    : %.2f%% over %d attempts\n", scoreResp.AverageAccuracy*100, scoreResp.ClosedAttempts)
+	}
 	fmt.Printf("General Avg Rate : %.2f%%\n", scoreResp.GeneralAccuracyRates*100)
-	fmt.Printf("Quiz closed at   : %s\n", scoreResp.UserQuiz.ClosedAt)
+	if scoreResp.UserQuiz.IsClosed() {
+		fmt.Printf("Quiz closed at   : %s\n", scoreResp.UserQuiz.ClosedAt.Format(time.RFC1123))
+	}
 
 	color.Magenta("========================================")
 
 	return nil
 }
 
+// loginOrRegister logs the user in, when the credentials are refused
+// it offers to create an account with them instead.
+func loginOrRegister(reader *bufio.Reader, client *api.Client) error {
+	username, err := promptForUsername(reader)
+	if err != nil {
+		return err
+	}
+	password, err := promptForPassword(reader, "Enter your password: ")
+	if err != nil {
+		return err
+	}
+
+	err = client.Login(username, password)
+	if !errors.Is(err, api.ErrInvalidCredentials) {
+		return err
+	}
+
+	color.Yellow("Wrong password or unknown user.")
+	register, err := promptYesNo(reader, fmt.Sprintf("Create a new account for %s? (Y/N): ", username))
+	if err != nil || !register {
+		return api.ErrInvalidCredentials
+	}
+	confirm, err := promptForPassword(reader, "Confirm your password: ")
+	if err != nil {
+		return err
+	}
+	if confirm != password {
+		return errors.New("passwords do not match")
+	}
+	if err := client.Register(username, password); err != nil {
+		if errors.Is(err, api.ErrUsernameTaken) {
+			return fmt.Errorf("%s is already taken, check your password", username)
+		}
+		return err
+	}
+	return nil
+}
+
+// loginWithSSO signs in with the device flow: the user approves the login
+// in a browser, possibly on another device, while the CLI waits.
+func loginWithSSO(_ *bufio.Reader, client *api.Client) error {
+	auth, err := client.StartDeviceLogin()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("To sign in, open %s and enter the code ", auth.VerificationURI)
+	color.New(color.Bold).Println(auth.UserCode)
+	if auth.VerificationURIComplete != "" {
+		fmt.Printf("or open %s\n", auth.VerificationURIComplete)
+	}
+	fmt.Println("Waiting for the approval...")
+	return client.CompleteDeviceLogin(auth)
+}
+
+// promptForPassword reads a password without echoing it when stdin is a terminal.
+func promptForPassword(reader *bufio.Reader, prompt string) (string, error) {
+	fmt.Print(prompt)
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		input, err := reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(input, "\r\n"), nil
+	}
+	password, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
+
 // promptForUsername reads the username from stdin.
 func promptForUsername(reader *bufio.Reader) (string, error) {
 	fmt.Print("Enter your username: ")
@@ -223,22 +345,71 @@ func promptForQuizType(reader *bufio.Reader, quizTypes []models.QuizType) (strin
 	return quizTypes[index].Name, nil
 }
 
-// promptForAnswer repeatedly prompts the user for an answer until a valid one is provided.
-func promptForAnswer(reader *bufio.Reader) (string, error) {
-	validOptions := []string{"A", "B", "C", "D"}
+// promptForAnswer repeatedly prompts the user for an answer until one fitting the question kind is provided.
+func promptForAnswer(reader *bufio.Reader, question *models.Question) (string, error) {
+	letters := make([]string, len(question.Options))
+	for i := range question.Options {
+		letters[i] = string(rune('A' + i))
+	}
 	for {
-		fmt.Print("Your answer (A, B, C, D): ")
+		var prompt string
+		switch question.Kind {
+		case models.KindTrueFalse:
+			prompt = "Your answer (true or false): "
+		case models.KindMultiSelect:
+			prompt = fmt.Sprintf("Your answers, separated by commas (%s): ", strings.Join(letters, ", "))
+		case models.KindNumeric:
+			prompt = "Your answer (a number): "
+		case models.KindText:
+			prompt = "Your answer: "
+		default:
+			prompt = fmt.Sprintf("Your answer (%s): ", strings.Join(letters, ", "))
+		}
+		fmt.Print(prompt)
 		input, err := reader.ReadString('\n')
 		if err != nil {
 			return "", err
 		}
-		input = strings.TrimSpace(strings.ToUpper(input))
+		input = strings.TrimSpace(input)
+
+		if answer, ok := checkAnswer(question.Kind, input, letters); ok {
+			return answer, nil
+		}
+		fmt.Printf("Invalid answer: %s.\n", input)
+	}
+}
 
-		for _, opt := range validOptions {
-			if input == opt {
-				return input, nil
+// checkAnswer checks that input answers a question of kind, letters are the options it can pick
+func checkAnswer(kind, input string, letters []string) (string, bool) {
+	switch kind {
+	case models.KindTrueFalse:
+		switch strings.ToLower(input) {
+		case "true", "t", "yes", "y":
+			return "true", true
+		case "false", "f", "no", "n":
+			return "false", true
+		}
+		return "", false
+	case models.KindMultiSelect:
+		picked := make([]string, 0)
+		for _, part := range strings.Split(input, ",") {
+			part = strings.ToUpper(strings.TrimSpace(part))
+			if part == "" {
+				continue
+			}
+			if !slices.Contains(letters, part) {
+				return "", false
 			}
+			picked = append(picked, part)
 		}
-		fmt.Printf("Invalid answer: %s. Please enter one of %v.\n", input, validOptions)
+		return strings.Join(picked, ","), len(picked) > 0
+	case models.KindNumeric:
+		_, err := strconv.ParseFloat(strings.ReplaceAll(input, ",", "."), 64)
+		return input, err == nil
+	case models.KindText:
+		return input, input != ""
+	default:
+		input = strings.ToUpper(input)
+		return input, slices.Contains(letters, input)
 	}
 }